@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// Product is a product in the catalog, in the same JSON form that RemoteService reads
+type Product struct {
+	Name  string `json:"name"`
+	Price string `json:"price"`
+}
+
+// amount returns the amount of the price, which is a number followed by an optional unit, eg 2.50/lb
+func (p Product) amount() (float64, error) {
+	price := p.Price
+	if i := strings.IndexByte(price, '/'); i >= 0 {
+		price = price[:i]
+	}
+
+	return strconv.ParseFloat(strings.TrimSpace(price), 64)
+}
+
+// Catalog is the products served, in the order of the data file
+type Catalog struct {
+	products []Product
+	amounts  []float64
+}
+
+// LoadCatalog loads a catalog from a JSON file containing an array of products
+func LoadCatalog(filename string) (*Catalog, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var products []Product
+	if err := json.Unmarshal(data, &products); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	c := &Catalog{products: products, amounts: make([]float64, len(products))}
+	for i, p := range products {
+		if c.amounts[i], err = p.amount(); err != nil {
+			return nil, fmt.Errorf("%s: product %q: price %q does not start with a number", filename, p.Name, p.Price)
+		}
+	}
+
+	return c, nil
+}
+
+// Filter selects products.
+// Zero values match every product.
+type Filter struct {
+	// Name matches products whose name contains it, case insensitive
+	Name string
+	// MinPrice and MaxPrice match products whose price is in the range, inclusive
+	MinPrice float64
+	MaxPrice float64
+}
+
+// Find returns the products that match a filter, in catalog order
+func (c *Catalog) Find(f Filter) []Product {
+	var (
+		name     = strings.ToLower(f.Name)
+		products = []Product{}
+	)
+
+	for i, p := range c.products {
+		if (name != "") && !strings.Contains(strings.ToLower(p.Name), name) {
+			continue
+		}
+
+		if (f.MinPrice > 0) && (c.amounts[i] < f.MinPrice) {
+			continue
+		}
+
+		if (f.MaxPrice > 0) && (c.amounts[i] > f.MaxPrice) {
+			continue
+		}
+
+		products = append(products, p)
+	}
+
+	return products
+}
+
+// Get returns the product with a name, case insensitive, and false if there is no such product
+func (c *Catalog) Get(name string) (Product, bool) {
+	for _, p := range c.products {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+
+	return Product{}, false
+}
@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+func main() {
+	var (
+		addr          = flag.String("addr", ":8081", "address to listen on")
+		dataFile      = flag.String("data", "products.json", "JSON file of products to serve")
+		maxAge        = flag.Duration("max-age", time.Minute, "how long clients may cache responses")
+		latency       = flag.Duration("latency", 0, "delay before each response")
+		jitter        = flag.Duration("jitter", 0, "maximum random delay added to the latency")
+		failureRate   = flag.Float64("failure-rate", 0, "fraction of requests to fail, from 0 to 1")
+		failureStatus = flag.Int("failure-status", http.StatusServiceUnavailable, "status of failed requests, from 400 to 599")
+		seed          = flag.Int64("seed", time.Now().UnixNano(), "random seed, to repeat the same failures")
+	)
+	flag.Parse()
+
+	if (*failureRate < 0) || (*failureRate > 1) {
+		fmt.Fprintln(os.Stderr, "-failure-rate must be from 0 to 1")
+		os.Exit(2)
+	}
+
+	// Other statuses would not be failures, and a 1xx status cannot be the final response
+	if (*failureStatus < 400) || (*failureStatus > 599) {
+		fmt.Fprintln(os.Stderr, "-failure-status must be an HTTP error status from 400 to 599")
+		os.Exit(2)
+	}
+
+	catalog, err := LoadCatalog(*dataFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	srv := newServer(catalog, *maxAge, Faults{
+		Latency:       *latency,
+		Jitter:        *jitter,
+		FailureRate:   *failureRate,
+		FailureStatus: *failureStatus,
+	}, *seed)
+
+	log.Printf("Serving %s on %s%s", *dataFile, *addr, productsPath)
+	if err := http.ListenAndServe(*addr, srv); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
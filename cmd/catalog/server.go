@@ -0,0 +1,264 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// productsPath is the path products are served from
+const productsPath = "/products"
+
+// Faults are artificial problems injected into every response, to exercise clients
+type Faults struct {
+	// Latency is the delay before responding, plus a random delay up to Jitter
+	Latency time.Duration
+	Jitter  time.Duration
+	// FailureRate is the fraction of requests, from 0 to 1, that fail with FailureStatus
+	FailureRate   float64
+	FailureStatus int
+}
+
+// server serves the products of a catalog as JSON
+type server struct {
+	catalog *Catalog
+	maxAge  time.Duration
+	faults  Faults
+
+	// rnd is not safe for concurrent use, so is guarded by mu
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// newServer constructs a server.
+// The seed makes injected faults repeatable.
+func newServer(catalog *Catalog, maxAge time.Duration, faults Faults, seed int64) *server {
+	return &server{
+		catalog: catalog,
+		maxAge:  maxAge,
+		faults:  faults,
+		rnd:     rand.New(rand.NewSource(seed)),
+	}
+}
+
+// random returns a random number in [0, 1)
+func (s *server) random() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.rnd.Float64()
+}
+
+// ServeHTTP is http.Handler for server.
+// GET, HEAD and POST all return products, as RemoteService can be configured with any of them.
+// /products returns the products matching the parameters, and /products/{name} returns the named product.
+// Parameters can be in the query, and for POST, a form or JSON object body.
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status := s.serve(w, r)
+	log.Printf("%s %s -> %d", r.Method, r.URL, status)
+}
+
+// serve serves a request, and returns the status
+func (s *server) serve(w http.ResponseWriter, r *http.Request) int {
+	name := strings.TrimPrefix(r.URL.Path, productsPath+"/")
+	if (r.URL.Path != productsPath) && ((name == r.URL.Path) || (name == "") || strings.Contains(name, "/")) {
+		http.NotFound(w, r)
+		return http.StatusNotFound
+	}
+
+	if (r.Method != http.MethodGet) && (r.Method != http.MethodHead) && (r.Method != http.MethodPost) {
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return http.StatusMethodNotAllowed
+	}
+
+	// Injected latency, which ends early if the client gives up
+	if delay := s.faults.Latency + time.Duration(s.random()*float64(s.faults.Jitter)); delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return 499
+		}
+	}
+
+	// Injected failure
+	if (s.faults.FailureRate > 0) && (s.random() < s.faults.FailureRate) {
+		if (s.faults.FailureStatus == http.StatusServiceUnavailable) || (s.faults.FailureStatus == http.StatusTooManyRequests) {
+			w.Header().Set("Retry-After", "1")
+		}
+
+		http.Error(w, http.StatusText(s.faults.FailureStatus), s.faults.FailureStatus)
+		return s.faults.FailureStatus
+	}
+
+	query, err := requestParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return http.StatusBadRequest
+	}
+
+	filter, page, size, err := parseQuery(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return http.StatusBadRequest
+	}
+
+	var products []Product
+	if r.URL.Path == productsPath {
+		products = s.catalog.Find(filter)
+	} else if product, have := s.catalog.Get(name); have {
+		products = []Product{product}
+	} else {
+		http.NotFound(w, r)
+		return http.StatusNotFound
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(products)))
+
+	if size > 0 {
+		pages := (len(products) + size - 1) / size
+		if link := linkHeader(r.URL, query, page, pages); link != "" {
+			w.Header().Set("Link", link)
+		}
+
+		start, end := (page-1)*size, page*size
+		if start > len(products) {
+			start = len(products)
+		}
+		if end > len(products) {
+			end = len(products)
+		}
+		products = products[start:end]
+	}
+
+	var body bytes.Buffer
+	json.NewEncoder(&body).Encode(products)
+
+	// The ETag is a hash of the body, so it changes whenever the selected products change
+	sum := sha256.Sum256(body.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(s.maxAge.Seconds())))
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return http.StatusNotModified
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	if r.Method != http.MethodHead {
+		body.WriteTo(w)
+	}
+
+	return http.StatusOK
+}
+
+// requestParams returns the query parameters of a request, plus for POST, the fields of a form or JSON object body
+func requestParams(r *http.Request) (url.Values, error) {
+	params := r.URL.Query()
+	if r.Method != http.MethodPost {
+		return params, nil
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		var fields map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&fields); (err != nil) && (err != io.EOF) {
+			return nil, fmt.Errorf("body must be a JSON object: %w", err)
+		}
+
+		for name, value := range fields {
+			params.Set(name, fmt.Sprint(value))
+		}
+
+		return params, nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	for name, values := range r.PostForm {
+		params[name] = append(params[name], values...)
+	}
+
+	return params, nil
+}
+
+// parseQuery parses the filter and pagination query parameters.
+// A size of 0 means all products are returned.
+func parseQuery(query url.Values) (filter Filter, page, size int, err error) {
+	filter.Name = query.Get("name")
+
+	for param, value := range map[string]*float64{"minPrice": &filter.MinPrice, "maxPrice": &filter.MaxPrice} {
+		if s := query.Get(param); s != "" {
+			if *value, err = strconv.ParseFloat(s, 64); (err != nil) || (*value < 0) {
+				return Filter{}, 0, 0, fmt.Errorf("%s must be a non-negative number", param)
+			}
+		}
+	}
+
+	page, size = 1, 0
+	for param, value := range map[string]*int{"page": &page, "size": &size} {
+		if s := query.Get(param); s != "" {
+			if *value, err = strconv.Atoi(s); (err != nil) || (*value < 1) {
+				return Filter{}, 0, 0, fmt.Errorf("%s must be a positive integer", param)
+			}
+		}
+	}
+
+	return filter, page, size, nil
+}
+
+// linkHeader returns a Link header value with the first, prev, next and last pages that exist
+func linkHeader(u *url.URL, query url.Values, page, pages int) string {
+	var links []string
+	link := func(rel string, page int) {
+		q := url.Values{}
+		for key, values := range query {
+			q[key] = values
+		}
+		q.Set("page", strconv.Itoa(page))
+
+		links = append(links, fmt.Sprintf(`<%s?%s>; rel="%s"`, u.Path, q.Encode(), rel))
+	}
+
+	if pages == 0 {
+		return ""
+	}
+
+	link("first", 1)
+	if page > 1 {
+		link("prev", page-1)
+	}
+	if page < pages {
+		link("next", page+1)
+	}
+	link("last", pages)
+
+	return strings.Join(links, ", ")
+}
+
+// etagMatches returns true if an If-None-Match header value matches an ETag
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if candidate = strings.TrimSpace(candidate); (candidate == "*") || (strings.TrimPrefix(candidate, "W/") == etag) {
+			return true
+		}
+	}
+
+	return false
+}
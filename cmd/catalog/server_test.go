@@ -0,0 +1,163 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testCatalog is the catalog served by the tests
+var testCatalog = &Catalog{
+	products: []Product{{Name: "Apples", Price: "1.50/lb"}, {Name: "Bananas", Price: "0.75/lb"}},
+	amounts:  []float64{1.50, 0.75},
+}
+
+// get sends a GET request to a server, and returns the response
+func get(t *testing.T, srv *server, target string) *http.Response {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+	return w.Result()
+}
+
+func TestServeProducts(t *testing.T) {
+	srv := newServer(testCatalog, time.Minute, Faults{}, 1)
+
+	resp := get(t, srv, "/products?maxPrice=1")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	var products []Product
+	if err := json.NewDecoder(resp.Body).Decode(&products); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []Product{{Name: "Bananas", Price: "0.75/lb"}}; !reflect.DeepEqual(products, want) {
+		t.Errorf("products %+v, want %+v", products, want)
+	}
+
+	if got := resp.Header.Get("Cache-Control"); got != "max-age=60" {
+		t.Errorf("Cache-Control %q, want max-age=60", got)
+	}
+}
+
+func TestServeFailures(t *testing.T) {
+	for _, test := range []struct {
+		status     int
+		retryAfter string
+	}{
+		{http.StatusServiceUnavailable, "1"},
+		{http.StatusTooManyRequests, "1"},
+		{http.StatusInternalServerError, ""},
+		{http.StatusBadRequest, ""},
+	} {
+		srv := newServer(testCatalog, time.Minute, Faults{FailureRate: 1, FailureStatus: test.status}, 1)
+
+		resp := get(t, srv, "/products")
+		if resp.StatusCode != test.status {
+			t.Errorf("status %d, want %d", resp.StatusCode, test.status)
+		}
+
+		if got := resp.Header.Get("Retry-After"); got != test.retryAfter {
+			t.Errorf("status %d: Retry-After %q, want %q", test.status, got, test.retryAfter)
+		}
+	}
+}
+
+func TestServeFailureRate(t *testing.T) {
+	const requests = 1000
+
+	for _, rate := range []float64{0, 0.2, 0.5} {
+		var (
+			faults   = Faults{FailureRate: rate, FailureStatus: http.StatusBadGateway}
+			srv      = newServer(testCatalog, time.Minute, faults, 1)
+			failures int
+		)
+
+		for i := 0; i < requests; i++ {
+			switch status := get(t, srv, "/products").StatusCode; status {
+			case http.StatusOK:
+			case http.StatusBadGateway:
+				failures++
+			default:
+				t.Fatalf("status %d", status)
+			}
+		}
+
+		// The failures are random, so allow for some variation around the rate
+		if got := float64(failures) / requests; (got < rate-0.05) || (got > rate+0.05) {
+			t.Errorf("failure rate %v, want about %v", got, rate)
+		}
+	}
+}
+
+func TestServeFailureSeed(t *testing.T) {
+	statuses := func(seed int64) []int {
+		srv := newServer(testCatalog, time.Minute, Faults{FailureRate: 0.5, FailureStatus: http.StatusBadGateway}, seed)
+
+		var statuses []int
+		for i := 0; i < 20; i++ {
+			statuses = append(statuses, get(t, srv, "/products").StatusCode)
+		}
+
+		return statuses
+	}
+
+	if first, second := statuses(42), statuses(42); !reflect.DeepEqual(first, second) {
+		t.Errorf("the same seed gave different failures: %v and %v", first, second)
+	}
+}
+
+func TestServeLatency(t *testing.T) {
+	const latency = 50 * time.Millisecond
+	srv := newServer(testCatalog, time.Minute, Faults{Latency: latency, Jitter: latency}, 1)
+
+	start := time.Now()
+	if status := get(t, srv, "/products").StatusCode; status != http.StatusOK {
+		t.Errorf("status %d, want %d", status, http.StatusOK)
+	}
+
+	if elapsed := time.Since(start); (elapsed < latency) || (elapsed > 10*latency) {
+		t.Errorf("response took %v, want at least %v", elapsed, latency)
+	}
+
+	// The delay ends early if the client gives up
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+
+	srv = newServer(testCatalog, time.Minute, Faults{Latency: time.Hour}, 1)
+	start = time.Now()
+	r := httptest.NewRequest(http.MethodGet, "/products", nil).WithContext(ctx)
+	if status := srv.serve(httptest.NewRecorder(), r); status != 499 {
+		t.Errorf("status %d, want 499", status)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("a cancelled request took %v", elapsed)
+	}
+}
+
+func TestServeOverHTTP(t *testing.T) {
+	faults := Faults{FailureRate: 1, FailureStatus: http.StatusServiceUnavailable}
+	ts := httptest.NewServer(newServer(testCatalog, time.Minute, faults, 1))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Command defines a command to perform
+type Command interface {
+	Perform()
+}
+
+// Processor processes one or more Command types, returning true if it processes a particular Command, false if it does not.
+type Processor interface {
+	Process(Command) bool
+}
+
+// FallibleProcessor is a Processor that can fail to process a Command it applies to.
+// TryProcess returns true if the processor applies to the Command, and an error if processing it failed.
+type FallibleProcessor interface {
+	Processor
+	TryProcess(Command) (bool, error)
+}
+
+// tryProcess processes a Command with a Processor, using TryProcess if the Processor is a FallibleProcessor.
+// A RoutedProcessor is not given a Command that does not match its Predicate.
+func tryProcess(p Processor, cmd Command) (bool, error) {
+	if !accepts(p, cmd) {
+		return false, nil
+	}
+
+	if fp, isa := p.(FallibleProcessor); isa {
+		return fp.TryProcess(cmd)
+	}
+
+	return p.Process(cmd), nil
+}
+
+// Mode is the way a Chain applies its Processors to a Command
+type Mode uint
+
+// Mode constants
+const (
+	// FirstMatch stops at the first Processor that processes the Command, even if it fails
+	FirstMatch Mode = iota
+	// AllMatch gives the Command to every Processor, collecting the errors of any that fail
+	AllMatch
+	// UntilError gives the Command to every Processor, stopping at the first one that fails
+	UntilError
+)
+
+var (
+	modeToString = map[Mode]string{
+		FirstMatch: "FirstMatch",
+		AllMatch:   "AllMatch",
+		UntilError: "UntilError",
+	}
+)
+
+// String is Mode Stringer
+func (m Mode) String() string {
+	return modeToString[m]
+}
+
+// ProcessorError is the error of a Processor that failed to process a Command
+type ProcessorError struct {
+	Processor Processor
+	Err       error
+}
+
+// Error is error for ProcessorError
+func (e ProcessorError) Error() string {
+	return fmt.Sprintf("%T: %s", e.Processor, e.Err)
+}
+
+// Unwrap returns the error of the Processor
+func (e ProcessorError) Unwrap() error {
+	return e.Err
+}
+
+// Result describes how a Chain applied a Command: the Processors that processed it, in order, and the errors of
+// those that failed, as ProcessorErrors. A Processor that failed is also in Handled.
+type Result struct {
+	Command Command
+	Handled []Processor
+	Errors  []error
+}
+
+// Matched returns true if any Processor processed the Command
+func (r Result) Matched() bool {
+	return len(r.Handled) > 0
+}
+
+// OK returns true if any Processor processed the Command, and none failed
+func (r Result) OK() bool {
+	return r.Matched() && (len(r.Errors) == 0)
+}
+
+// String is Result Stringer
+func (r Result) String() string {
+	if !r.Matched() {
+		return fmt.Sprintf("%T: no processor", r.Command)
+	}
+
+	handled := make([]string, len(r.Handled))
+	for i, p := range r.Handled {
+		handled[i] = fmt.Sprintf("%T", p)
+	}
+
+	str := fmt.Sprintf("%T: handled by %s", r.Command, strings.Join(handled, ", "))
+	if len(r.Errors) > 0 {
+		errs := make([]string, len(r.Errors))
+		for i, err := range r.Errors {
+			errs[i] = err.Error()
+		}
+
+		str += fmt.Sprintf(", errors: %s", strings.Join(errs, "; "))
+	}
+
+	return str
+}
+
+// Chain tries processing a Command with a series of Processors, in order of priority, highest first.
+// Processors with the same priority are in the order they were added.
+// A Processor registered with a Predicate is only given Commands that match it.
+// The Mode decides whether it stops at the first Processor that processes the Command (the default), gives it to
+// every Processor, or gives it to every Processor until one fails.
+type Chain struct {
+	routes []route
+	mode   Mode
+}
+
+// WithProcessors appends processors to the chain with priority 0, and no predicate
+func (ch *Chain) WithProcessors(processors ...Processor) *Chain {
+	for _, p := range processors {
+		ch.Register(p, 0, nil)
+	}
+
+	return ch
+}
+
+// Register adds a processor to the chain with a priority, and a predicate that a Command must match to be given to
+// the processor. A nil predicate gives the processor every Command.
+// The predicate of a RoutedProcessor is combined with the given one.
+func (ch *Chain) Register(p Processor, priority int, predicate Predicate) *Chain {
+	if rp, isa := p.(RoutedProcessor); isa {
+		if predicate == nil {
+			predicate = rp.Predicate()
+		} else {
+			predicate = All(rp.Predicate(), predicate)
+		}
+	}
+
+	ch.routes = append(ch.routes, route{processor: p, priority: priority, predicate: predicate})
+	sort.SliceStable(ch.routes, func(i, j int) bool { return ch.routes[i].priority > ch.routes[j].priority })
+
+	return ch
+}
+
+// WithMode sets the mode
+func (ch *Chain) WithMode(mode Mode) *Chain {
+	ch.mode = mode
+	return ch
+}
+
+// Apply gives the Command to the processors according to the mode, and returns the result
+func (ch Chain) Apply(cmd Command) Result {
+	result := Result{Command: cmd}
+
+	for _, r := range ch.routes {
+		if !r.matches(cmd) {
+			continue
+		}
+
+		p := r.processor
+		handled, err := tryProcess(p, cmd)
+		if !handled {
+			continue
+		}
+
+		result.Handled = append(result.Handled, p)
+		if err != nil {
+			result.Errors = append(result.Errors, ProcessorError{Processor: p, Err: err})
+		}
+
+		if (ch.mode == FirstMatch) || ((ch.mode == UntilError) && (err != nil)) {
+			break
+		}
+	}
+
+	return result
+}
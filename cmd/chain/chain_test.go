@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// Errors of test processors
+var (
+	errFirst  = fmt.Errorf("First failure")
+	errSecond = fmt.Errorf("Second failure")
+)
+
+// testProcessor is a FallibleProcessor that processes every Command unless it declines, failing with err if not nil
+type testProcessor struct {
+	name     string
+	declines bool
+	err      error
+}
+
+// Process is Processor for testProcessor
+func (p testProcessor) Process(cmd Command) bool {
+	handled, _ := p.TryProcess(cmd)
+	return handled
+}
+
+// TryProcess is FallibleProcessor for testProcessor
+func (p testProcessor) TryProcess(Command) (bool, error) {
+	return !p.declines, p.err
+}
+
+// testCommand is a Command that does nothing
+type testCommand struct{}
+
+// Perform is Command for testCommand
+func (testCommand) Perform() {}
+
+// names returns the names of test processors
+func names(processors []Processor) []string {
+	var names []string
+	for _, p := range processors {
+		names = append(names, p.(testProcessor).name)
+	}
+
+	return names
+}
+
+func TestChainModes(t *testing.T) {
+	var (
+		declines = testProcessor{name: "declines", declines: true}
+		succeeds = testProcessor{name: "succeeds"}
+		first    = testProcessor{name: "first", err: errFirst}
+		second   = testProcessor{name: "second", err: errSecond}
+		last     = testProcessor{name: "last"}
+	)
+
+	for _, test := range []struct {
+		mode       Mode
+		processors []Processor
+		handled    []string
+		errs       []error
+	}{
+		{FirstMatch, nil, nil, nil},
+		{FirstMatch, []Processor{declines}, nil, nil},
+		{FirstMatch, []Processor{declines, succeeds, last}, []string{"succeeds"}, nil},
+		{FirstMatch, []Processor{declines, first, last}, []string{"first"}, []error{errFirst}},
+		{AllMatch, []Processor{declines}, nil, nil},
+		{AllMatch, []Processor{succeeds, declines, last}, []string{"succeeds", "last"}, nil},
+		{
+			AllMatch,
+			[]Processor{first, declines, succeeds, second, last},
+			[]string{"first", "succeeds", "second", "last"},
+			[]error{errFirst, errSecond},
+		},
+		{UntilError, []Processor{declines}, nil, nil},
+		{UntilError, []Processor{succeeds, declines, last}, []string{"succeeds", "last"}, nil},
+		{UntilError, []Processor{succeeds, first, second, last}, []string{"succeeds", "first"}, []error{errFirst}},
+	} {
+		t.Run(fmt.Sprintf("%s %v", test.mode, names(test.processors)), func(t *testing.T) {
+			result := (&Chain{}).WithMode(test.mode).WithProcessors(test.processors...).Apply(testCommand{})
+
+			if got := names(result.Handled); !reflect.DeepEqual(got, test.handled) {
+				t.Errorf("handled by %v, want %v", got, test.handled)
+			}
+
+			if len(result.Errors) != len(test.errs) {
+				t.Fatalf("errors %v, want %v", result.Errors, test.errs)
+			}
+
+			for i, err := range result.Errors {
+				var processorErr ProcessorError
+				if !errors.As(err, &processorErr) {
+					t.Fatalf("error %v is not a ProcessorError", err)
+				}
+
+				// The error wraps the error of the processor, which is also in Handled
+				if !errors.Is(err, test.errs[i]) || (errors.Unwrap(err) != test.errs[i]) {
+					t.Errorf("error %v does not wrap %v", err, test.errs[i])
+				}
+
+				if p := processorErr.Processor; (p != first) && (p != second) {
+					t.Errorf("error of processor %v, want a failing processor", p)
+				}
+			}
+
+			if (result.Matched() != (len(test.handled) > 0)) || (result.OK() != (result.Matched() && (len(test.errs) == 0))) {
+				t.Errorf("Matched %v, OK %v, for %v", result.Matched(), result.OK(), result)
+			}
+		})
+	}
+}
+
+func TestChainPriority(t *testing.T) {
+	c := (&Chain{}).
+		WithMode(AllMatch).
+		Register(testProcessor{name: "low"}, -1, nil).
+		Register(testProcessor{name: "default"}, 0, nil).
+		Register(testProcessor{name: "high"}, 10, nil).
+		Register(testProcessor{name: "also default"}, 0, nil).
+		Register(testProcessor{name: "not routed"}, 20, Not(ForType(testCommand{})))
+
+	// Higher priorities are first, and equal priorities are in the order they were registered
+	want := []string{"high", "default", "also default", "low"}
+	if got := names(c.Apply(testCommand{}).Handled); !reflect.DeepEqual(got, want) {
+		t.Errorf("handled by %v, want %v", got, want)
+	}
+}
+
+func TestResultString(t *testing.T) {
+	for _, test := range []struct {
+		result Result
+		want   string
+	}{
+		{Result{Command: testCommand{}}, "main.testCommand: no processor"},
+		{
+			Result{Command: testCommand{}, Handled: []Processor{testProcessor{}, AnyProcessor{}}},
+			"main.testCommand: handled by main.testProcessor, main.AnyProcessor",
+		},
+		{
+			Result{
+				Command: testCommand{},
+				Handled: []Processor{testProcessor{}},
+				Errors:  []error{ProcessorError{Processor: testProcessor{}, Err: errFirst}},
+			},
+			"main.testCommand: handled by main.testProcessor, errors: main.testProcessor: First failure",
+		},
+	} {
+		if got := test.result.String(); got != test.want {
+			t.Errorf("%q, want %q", got, test.want)
+		}
+	}
+}
@@ -3,22 +3,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"strings"
 )
 
-// Command defines a command to perform
-type Command interface {
-	Perform()
-}
-
 // PrintCommand prints to the console
 type PrintCommand struct {
-	msg string
+	Msg string
 }
 
 // Perform is Command for PrintCommand
 func (p PrintCommand) Perform() {
-	fmt.Println(p.msg)
+	fmt.Println(p.Msg)
 }
 
 // BeepCommand beeps
@@ -29,9 +26,25 @@ func (p BeepCommand) Perform() {
 	fmt.Println("Beep!")
 }
 
-// Processor processes one or more Command types, returning true if it processes a particular Command, false if it does not.
-type Processor interface {
-	Process(Command) bool
+// AlertCommand is an alert with a level
+type AlertCommand struct {
+	Level string
+	Msg   string
+}
+
+// Perform is Command for AlertCommand
+func (a AlertCommand) Perform() {
+	fmt.Printf("Alert (%s): %s\n", a.Level, a.Msg)
+}
+
+// PagerProcessor pages whoever is on call with any Command, which is routed to it with a Predicate
+type PagerProcessor struct{}
+
+// Process is Processor for PagerProcessor
+func (p PagerProcessor) Process(c Command) bool {
+	fmt.Print("Paging: ")
+	c.Perform()
+	return true
 }
 
 // AnyProcessor processes any Command
@@ -43,55 +56,151 @@ func (p AnyProcessor) Process(c Command) bool {
 	return true
 }
 
-// PrintProcessor is Processor for PrintCommand only
+// PrintProcessor is RoutedProcessor for PrintCommand only
 type PrintProcessor struct{}
 
-// Process is Processor for PrintProcessor
+// Predicate is RoutedProcessor for PrintProcessor
+func (p PrintProcessor) Predicate() Predicate {
+	return ForType(PrintCommand{})
+}
+
+// Process is Processor for PrintProcessor, which is only given PrintCommands
 func (p PrintProcessor) Process(c Command) bool {
-	if pc, isa := c.(PrintCommand); isa {
-		pc.Perform()
-		return true
+	c.Perform()
+	return true
+}
+
+// ErrMuted is the error of a SpeakerProcessor that is muted
+var ErrMuted = fmt.Errorf("Speaker is muted")
+
+// SpeakerProcessor is FallibleProcessor for BeepCommand only, which fails if the speaker is muted
+type SpeakerProcessor struct {
+	muted bool
+}
+
+// Process is Processor for SpeakerProcessor
+func (p SpeakerProcessor) Process(c Command) bool {
+	handled, _ := p.TryProcess(c)
+	return handled
+}
+
+// TryProcess is FallibleProcessor for SpeakerProcessor
+func (p SpeakerProcessor) TryProcess(c Command) (bool, error) {
+	bc, isa := c.(BeepCommand)
+	if !isa {
+		return false, nil
+	}
+
+	if p.muted {
+		return true, ErrMuted
 	}
 
-	return false
+	bc.Perform()
+	return true, nil
 }
 
-// Chain tries processing a Command with a series of Processors, and stops at the first Processor that indicates it processed the Command.
-type Chain struct {
-	processors []Processor
+// quietKey is the context key for quiet hours
+type quietKey struct{}
+
+// logHandler logs each Command before passing it on, and the Result after
+func logHandler(ctx context.Context, cmd Command, next NextFunc) (Result, error) {
+	fmt.Printf("  log: %T %+v\n", cmd, cmd)
+	result, err := next(ctx, cmd)
+	fmt.Printf("  log: %v, err = %v\n", result, err)
+
+	return result, err
 }
 
-// Apply tries each processor until it finds one that applies, if any
-func (ch Chain) Apply(cmd Command) {
-	fmt.Printf("Attempting to find a processor for Command type %T\n", cmd)
+// shoutHandler upper cases the message of a PrintCommand
+func shoutHandler(ctx context.Context, cmd Command, next NextFunc) (Result, error) {
+	if pc, isa := cmd.(PrintCommand); isa {
+		cmd = PrintCommand{Msg: strings.ToUpper(pc.Msg)}
+	}
 
-	for _, p := range ch.processors {
-		if p.Process(cmd) {
-			return
-		}
+	return next(ctx, cmd)
+}
+
+// quietHandler swallows BeepCommands during quiet hours, which are set in the context
+func quietHandler(ctx context.Context, cmd Command, next NextFunc) (Result, error) {
+	if _, isa := cmd.(BeepCommand); isa && (ctx.Value(quietKey{}) == true) {
+		fmt.Println("  quiet: no beeping")
+		return Result{Command: cmd}, nil
 	}
 
-	fmt.Println("Unable to find a processor")
+	return next(ctx, cmd)
 }
 
 func main() {
 	var (
-		pc = PrintCommand{msg: "Hello, World"}
+		pc = PrintCommand{Msg: "Hello, World"}
 		bc = BeepCommand{}
-		c  = Chain{
-			processors: []Processor{
-				PrintProcessor{},
-			},
-		}
+		c  = (&Chain{}).WithProcessors(PrintProcessor{})
 	)
 
 	// Will not process BeepCommand, only PrintCommand
-	c.Apply(pc)
-	c.Apply(bc)
+	fmt.Println(c.Apply(pc))
+	fmt.Println(c.Apply(bc))
 
-	c.processors = append(c.processors, AnyProcessor{})
+	c.WithProcessors(AnyProcessor{})
 
 	// Will process both commands
-	c.Apply(pc)
-	c.Apply(bc)
+	fmt.Println(c.Apply(pc))
+	fmt.Println(c.Apply(bc))
+
+	// A muted speaker fails to beep
+	for _, mode := range []Mode{FirstMatch, AllMatch, UntilError} {
+		fmt.Printf("\n%s:\n", mode)
+		c = (&Chain{}).WithMode(mode).WithProcessors(PrintProcessor{}, SpeakerProcessor{muted: true}, AnyProcessor{})
+
+		fmt.Println(c.Apply(pc))
+		result := c.Apply(bc)
+		fmt.Println(result)
+		fmt.Println("ok:", result.OK())
+	}
+
+	// Route commands by priority and predicate
+	fmt.Println("\nRouting:")
+	c = (&Chain{}).
+		Register(AnyProcessor{}, 0, nil).
+		Register(PagerProcessor{}, 20, All(ForType(AlertCommand{}), FieldEquals("Level", "critical"))).
+		Register(PrintProcessor{}, 10, Not(FieldEquals("Msg", "")))
+
+	for _, cmd := range []Command{pc, PrintCommand{}, AlertCommand{Level: "critical", Msg: "Disk full"}, AlertCommand{Level: "info", Msg: "Disk cleaned"}} {
+		fmt.Println(c.Explain(cmd))
+		fmt.Println(c.Apply(cmd))
+	}
+
+	fmt.Println(c.WithMode(AllMatch).Explain(AlertCommand{Level: "critical", Msg: "Disk full"}))
+
+	// A pipeline of middleware, ending with processors
+	fmt.Println("\nPipeline:")
+	pl := &Pipeline{}
+	pl.Use("log", HandlerFunc(logHandler))
+	pl.Use("quiet", HandlerFunc(quietHandler))
+	pl.Use("print", ProcessorHandler(PrintProcessor{}))
+	pl.Use("speaker", ProcessorHandler(SpeakerProcessor{}))
+
+	run := func(ctx context.Context, cmd Command) {
+		if _, err := pl.Run(ctx, cmd); err != nil {
+			fmt.Println("error:", err)
+		}
+	}
+	run(context.Background(), pc)
+	run(context.Background(), bc)
+	run(context.WithValue(context.Background(), quietKey{}, true), bc)
+
+	// Insert and remove handlers while running
+	pl.InsertAfter("log", "shout", HandlerFunc(shoutHandler))
+	fmt.Println(pl.Names())
+	run(context.Background(), pc)
+
+	pl.Remove("shout")
+	fmt.Println(pl.Names())
+	fmt.Println(pl.InsertBefore("shout", "shout", HandlerFunc(shoutHandler)))
+	fmt.Println(pl.Use("log", HandlerFunc(logHandler)))
+
+	// A cancelled context stops the pipeline
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	run(ctx, pc)
 }
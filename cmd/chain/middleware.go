@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrNoSuchHandler is the error returned when there is no handler with the requested name
+	ErrNoSuchHandler = fmt.Errorf("No such handler")
+	// ErrDuplicateHandler is the error returned when adding a handler with the name of an existing handler
+	ErrDuplicateHandler = fmt.Errorf("Duplicate handler")
+)
+
+// NextFunc passes a Command to the rest of a Pipeline
+type NextFunc func(ctx context.Context, cmd Command) (Result, error)
+
+// Handler is a middleware step of a Pipeline. It can do something before calling next, modify the Command or context
+// it passes to next, not call next at all to short-circuit the rest of the Pipeline, and do something with the Result
+// after next returns.
+//
+// The Result describes the Processors that handled the Command, as for a Chain. The error is for a Handler that
+// could not do its job, such as a context that is done, and stops the Pipeline.
+type Handler interface {
+	Handle(ctx context.Context, cmd Command, next NextFunc) (Result, error)
+}
+
+// HandlerFunc is a func adapter for Handler
+type HandlerFunc func(ctx context.Context, cmd Command, next NextFunc) (Result, error)
+
+// Handle is Handler for HandlerFunc
+func (f HandlerFunc) Handle(ctx context.Context, cmd Command, next NextFunc) (Result, error) {
+	return f(ctx, cmd, next)
+}
+
+// processorHandler adapts a Processor to a Handler
+type processorHandler struct {
+	processor Processor
+}
+
+// ProcessorHandler adapts a Processor to a Handler that short-circuits the Pipeline if the Processor processes the
+// Command, and otherwise calls next. The error of a FallibleProcessor is in the Result Errors, as for a Chain.
+func ProcessorHandler(p Processor) Handler {
+	return processorHandler{processor: p}
+}
+
+// Handle is Handler for processorHandler
+func (h processorHandler) Handle(ctx context.Context, cmd Command, next NextFunc) (Result, error) {
+	handled, err := tryProcess(h.processor, cmd)
+	if !handled {
+		return next(ctx, cmd)
+	}
+
+	result := Result{Command: cmd, Handled: []Processor{h.processor}}
+	if err != nil {
+		result.Errors = []error{ProcessorError{Processor: h.processor, Err: err}}
+	}
+
+	return result, nil
+}
+
+// namedHandler is a Handler with a name
+type namedHandler struct {
+	name    string
+	handler Handler
+}
+
+// Pipeline passes a Command through a series of named Handlers, in order, each of which decides whether to call the
+// next. Handlers can be added, inserted and removed while the Pipeline is in use: a Command that is being handled
+// continues with the Handlers there were when it started.
+//
+// It is safe for concurrent use, and must be used by pointer.
+type Pipeline struct {
+	mu       sync.RWMutex
+	handlers []namedHandler
+}
+
+// indexOf returns the index of the handler with the given name, or -1 if there is none.
+// Must be called with the mutex held.
+func (pl *Pipeline) indexOf(name string) int {
+	for i, nh := range pl.handlers {
+		if nh.name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// insert inserts a handler at an index.
+// Must be called with the mutex held.
+func (pl *Pipeline) insert(i int, name string, h Handler) error {
+	if pl.indexOf(name) >= 0 {
+		return fmt.Errorf("%w: %q", ErrDuplicateHandler, name)
+	}
+
+	handlers := make([]namedHandler, 0, len(pl.handlers)+1)
+	handlers = append(handlers, pl.handlers[:i]...)
+	handlers = append(handlers, namedHandler{name: name, handler: h})
+	pl.handlers = append(handlers, pl.handlers[i:]...)
+
+	return nil
+}
+
+// Use adds a handler to the end of the pipeline
+func (pl *Pipeline) Use(name string, h Handler) error {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	return pl.insert(len(pl.handlers), name, h)
+}
+
+// InsertBefore inserts a handler before the handler with the given name
+func (pl *Pipeline) InsertBefore(before, name string, h Handler) error {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	i := pl.indexOf(before)
+	if i < 0 {
+		return fmt.Errorf("%w: %q", ErrNoSuchHandler, before)
+	}
+
+	return pl.insert(i, name, h)
+}
+
+// InsertAfter inserts a handler after the handler with the given name
+func (pl *Pipeline) InsertAfter(after, name string, h Handler) error {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	i := pl.indexOf(after)
+	if i < 0 {
+		return fmt.Errorf("%w: %q", ErrNoSuchHandler, after)
+	}
+
+	return pl.insert(i+1, name, h)
+}
+
+// Remove removes the handler with the given name
+func (pl *Pipeline) Remove(name string) error {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	i := pl.indexOf(name)
+	if i < 0 {
+		return fmt.Errorf("%w: %q", ErrNoSuchHandler, name)
+	}
+
+	pl.handlers = append(pl.handlers[:i:i], pl.handlers[i+1:]...)
+	return nil
+}
+
+// Names returns the names of the handlers, in order
+func (pl *Pipeline) Names() []string {
+	pl.mu.RLock()
+	defer pl.mu.RUnlock()
+
+	names := make([]string, len(pl.handlers))
+	for i, nh := range pl.handlers {
+		names[i] = nh.name
+	}
+
+	return names
+}
+
+// Run passes a Command to the first handler. If every handler calls next, the Result has no Processors.
+// The Pipeline stops with the context error if the context is done before a handler is called.
+func (pl *Pipeline) Run(ctx context.Context, cmd Command) (Result, error) {
+	pl.mu.RLock()
+	handlers := pl.handlers
+	pl.mu.RUnlock()
+
+	var next func(i int) NextFunc
+	next = func(i int) NextFunc {
+		return func(ctx context.Context, cmd Command) (Result, error) {
+			if err := ctx.Err(); err != nil {
+				return Result{Command: cmd}, err
+			}
+
+			if i == len(handlers) {
+				return Result{Command: cmd}, nil
+			}
+
+			return handlers[i].handler.Handle(ctx, cmd, next(i+1))
+		}
+	}
+
+	return next(0)(ctx, cmd)
+}
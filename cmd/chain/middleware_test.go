@@ -0,0 +1,281 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// callLog records the handlers that are called, in order
+type callLog struct {
+	mu    sync.Mutex
+	names []string
+}
+
+// handler returns a Handler that records its name and calls next
+func (l *callLog) handler(name string) Handler {
+	return HandlerFunc(func(ctx context.Context, cmd Command, next NextFunc) (Result, error) {
+		l.mu.Lock()
+		l.names = append(l.names, name)
+		l.mu.Unlock()
+
+		return next(ctx, cmd)
+	})
+}
+
+// take returns the names that were recorded, and clears them
+func (l *callLog) take() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	names := l.names
+	l.names = nil
+
+	return names
+}
+
+// mustRun runs a Command through a Pipeline, and fails if it returns an error
+func mustRun(t *testing.T, pl *Pipeline) Result {
+	t.Helper()
+
+	result, err := pl.Run(context.Background(), testCommand{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+func TestPipelineEdits(t *testing.T) {
+	var (
+		log callLog
+		pl  = &Pipeline{}
+	)
+
+	for _, err := range []error{
+		pl.Use("a", log.handler("a")),
+		pl.Use("c", log.handler("c")),
+		pl.InsertBefore("c", "b", log.handler("b")),
+		pl.InsertAfter("c", "d", log.handler("d")),
+		pl.InsertBefore("a", "first", log.handler("first")),
+		pl.InsertAfter("d", "last", log.handler("last")),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{"first", "a", "b", "c", "d", "last"}
+	if got := pl.Names(); !reflect.DeepEqual(got, want) {
+		t.Errorf("names %v, want %v", got, want)
+	}
+
+	if result := mustRun(t, pl); result.Matched() {
+		t.Errorf("result %v, want no processor", result)
+	}
+	if got := log.take(); !reflect.DeepEqual(got, want) {
+		t.Errorf("called %v, want %v", got, want)
+	}
+
+	for _, name := range []string{"b", "first", "last"} {
+		if err := pl.Remove(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want = []string{"a", "c", "d"}
+	mustRun(t, pl)
+	if got := log.take(); !reflect.DeepEqual(got, want) {
+		t.Errorf("called %v after removing handlers, want %v", got, want)
+	}
+}
+
+func TestPipelineEditErrors(t *testing.T) {
+	var (
+		log callLog
+		pl  = &Pipeline{}
+	)
+	pl.Use("a", log.handler("a"))
+
+	for _, test := range []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"use duplicate", pl.Use("a", log.handler("a")), ErrDuplicateHandler},
+		{"insert duplicate before", pl.InsertBefore("a", "a", log.handler("a")), ErrDuplicateHandler},
+		{"insert duplicate after", pl.InsertAfter("a", "a", log.handler("a")), ErrDuplicateHandler},
+		{"insert before missing", pl.InsertBefore("missing", "b", log.handler("b")), ErrNoSuchHandler},
+		{"insert after missing", pl.InsertAfter("missing", "b", log.handler("b")), ErrNoSuchHandler},
+		{"remove missing", pl.Remove("missing"), ErrNoSuchHandler},
+	} {
+		if !errors.Is(test.err, test.want) {
+			t.Errorf("%s: err = %v, want %v", test.name, test.err, test.want)
+		}
+	}
+
+	if got := pl.Names(); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("names %v after failed edits, want [a]", got)
+	}
+}
+
+func TestPipelineShortCircuit(t *testing.T) {
+	var (
+		log      callLog
+		pl       = &Pipeline{}
+		declines = testProcessor{name: "declines", declines: true}
+		fails    = testProcessor{name: "fails", err: errFirst}
+		after    Result
+	)
+
+	// The first handler sees the result of the rest of the pipeline
+	pl.Use("outer", HandlerFunc(func(ctx context.Context, cmd Command, next NextFunc) (Result, error) {
+		result, err := next(ctx, cmd)
+		after = result
+		return result, err
+	}))
+	pl.Use("a", log.handler("a"))
+	pl.Use("declines", ProcessorHandler(declines))
+	pl.Use("b", log.handler("b"))
+	pl.Use("fails", ProcessorHandler(fails))
+	pl.Use("c", log.handler("c"))
+
+	result := mustRun(t, pl)
+	if got := log.take(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("called %v, want [a b]", got)
+	}
+
+	if !reflect.DeepEqual(result.Handled, []Processor{fails}) || (len(result.Errors) != 1) ||
+		!errors.Is(result.Errors[0], errFirst) {
+		t.Errorf("result %v, want handled by fails with its error", result)
+	}
+
+	if !reflect.DeepEqual(after, result) {
+		t.Errorf("outer handler saw %v, want %v", after, result)
+	}
+
+	// A handler that does not call next stops the pipeline without a processor
+	pl.InsertBefore("a", "stop", HandlerFunc(func(ctx context.Context, cmd Command, next NextFunc) (Result, error) {
+		return Result{Command: cmd}, nil
+	}))
+	if result := mustRun(t, pl); result.Matched() || (len(log.take()) != 0) {
+		t.Errorf("result %v, want the pipeline stopped", result)
+	}
+}
+
+func TestPipelineContext(t *testing.T) {
+	var (
+		log callLog
+		pl  = &Pipeline{}
+	)
+	pl.Use("a", log.handler("a"))
+	pl.Use("cancel", HandlerFunc(func(ctx context.Context, cmd Command, next NextFunc) (Result, error) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		return next(ctx, cmd)
+	}))
+	pl.Use("b", log.handler("b"))
+
+	// The pipeline stops before the next handler once the context is done
+	if _, err := pl.Run(context.Background(), testCommand{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+	if got := log.take(); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("called %v, want [a]", got)
+	}
+
+	// No handler is called with a context that is already done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := pl.Run(ctx, testCommand{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+	if got := log.take(); len(got) != 0 {
+		t.Errorf("called %v with a done context", got)
+	}
+}
+
+func TestPipelineEditWhileRunning(t *testing.T) {
+	var (
+		log callLog
+		pl  = &Pipeline{}
+	)
+
+	// The first handler edits the pipeline while the command is being handled
+	pl.Use("edit", HandlerFunc(func(ctx context.Context, cmd Command, next NextFunc) (Result, error) {
+		if err := pl.Remove("a"); err == nil {
+			pl.Use("b", log.handler("b"))
+		}
+
+		return next(ctx, cmd)
+	}))
+	pl.Use("a", log.handler("a"))
+
+	// A running command keeps the handlers there were when it started
+	mustRun(t, pl)
+	if got := log.take(); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("called %v, want [a]", got)
+	}
+
+	mustRun(t, pl)
+	if got := log.take(); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("called %v, want [b]", got)
+	}
+}
+
+func TestPipelineConcurrent(t *testing.T) {
+	var (
+		log  callLog
+		pl   = &Pipeline{}
+		wg   sync.WaitGroup
+		done = make(chan struct{})
+	)
+	pl.Use("first", log.handler("first"))
+	pl.Use("last", log.handler("last"))
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				if _, err := pl.Run(context.Background(), testCommand{}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+
+	// Edit the pipeline while commands run through it, which the race detector checks
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprint(i)
+		if err := pl.InsertAfter("first", name, log.handler(name)); err != nil {
+			t.Fatal(err)
+		}
+
+		if i%2 == 0 {
+			if err := pl.Remove(name); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if names := pl.Names(); (len(names) != 52) || (names[0] != "first") || (names[51] != "last") {
+		t.Errorf("names %v, want first, the odd numbers and last", names)
+	}
+}
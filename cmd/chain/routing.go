@@ -0,0 +1,277 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Predicate decides which Commands a Processor is given.
+// String describes the Predicate, to explain why a Command is or is not given to a Processor.
+type Predicate interface {
+	Matches(Command) bool
+	String() string
+}
+
+// typePredicate matches Commands of one type
+type typePredicate struct {
+	typ reflect.Type
+}
+
+// ForType returns a Predicate that matches Commands of the same type as the given Command
+func ForType(cmd Command) Predicate {
+	return typePredicate{typ: reflect.TypeOf(cmd)}
+}
+
+// Matches is Predicate for typePredicate
+func (p typePredicate) Matches(cmd Command) bool {
+	return reflect.TypeOf(cmd) == p.typ
+}
+
+// String is Stringer for typePredicate
+func (p typePredicate) String() string {
+	return fmt.Sprintf("type is %s", p.typ)
+}
+
+// fieldPredicate matches Commands with a field that has a value
+type fieldPredicate struct {
+	field string
+	value interface{}
+}
+
+// FieldEquals returns a Predicate that matches Commands that are structs, or pointers to structs, with an exported
+// field of the given name that is equal to the given value
+func FieldEquals(field string, value interface{}) Predicate {
+	return fieldPredicate{field: field, value: value}
+}
+
+// Matches is Predicate for fieldPredicate
+func (p fieldPredicate) Matches(cmd Command) bool {
+	v := reflect.ValueOf(cmd)
+	if (v.Kind() == reflect.Ptr) && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return false
+	}
+
+	f := v.FieldByName(p.field)
+	return f.IsValid() && f.CanInterface() && reflect.DeepEqual(f.Interface(), p.value)
+}
+
+// String is Stringer for fieldPredicate
+func (p fieldPredicate) String() string {
+	return fmt.Sprintf("%s == %#v", p.field, p.value)
+}
+
+// funcPredicate matches Commands for which a func returns true
+type funcPredicate struct {
+	description string
+	fn          func(Command) bool
+}
+
+// Where returns a Predicate that matches Commands for which a func returns true, described by a description
+func Where(description string, fn func(Command) bool) Predicate {
+	return funcPredicate{description: description, fn: fn}
+}
+
+// Matches is Predicate for funcPredicate
+func (p funcPredicate) Matches(cmd Command) bool {
+	return p.fn(cmd)
+}
+
+// String is Stringer for funcPredicate
+func (p funcPredicate) String() string {
+	return p.description
+}
+
+// allPredicate matches Commands that match every one of its Predicates
+type allPredicate struct {
+	predicates []Predicate
+}
+
+// All returns a Predicate that matches Commands that match every one of the given Predicates
+func All(predicates ...Predicate) Predicate {
+	return allPredicate{predicates: predicates}
+}
+
+// Matches is Predicate for allPredicate
+func (p allPredicate) Matches(cmd Command) bool {
+	for _, pred := range p.predicates {
+		if !pred.Matches(cmd) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String is Stringer for allPredicate
+func (p allPredicate) String() string {
+	strs := make([]string, len(p.predicates))
+	for i, pred := range p.predicates {
+		strs[i] = pred.String()
+	}
+
+	return strings.Join(strs, " and ")
+}
+
+// notPredicate matches Commands that do not match its Predicate
+type notPredicate struct {
+	predicate Predicate
+}
+
+// Not returns a Predicate that matches Commands that do not match the given Predicate
+func Not(predicate Predicate) Predicate {
+	return notPredicate{predicate: predicate}
+}
+
+// Matches is Predicate for notPredicate
+func (p notPredicate) Matches(cmd Command) bool {
+	return !p.predicate.Matches(cmd)
+}
+
+// String is Stringer for notPredicate
+func (p notPredicate) String() string {
+	return "not (" + p.predicate.String() + ")"
+}
+
+// RoutedProcessor is a Processor that only processes the Commands that match its own Predicate, such as Commands of
+// one type, so that its Process method does not need to check them.
+// A Chain or Pipeline only gives it those Commands, in addition to any Predicate it is registered with.
+type RoutedProcessor interface {
+	Processor
+	Predicate() Predicate
+}
+
+// accepts returns true if a Processor is not a RoutedProcessor, or the Command matches its Predicate
+func accepts(p Processor, cmd Command) bool {
+	rp, isa := p.(RoutedProcessor)
+	return !isa || rp.Predicate().Matches(cmd)
+}
+
+// route is a Processor registered with a Chain
+type route struct {
+	processor Processor
+	priority  int
+	predicate Predicate
+}
+
+// matches returns true if the route has no predicate, or the Command matches it
+func (r route) matches(cmd Command) bool {
+	return (r.predicate == nil) || r.predicate.Matches(cmd)
+}
+
+// Verdict is whether a Chain would give a Command to a Processor
+type Verdict uint
+
+// Verdict constants
+const (
+	// Skipped means the Command does not match the predicate
+	Skipped Verdict = iota
+	// Selected means the Command matches the predicate
+	Selected
+	// Undecided means there is no predicate, so only the Processor can decide if it processes the Command
+	Undecided
+)
+
+var (
+	verdictToString = map[Verdict]string{
+		Skipped:   "skipped",
+		Selected:  "selected",
+		Undecided: "undecided",
+	}
+)
+
+// String is Verdict Stringer
+func (v Verdict) String() string {
+	return verdictToString[v]
+}
+
+// Step explains what a Chain would do with a Command for one Processor.
+// In FirstMatch mode, a Processor after one that is selected or undecided is only given the Command if the earlier
+// ones do not process it: DeclinedBy lists them.
+type Step struct {
+	Processor  Processor
+	Priority   int
+	Verdict    Verdict
+	Reason     string
+	DeclinedBy []Processor
+}
+
+// Explanation explains what a Chain would do with a Command, without processing it
+type Explanation struct {
+	Command Command
+	Mode    Mode
+	Steps   []Step
+}
+
+// Handlers returns the Processors that are selected for the Command, in the order they would be given it, ignoring
+// those that are undecided. In FirstMatch mode, each one is only given the Command if the earlier ones decline it.
+func (e Explanation) Handlers() []Processor {
+	var handlers []Processor
+	for _, step := range e.Steps {
+		if step.Verdict == Selected {
+			handlers = append(handlers, step.Processor)
+		}
+	}
+
+	return handlers
+}
+
+// String is Explanation Stringer
+func (e Explanation) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%T in %s mode:", e.Command, e.Mode)
+	for _, step := range e.Steps {
+		fmt.Fprintf(&sb, "\n  %T (priority %d): %s, %s", step.Processor, step.Priority, step.Verdict, step.Reason)
+
+		if len(step.DeclinedBy) > 0 {
+			declinedBy := make([]string, len(step.DeclinedBy))
+			for i, p := range step.DeclinedBy {
+				declinedBy[i] = fmt.Sprintf("%T", p)
+			}
+
+			fmt.Fprintf(&sb, ", if declined by %s", strings.Join(declinedBy, " and "))
+		}
+	}
+
+	return sb.String()
+}
+
+// Explain returns what Apply would do with a Command, without processing it.
+// The predicates decide which processors are selected. Processors without a predicate are undecided, as they decide for
+// themselves when processing a Command. A selected processor can also decline a Command, so in FirstMatch mode the
+// processors after a selected or undecided one are explained as well, as they are given the Command if the earlier
+// ones decline it.
+func (ch Chain) Explain(cmd Command) Explanation {
+	explanation := Explanation{Command: cmd, Mode: ch.mode}
+
+	var earlier []Processor
+	for _, r := range ch.routes {
+		step := Step{Processor: r.processor, Priority: r.priority}
+
+		switch {
+		case r.predicate == nil:
+			step.Verdict, step.Reason = Undecided, "no predicate, the processor decides"
+
+		case r.predicate.Matches(cmd):
+			step.Verdict, step.Reason = Selected, fmt.Sprintf("matches %s", r.predicate)
+
+		default:
+			step.Verdict, step.Reason = Skipped, fmt.Sprintf("does not match %s", r.predicate)
+		}
+
+		if (step.Verdict != Skipped) && (ch.mode == FirstMatch) {
+			step.DeclinedBy = append([]Processor(nil), earlier...)
+			earlier = append(earlier, r.processor)
+		}
+
+		explanation.Steps = append(explanation.Steps, step)
+	}
+
+	return explanation
+}
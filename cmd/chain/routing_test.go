@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// decliningProcessor is selected for every Command by its Predicate, but declines to process any of them
+type decliningProcessor struct{}
+
+// Predicate is RoutedProcessor for decliningProcessor
+func (p decliningProcessor) Predicate() Predicate {
+	return Where("any command", func(Command) bool { return true })
+}
+
+// Process is Processor for decliningProcessor
+func (p decliningProcessor) Process(Command) bool {
+	return false
+}
+
+// verdicts returns the verdict of each step of an Explanation
+func verdicts(e Explanation) []Verdict {
+	vs := make([]Verdict, len(e.Steps))
+	for i, step := range e.Steps {
+		vs[i] = step.Verdict
+	}
+
+	return vs
+}
+
+func TestRoutedProcessor(t *testing.T) {
+	c := (&Chain{}).WithProcessors(PrintProcessor{})
+
+	if result := c.Apply(BeepCommand{}); result.Matched() {
+		t.Errorf("PrintProcessor processed a BeepCommand: %v", result)
+	}
+
+	if result := c.Apply(PrintCommand{Msg: "hello"}); !result.OK() {
+		t.Errorf("PrintProcessor did not process a PrintCommand: %v", result)
+	}
+
+	// The predicate of the processor is combined with the one it is registered with
+	c = (&Chain{}).Register(PrintProcessor{}, 0, Not(FieldEquals("Msg", "")))
+	if result := c.Apply(PrintCommand{}); result.Matched() {
+		t.Errorf("PrintProcessor processed an empty PrintCommand: %v", result)
+	}
+
+	// A pipeline does not give the processor other commands either
+	pl := &Pipeline{}
+	pl.Use("print", ProcessorHandler(PrintProcessor{}))
+	if result, err := pl.Run(context.Background(), BeepCommand{}); (err != nil) || result.Matched() {
+		t.Errorf("pipeline gave PrintProcessor a BeepCommand: %v, %v", result, err)
+	}
+}
+
+func TestExplainFallThrough(t *testing.T) {
+	c := (&Chain{}).
+		Register(decliningProcessor{}, 20, nil).
+		Register(PagerProcessor{}, 10, ForType(AlertCommand{})).
+		Register(PrintProcessor{}, 5, nil).
+		Register(AnyProcessor{}, 0, nil)
+
+	cmd := AlertCommand{Level: "info", Msg: "Disk cleaned"}
+	explanation := c.Explain(cmd)
+
+	if got, want := verdicts(explanation), []Verdict{Selected, Selected, Skipped, Undecided}; !reflect.DeepEqual(got, want) {
+		t.Errorf("verdicts %v, want %v", got, want)
+	}
+
+	for i, want := range [][]Processor{
+		nil,
+		{decliningProcessor{}},
+		nil,
+		{decliningProcessor{}, PagerProcessor{}},
+	} {
+		if got := explanation.Steps[i].DeclinedBy; !reflect.DeepEqual(got, want) {
+			t.Errorf("step %d declined by %v, want %v", i, got, want)
+		}
+	}
+
+	if got, want := explanation.Handlers(), []Processor{decliningProcessor{}, PagerProcessor{}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("handlers %v, want %v", got, want)
+	}
+
+	// The first selected processor declines, so the chain falls through to the next one, as explained
+	if result := c.Apply(cmd); !reflect.DeepEqual(result.Handled, []Processor{PagerProcessor{}}) {
+		t.Errorf("handled by %v, want PagerProcessor", result.Handled)
+	}
+
+	// Only FirstMatch mode stops at a processor
+	for _, step := range c.WithMode(AllMatch).Explain(cmd).Steps {
+		if len(step.DeclinedBy) > 0 {
+			t.Errorf("%T declined by %v in AllMatch mode", step.Processor, step.DeclinedBy)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// receiver performs useful function
+// command contains receiver and invokes receiver with hard-coded params
+// invoker executes command, optional bookkeeping
+// client has commands, receivers, and invokers.
+//   assigns receivers to commands with params to use.
+//   decides which commands to execute as needed.
+//   executes commands by passing them to invoker.
+
+// SmartBulb is a receiver that can be on or off, and select one of 3 colours.
+// Commands for a bulb that is offline fail.
+//
+// It is safe for concurrent use, as scheduled sequences can control the same bulb at once.
+type SmartBulb struct {
+	ID int
+
+	mu      sync.Mutex
+	on      bool
+	colour  string
+	offline bool
+}
+
+// BulbState is the state of a SmartBulb, which commands capture so they can be undone
+type BulbState struct {
+	On     bool
+	Colour string
+}
+
+// NewSmartBulb constructs a SmartBulb
+func NewSmartBulb(id int) *SmartBulb {
+	return &SmartBulb{ID: id}
+}
+
+// SetOffline sets whether the bulb is offline
+func (s *SmartBulb) SetOffline(offline bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.offline = offline
+}
+
+// Switch switches bulb on or off
+func (s *SmartBulb) Switch(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.switchTo(on)
+}
+
+// switchTo switches bulb on or off.
+// Must be called with the mutex held.
+func (s *SmartBulb) switchTo(on bool) {
+	s.on = on
+	msg := "On"
+	if !on {
+		msg = "Off"
+	}
+	fmt.Println("Bulb", s.ID, "switched", msg)
+}
+
+// Changes sets colour
+func (s *SmartBulb) Change(colour string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.change(colour)
+}
+
+// change sets colour.
+// Must be called with the mutex held.
+func (s *SmartBulb) change(colour string) {
+	s.colour = colour
+	fmt.Println("Bulb", s.ID, "coloured", colour)
+}
+
+// State returns the current state
+func (s *SmartBulb) State() BulbState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return BulbState{On: s.on, Colour: s.colour}
+}
+
+// Restore switches and colours the bulb to a previous state, only changing what is different
+func (s *SmartBulb) Restore(state BulbState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.restore(state)
+}
+
+// restore switches and colours the bulb to a previous state.
+// Must be called with the mutex held.
+func (s *SmartBulb) restore(state BulbState) {
+	if s.colour != state.Colour {
+		if state.Colour == "" {
+			s.colour = ""
+			fmt.Println("Bulb", s.ID, "colour cleared")
+		} else {
+			s.change(state.Colour)
+		}
+	}
+
+	if s.on != state.On {
+		s.switchTo(state.On)
+	}
+}
+
+// apply calls fn to change the bulb, with the mutex held so that the state before the change, which is returned, is
+// exactly what fn changed. ErrOffline is returned without calling fn if the bulb is offline.
+func (s *SmartBulb) apply(fn func()) (BulbState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.offline {
+		return BulbState{}, fmt.Errorf("%w: bulb %d", ErrOffline, s.ID)
+	}
+
+	prior := BulbState{On: s.on, Colour: s.colour}
+	fn()
+
+	return prior, nil
+}
@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for delays and schedules, so that tests can control time instead of sleeping
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// After returns a channel that receives the time once d has passed
+	After(d time.Duration) <-chan time.Time
+	// Sleep waits for d to pass
+	Sleep(d time.Duration)
+}
+
+// RealClock is Clock for the time package
+type RealClock struct{}
+
+// Now is Clock for RealClock
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// After is Clock for RealClock
+func (RealClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Sleep is Clock for RealClock
+func (RealClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// fakeTimer is a channel waiting for a FakeClock to reach a time
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// FakeClock is a Clock that only moves when it is told to.
+// Sleep advances the clock instead of waiting, so that code that sleeps runs instantly.
+//
+// It is safe for concurrent use.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+}
+
+// NewFakeClock constructs a FakeClock at the given time
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now is Clock for FakeClock
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// After is Clock for FakeClock, the channel receives the time once the clock is advanced by d
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+
+	c.timers = append(c.timers, fakeTimer{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Sleep is Clock for FakeClock, it advances the clock by d
+func (c *FakeClock) Sleep(d time.Duration) {
+	c.Advance(d)
+}
+
+// Advance moves the clock forward by d, firing any After channels that are due, in order
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	sort.SliceStable(c.timers, func(i, j int) bool { return c.timers[i].at.Before(c.timers[j].at) })
+	fired := 0
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			break
+		}
+
+		t.ch <- c.now
+		fired++
+	}
+	c.timers = c.timers[fired:]
+}
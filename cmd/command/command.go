@@ -0,0 +1,269 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Command is an interface for a method of no args
+type Command interface {
+	Execute()
+}
+
+// CommandFunc is an adapter to allow use of ordinary functions as commands
+type CommandFunc func()
+
+// Execute calls c()
+func (c CommandFunc) Execute() {
+	c()
+}
+
+// ReversibleCommand is a Command that can be undone.
+// Inverse returns a Command that undoes the most recent Execute, and must only be called after Execute.
+type ReversibleCommand interface {
+	Command
+	Inverse() Command
+}
+
+// invertingCommand is a ReversibleCommand that returns the Command that undoes each execution, so that a command that
+// is executed more than once, or concurrently, can undo each execution
+type invertingCommand interface {
+	ReversibleCommand
+	tryExecuteInverse() (Command, error)
+}
+
+// tryExecuteReversible executes a command as tryExecute does, and returns the Command that undoes this execution.
+// The inverse of a command that is not an invertingCommand is taken as soon as it has executed.
+func tryExecuteReversible(cmd ReversibleCommand) (inverse Command, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			inverse, err = nil, fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	if ic, isa := cmd.(invertingCommand); isa {
+		return ic.tryExecuteInverse()
+	}
+
+	if err := tryExecute(cmd); err != nil {
+		return nil, err
+	}
+
+	return cmd.Inverse(), nil
+}
+
+// Op is an operation a BulbCommand performs
+type Op uint
+
+// Op constants
+const (
+	// SwitchOp switches a bulb on or off
+	SwitchOp Op = iota
+	// ColourOp changes the colour of a bulb
+	ColourOp
+)
+
+var (
+	opToString = map[Op]string{
+		SwitchOp: "switch",
+		ColourOp: "colour",
+	}
+)
+
+// String is Op Stringer
+func (o Op) String() string {
+	return opToString[o]
+}
+
+// BulbCommand is a ReversibleCommand that switches or colours a bulb, capturing the state of the bulb before it does.
+// It is also a FallibleCommand, that fails if the bulb is offline.
+type BulbCommand struct {
+	bulb   *SmartBulb
+	op     Op
+	on     bool
+	colour string
+
+	// mu guards prior, the state before the most recent Execute
+	mu    sync.Mutex
+	prior BulbState
+}
+
+// Execute is Command for BulbCommand, it does nothing if the bulb is offline
+func (c *BulbCommand) Execute() {
+	c.TryExecute()
+}
+
+// TryExecute is FallibleCommand for BulbCommand
+func (c *BulbCommand) TryExecute() error {
+	_, err := c.tryExecuteInverse()
+	return err
+}
+
+// tryExecuteInverse is invertingCommand for BulbCommand
+func (c *BulbCommand) tryExecuteInverse() (Command, error) {
+	prior, err := c.bulb.apply(func() {
+		switch c.op {
+		case SwitchOp:
+			c.bulb.switchTo(c.on)
+		case ColourOp:
+			c.bulb.change(c.colour)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	inverse := restoreCommand{bulb: c.bulb, state: prior}
+
+	c.mu.Lock()
+	c.prior = inverse.state
+	c.mu.Unlock()
+
+	return inverse, nil
+}
+
+// Inverse is ReversibleCommand for BulbCommand, it restores the state of the bulb before the most recent Execute.
+// The inverse is a FallibleCommand that fails if the bulb is offline.
+func (c *BulbCommand) Inverse() Command {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return restoreCommand{bulb: c.bulb, state: c.prior}
+}
+
+// restoreCommand is a FallibleCommand that restores the state of a bulb
+type restoreCommand struct {
+	bulb  *SmartBulb
+	state BulbState
+}
+
+// Execute is Command for restoreCommand, it does nothing if the bulb is offline
+func (c restoreCommand) Execute() {
+	c.TryExecute()
+}
+
+// TryExecute is FallibleCommand for restoreCommand
+func (c restoreCommand) TryExecute() error {
+	_, err := c.bulb.apply(func() { c.bulb.restore(c.state) })
+	return err
+}
+
+// String is BulbCommand Stringer
+func (c *BulbCommand) String() string {
+	if c.op == SwitchOp {
+		if c.on {
+			return fmt.Sprintf("switch bulb %d on", c.bulb.ID)
+		}
+
+		return fmt.Sprintf("switch bulb %d off", c.bulb.ID)
+	}
+
+	return fmt.Sprintf("colour bulb %d %s", c.bulb.ID, c.colour)
+}
+
+// SwitchOn generates a Command for a bulb to turn it on
+func SwitchOn(s *SmartBulb) *BulbCommand {
+	return &BulbCommand{bulb: s, op: SwitchOp, on: true}
+}
+
+// SwitchOff generates a Command for a bulb to turn it off
+func SwitchOff(s *SmartBulb) *BulbCommand {
+	return &BulbCommand{bulb: s, op: SwitchOp, on: false}
+}
+
+// ColourRed generates a Command for a bulb to set the colour red
+func ColourRed(s *SmartBulb) *BulbCommand {
+	return &BulbCommand{bulb: s, op: ColourOp, colour: "Red"}
+}
+
+// ColourGreen generates a Command for a bulb to set the colour green
+func ColourGreen(s *SmartBulb) *BulbCommand {
+	return &BulbCommand{bulb: s, op: ColourOp, colour: "Green"}
+}
+
+// ColourBlue generates a Command for a bulb to set the colour blue
+func ColourBlue(s *SmartBulb) *BulbCommand {
+	return &BulbCommand{bulb: s, op: ColourOp, colour: "Blue"}
+}
+
+// Batch is a ReversibleCommand that executes several commands in order, and undoes them in reverse order.
+// It is also a FallibleCommand, that undoes the commands already executed if one fails.
+type Batch []ReversibleCommand
+
+// Execute is Command for Batch
+func (b Batch) Execute() {
+	b.TryExecute()
+}
+
+// TryExecute is FallibleCommand for Batch.
+// If undoing the commands already executed fails too, the error describes both failures.
+func (b Batch) TryExecute() error {
+	_, err := b.tryExecuteInverse()
+	return err
+}
+
+// tryExecuteInverse is invertingCommand for Batch
+func (b Batch) tryExecuteInverse() (Command, error) {
+	inverses := make(inverseBatch, len(b))
+	for i, cmd := range b {
+		inverse, err := tryExecuteReversible(cmd)
+		if err != nil {
+			if undoErr := inverses[len(b)-i:].TryExecute(); undoErr != nil {
+				return nil, fmt.Errorf("%w (undo failed: %v)", err, undoErr)
+			}
+
+			return nil, err
+		}
+
+		inverses[len(b)-1-i] = inverse
+	}
+
+	return inverses, nil
+}
+
+// Inverse is ReversibleCommand for Batch.
+// The inverse is a FallibleCommand that undoes every command, even if undoing some fails, and returns their errors.
+func (b Batch) Inverse() Command {
+	inverses := make(inverseBatch, len(b))
+	for i, cmd := range b {
+		inverses[len(b)-1-i] = cmd.Inverse()
+	}
+
+	return inverses
+}
+
+// inverseBatch is a FallibleCommand that undoes the commands of a Batch, so it is in reverse order
+type inverseBatch []Command
+
+// Execute is Command for inverseBatch
+func (b inverseBatch) Execute() {
+	b.TryExecute()
+}
+
+// TryExecute is FallibleCommand for inverseBatch.
+// Every command is executed, even if some fail, and the error wraps the first failure and describes the others.
+func (b inverseBatch) TryExecute() error {
+	var (
+		first error
+		rest  []string
+	)
+
+	for _, cmd := range b {
+		if err := tryExecute(cmd); err != nil {
+			if first == nil {
+				first = err
+			} else {
+				rest = append(rest, err.Error())
+			}
+		}
+	}
+
+	if len(rest) > 0 {
+		return fmt.Errorf("%w; %s", first, strings.Join(rest, "; "))
+	}
+
+	return first
+}
@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// errUndo is the error of undoCommand
+var errUndo = fmt.Errorf("cannot undo")
+
+// undoCommand is a ReversibleCommand that does nothing, and whose inverse fails
+type undoCommand struct{}
+
+// Execute is Command for undoCommand
+func (undoCommand) Execute() {}
+
+// Inverse is ReversibleCommand for undoCommand
+func (undoCommand) Inverse() Command {
+	return CommandFunc(func() { panic(errUndo) })
+}
+
+// assertState fails if a bulb is not in the expected state
+func assertState(t *testing.T, bulb *SmartBulb, want BulbState) {
+	t.Helper()
+
+	if got := bulb.State(); got != want {
+		t.Errorf("bulb %d is %+v, want %+v", bulb.ID, got, want)
+	}
+}
+
+func TestHistoryReexecute(t *testing.T) {
+	var (
+		bulb = NewSmartBulb(1)
+		red  = ColourRed(bulb)
+		h    = NewHistory(0)
+	)
+
+	// Executing the same command again must not lose the state to undo the first execution
+	for _, cmd := range []ReversibleCommand{red, ColourBlue(bulb), red} {
+		if err := h.Execute(cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, want := range []string{"Blue", "Red", ""} {
+		if err := h.Undo(); err != nil {
+			t.Fatal(err)
+		}
+		assertState(t, bulb, BulbState{Colour: want})
+	}
+
+	// Redo captures the state again
+	for _, want := range []string{"Red", "Blue", "Red"} {
+		if err := h.Redo(); err != nil {
+			t.Fatal(err)
+		}
+		assertState(t, bulb, BulbState{Colour: want})
+	}
+
+	if err := h.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	assertState(t, bulb, BulbState{Colour: "Blue"})
+}
+
+func TestBatchRollback(t *testing.T) {
+	var (
+		bulb    = NewSmartBulb(1)
+		offline = &SmartBulb{ID: 2, offline: true}
+	)
+
+	// A failure undoes the commands already executed
+	err := Batch{SwitchOn(bulb), ColourRed(bulb), SwitchOn(offline)}.TryExecute()
+	if !errors.Is(err, ErrOffline) {
+		t.Errorf("err = %v, want ErrOffline", err)
+	}
+	assertState(t, bulb, BulbState{})
+
+	// A failure to undo them is returned too
+	err = Batch{SwitchOn(bulb), undoCommand{}, SwitchOn(offline)}.TryExecute()
+	if !errors.Is(err, ErrOffline) || !strings.Contains(err.Error(), errUndo.Error()) {
+		t.Errorf("err = %v, want ErrOffline and %v", err, errUndo)
+	}
+
+	// The undo continues past a failure
+	assertState(t, bulb, BulbState{})
+}
+
+func TestBatchInverse(t *testing.T) {
+	var (
+		bulb  = NewSmartBulb(1)
+		bulb2 = NewSmartBulb(2)
+		batch = Batch{SwitchOn(bulb), SwitchOn(bulb2), ColourGreen(bulb2)}
+	)
+
+	if err := batch.TryExecute(); err != nil {
+		t.Fatal(err)
+	}
+
+	bulb2.SetOffline(true)
+	err := tryExecute(batch.Inverse())
+	if !errors.Is(err, ErrOffline) || !strings.Contains(err.Error(), "bulb 2") {
+		t.Errorf("err = %v, want ErrOffline for bulb 2", err)
+	}
+
+	// Commands for the bulb that is online are still undone
+	assertState(t, bulb, BulbState{})
+	assertState(t, bulb2, BulbState{On: true, Colour: "Green"})
+}
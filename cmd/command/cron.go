@@ -0,0 +1,237 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Trigger decides when a scheduled sequence runs
+type Trigger interface {
+	// Next returns the first time the trigger fires strictly after a time, or false if it never fires again
+	Next(after time.Time) (time.Time, bool)
+}
+
+// at is a Trigger that fires once
+type at struct {
+	t time.Time
+}
+
+// At returns a Trigger that fires once, at a time
+func At(t time.Time) Trigger {
+	return at{t: t}
+}
+
+// Next is Trigger for at
+func (a at) Next(after time.Time) (time.Time, bool) {
+	return a.t, a.t.After(after)
+}
+
+// String is Stringer for at
+func (a at) String() string {
+	return fmt.Sprintf("at %s", a.t.Format(time.RFC3339))
+}
+
+// cronField describes the values of one field of a cron expression
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var (
+	minuteField = cronField{name: "minute", min: 0, max: 59}
+	hourField   = cronField{name: "hour", min: 0, max: 23}
+	domField    = cronField{name: "day of month", min: 1, max: 31}
+	monthField  = cronField{
+		name:  "month",
+		min:   1,
+		max:   12,
+		names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"},
+	}
+	// Day of week 7 is also Sunday
+	dowField = cronField{
+		name:  "day of week",
+		min:   0,
+		max:   7,
+		names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"},
+	}
+)
+
+// value parses a number or name of the field
+func (f cronField) value(str string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(str, name) {
+			return f.min + i, nil
+		}
+	}
+
+	n, err := strconv.Atoi(str)
+	if (err != nil) || (n < f.min) || (n > f.max) {
+		return 0, fmt.Errorf("%s %q is not a number from %d to %d", f.name, str, f.min, f.max)
+	}
+
+	return n, nil
+}
+
+// parse parses a comma separated list of *, values, and ranges, each optionally with a /step, into a set of bits,
+// returning true if the field starts with *, which as in cron includes a step such as */2
+func (f cronField) parse(expr string) (uint64, bool, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		var (
+			rng  = part
+			step = 1
+		)
+
+		if slash := strings.IndexByte(part, '/'); slash >= 0 {
+			n, err := strconv.Atoi(part[slash+1:])
+			if (err != nil) || (n <= 0) {
+				return 0, false, fmt.Errorf("%s step %q is not a positive number", f.name, part[slash+1:])
+			}
+			rng, step = part[:slash], n
+		}
+
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			var err error
+			bounds := strings.SplitN(rng, "-", 2)
+			if lo, err = f.value(bounds[0]); err != nil {
+				return 0, false, err
+			}
+
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = f.value(bounds[1]); err != nil {
+					return 0, false, err
+				}
+			} else if step > 1 {
+				// a/n means from a to the max
+				hi = f.max
+			}
+
+			if hi < lo {
+				return 0, false, fmt.Errorf("%s range %q is backwards", f.name, rng)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, strings.HasPrefix(expr, "*"), nil
+}
+
+// CronTrigger is a Trigger that fires at times described by a cron expression
+type CronTrigger struct {
+	expr    string
+	minutes uint64
+	hours   uint64
+	doms    uint64
+	months  uint64
+	dows    uint64
+	domStar bool
+	dowStar bool
+}
+
+// ParseCron parses a cron expression of 5 fields: minute, hour, day of month, month, and day of week.
+// Each field is *, a number, or a range such as 1-5, optionally with a step such as */15, or a comma separated list of
+// them. Months and days of week can be names, such as jan or mon, and Sunday is 0 or 7.
+// As in cron, if neither day of month nor day of week starts with *, a day matching either fires, otherwise a day
+// must match both: so 0 0 */2 * mon is midnight on Mondays that are an odd day of the month.
+//
+// For example, 0 18 * * mon-fri is 18:00 every weekday.
+func ParseCron(expr string) (*CronTrigger, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields", expr)
+	}
+
+	var (
+		ct  = &CronTrigger{expr: expr}
+		err error
+	)
+
+	if ct.minutes, _, err = minuteField.parse(fields[0]); err != nil {
+		return nil, err
+	}
+
+	if ct.hours, _, err = hourField.parse(fields[1]); err != nil {
+		return nil, err
+	}
+
+	if ct.doms, ct.domStar, err = domField.parse(fields[2]); err != nil {
+		return nil, err
+	}
+
+	if ct.months, _, err = monthField.parse(fields[3]); err != nil {
+		return nil, err
+	}
+
+	if ct.dows, ct.dowStar, err = dowField.parse(fields[4]); err != nil {
+		return nil, err
+	}
+
+	// Sunday is 0 and 7
+	if ct.dows&(1<<7) != 0 {
+		ct.dows |= 1
+	}
+
+	return ct, nil
+}
+
+// matchesDay returns true if a day matches the day of month and day of week fields
+func (ct *CronTrigger) matchesDay(t time.Time) bool {
+	var (
+		dom = ct.doms&(1<<uint(t.Day())) != 0
+		dow = ct.dows&(1<<uint(t.Weekday())) != 0
+	)
+
+	if ct.domStar || ct.dowStar {
+		return dom && dow
+	}
+
+	return dom || dow
+}
+
+// Next is Trigger for CronTrigger, in the location of after.
+// A cron expression that matches no day, such as 0 0 31 feb *, never fires.
+func (ct *CronTrigger) Next(after time.Time) (time.Time, bool) {
+	var (
+		loc   = after.Location()
+		t     = after.Truncate(time.Minute).Add(time.Minute)
+		limit = after.AddDate(5, 0, 0)
+	)
+
+	for t.Before(limit) {
+		y, m, d := t.Date()
+
+		switch {
+		case ct.months&(1<<uint(m)) == 0:
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+
+		case !ct.matchesDay(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+
+		case ct.hours&(1<<uint(t.Hour())) == 0:
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, loc)
+
+		case ct.minutes&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+
+		default:
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// String is CronTrigger Stringer
+func (ct *CronTrigger) String() string {
+	return ct.expr
+}
@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// date returns a time in UTC
+func date(year int, month time.Month, day, hour, min int) time.Time {
+	return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"* * * smarch *",
+		"* * * * funday",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"30-10 * * * *",
+		"1,,2 * * * *",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("%q should be invalid", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	for _, test := range []struct {
+		expr  string
+		after time.Time
+		want  []time.Time
+	}{
+		// Weekdays at 18:00, from a Friday
+		{"0 18 * * mon-fri", date(2024, 1, 5, 12, 0), []time.Time{
+			date(2024, 1, 5, 18, 0), date(2024, 1, 8, 18, 0), date(2024, 1, 9, 18, 0),
+		}},
+		// Names of months and days, in any case
+		{"30 9 * JAN,jul Sun", date(2024, 1, 22, 0, 0), []time.Time{
+			date(2024, 1, 28, 9, 30), date(2024, 7, 7, 9, 30), date(2024, 7, 14, 9, 30),
+		}},
+		// Sunday is 0 or 7
+		{"0 0 * * 7", date(2024, 1, 1, 0, 0), []time.Time{
+			date(2024, 1, 7, 0, 0), date(2024, 1, 14, 0, 0),
+		}},
+		// Steps over the whole field, and from a start to the end of the field
+		{"*/15 * * * *", date(2024, 1, 1, 10, 7), []time.Time{
+			date(2024, 1, 1, 10, 15), date(2024, 1, 1, 10, 30), date(2024, 1, 1, 10, 45), date(2024, 1, 1, 11, 0),
+		}},
+		{"5/20 * * * *", date(2024, 1, 1, 10, 7), []time.Time{
+			date(2024, 1, 1, 10, 25), date(2024, 1, 1, 10, 45), date(2024, 1, 1, 11, 5),
+		}},
+		// Steps within a range, and lists
+		{"0 8-18/5,22 * * *", date(2024, 1, 1, 0, 0), []time.Time{
+			date(2024, 1, 1, 8, 0), date(2024, 1, 1, 13, 0), date(2024, 1, 1, 18, 0), date(2024, 1, 1, 22, 0), date(2024, 1, 2, 8, 0),
+		}},
+		// The next time is strictly after
+		{"0 12 * * *", date(2024, 1, 1, 12, 0), []time.Time{
+			date(2024, 1, 2, 12, 0),
+		}},
+		// Day of month and day of week are ORed if neither is *: the 13th, and every Friday
+		{"0 0 13 * fri", date(2024, 1, 1, 0, 0), []time.Time{
+			date(2024, 1, 5, 0, 0), date(2024, 1, 12, 0, 0), date(2024, 1, 13, 0, 0), date(2024, 1, 19, 0, 0),
+		}},
+		// but ANDed if either is *
+		{"0 0 1 * *", date(2024, 1, 15, 0, 0), []time.Time{
+			date(2024, 2, 1, 0, 0), date(2024, 3, 1, 0, 0),
+		}},
+		{"0 0 * * mon", date(2024, 1, 1, 0, 0), []time.Time{
+			date(2024, 1, 8, 0, 0), date(2024, 1, 15, 0, 0),
+		}},
+		// and a step over the whole field, such as */2, counts as *
+		{"0 0 */2 * mon", date(2023, 12, 31, 0, 0), []time.Time{
+			date(2024, 1, 1, 0, 0), date(2024, 1, 15, 0, 0), date(2024, 1, 29, 0, 0), date(2024, 2, 5, 0, 0),
+		}},
+		// the 13th that is a Sunday or Friday, which is day of week 0 or 5
+		{"0 0 13 * */5", date(2024, 1, 1, 0, 0), []time.Time{
+			date(2024, 9, 13, 0, 0), date(2024, 10, 13, 0, 0), date(2024, 12, 13, 0, 0),
+		}},
+		// A day that only exists in leap years
+		{"0 0 29 feb *", date(2024, 3, 1, 0, 0), []time.Time{
+			date(2028, 2, 29, 0, 0),
+		}},
+	} {
+		ct, err := ParseCron(test.expr)
+		if err != nil {
+			t.Errorf("%q: %s", test.expr, err)
+			continue
+		}
+
+		after := test.after
+		for _, want := range test.want {
+			got, fires := ct.Next(after)
+			if !fires || !got.Equal(want) {
+				t.Errorf("%q after %s: %s %v, want %s", test.expr, after, got, fires, want)
+				break
+			}
+			after = got
+		}
+	}
+}
+
+func TestCronNeverFires(t *testing.T) {
+	for _, expr := range []string{"0 0 31 feb *", "0 0 30 feb *", "0 0 31 apr,jun,sep,nov *"} {
+		ct, err := ParseCron(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if next, fires := ct.Next(date(2024, 1, 1, 0, 0)); fires {
+			t.Errorf("%q fires at %s", expr, next)
+		}
+	}
+
+	// Day of week is ORed, so it still fires on Mondays in February
+	ct, err := ParseCron("0 0 31 feb mon")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if next, fires := ct.Next(date(2024, 1, 1, 0, 0)); !fires || !next.Equal(date(2024, 2, 5, 0, 0)) {
+		t.Errorf("0 0 31 feb mon fires at %s %v", next, fires)
+	}
+}
@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+)
+
+var (
+	// ErrNothingToUndo is the error returned by Undo when there is no command to undo
+	ErrNothingToUndo = fmt.Errorf("Nothing to undo")
+	// ErrNothingToRedo is the error returned by Redo when there is no command to redo
+	ErrNothingToRedo = fmt.Errorf("Nothing to redo")
+)
+
+// executed is a command that History has executed, and the Command that undoes that execution
+type executed struct {
+	cmd     ReversibleCommand
+	inverse Command
+}
+
+// History executes ReversibleCommands, remembering them so they can be undone and redone.
+// Each execution is undone as it was executed, even if the same command is executed several times.
+// Executing a new command after undoing some discards the undone commands, as they belong to a different branch of
+// history. If the history has a limit, the oldest commands are forgotten once there are more than the limit.
+type History struct {
+	done   []executed
+	undone []ReversibleCommand
+	limit  int
+}
+
+// NewHistory constructs a History that remembers up to limit commands, or any number if limit <= 0
+func NewHistory(limit int) *History {
+	return &History{limit: limit}
+}
+
+// Execute executes a command, and remembers it so it can be undone if it succeeds
+func (h *History) Execute(cmd ReversibleCommand) error {
+	if err := h.execute(cmd); err != nil {
+		return err
+	}
+
+	h.undone = nil
+	if (h.limit > 0) && (len(h.done) > h.limit) {
+		h.done = append([]executed(nil), h.done[len(h.done)-h.limit:]...)
+	}
+
+	return nil
+}
+
+// execute executes a command, and remembers it and its inverse if it succeeds
+func (h *History) execute(cmd ReversibleCommand) error {
+	inverse, err := tryExecuteReversible(cmd)
+	if err != nil {
+		return err
+	}
+
+	h.done = append(h.done, executed{cmd: cmd, inverse: inverse})
+	return nil
+}
+
+// Undo undoes the most recent command that has not been undone. If undoing fails, the command is not undone.
+func (h *History) Undo() error {
+	if len(h.done) == 0 {
+		return ErrNothingToUndo
+	}
+
+	last := h.done[len(h.done)-1]
+	if err := tryExecute(last.inverse); err != nil {
+		return err
+	}
+
+	h.done = h.done[:len(h.done)-1]
+	h.undone = append(h.undone, last.cmd)
+
+	return nil
+}
+
+// Redo executes the most recently undone command again. If it fails, the command is not redone.
+func (h *History) Redo() error {
+	if len(h.undone) == 0 {
+		return ErrNothingToRedo
+	}
+
+	if err := h.execute(h.undone[len(h.undone)-1]); err != nil {
+		return err
+	}
+
+	h.undone = h.undone[:len(h.undone)-1]
+	return nil
+}
+
+// CanUndo returns true if there is a command to undo
+func (h *History) CanUndo() bool {
+	return len(h.done) > 0
+}
+
+// CanRedo returns true if there is a command to redo
+func (h *History) CanRedo() bool {
+	return len(h.undone) > 0
+}
+
+// String is History Stringer, listing the commands that can be undone, then the commands that can be redone
+func (h *History) String() string {
+	undo := make([]ReversibleCommand, len(h.done))
+	for i, e := range h.done {
+		undo[i] = e.cmd
+	}
+
+	redo := make([]ReversibleCommand, len(h.undone))
+	for i, cmd := range h.undone {
+		redo[len(h.undone)-1-i] = cmd
+	}
+
+	return fmt.Sprintf("undo %v, redo %v", undo, redo)
+}
@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SequenceInvoker is the invoker, it executes a sequence of commands and tracks how many times it succeeded and failed.
+// There can be a delay before each command, such as to fade a bulb from one colour to another.
+//
+// A sequence stops at the first command that fails or panics. In a transactional sequence, the commands that were
+// already executed are then undone in reverse order, so that bulbs are not left half updated.
+type SequenceInvoker struct {
+	commands []Command
+	// delays[i] is the delay before commands[i]
+	delays []time.Duration
+	// pending is the delay before the next command added
+	pending       time.Duration
+	clock         Clock
+	transactional bool
+
+	// mu guards the counts, as a sequence can be executed by several goroutines at once
+	mu        sync.Mutex
+	successes int
+	failures  int
+}
+
+// NewSequenceInvoker constructs a SequenceInvoker that uses the real clock
+func NewSequenceInvoker() *SequenceInvoker {
+	return &SequenceInvoker{clock: RealClock{}}
+}
+
+// WithCommand adds a command to the sequence
+func (s *SequenceInvoker) WithCommand(cmd Command) *SequenceInvoker {
+	s.commands = append(s.commands, cmd)
+	s.delays = append(s.delays, s.pending)
+	s.pending = 0
+
+	return s
+}
+
+// WithDelay adds a delay before the next command added
+func (s *SequenceInvoker) WithDelay(d time.Duration) *SequenceInvoker {
+	s.pending += d
+	return s
+}
+
+// WithClock overrides the real clock used to wait for delays
+func (s *SequenceInvoker) WithClock(clock Clock) *SequenceInvoker {
+	s.clock = clock
+	return s
+}
+
+// WithTransaction sets whether the sequence is transactional
+func (s *SequenceInvoker) WithTransaction(transactional bool) *SequenceInvoker {
+	s.transactional = transactional
+	return s
+}
+
+// Counts returns how many times the sequence succeeded and failed
+func (s *SequenceInvoker) Counts() (successes, failures int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.successes, s.failures
+}
+
+// Duration returns the total of the delays
+func (s *SequenceInvoker) Duration() time.Duration {
+	var total time.Duration
+	for _, d := range s.delays {
+		total += d
+	}
+
+	return total
+}
+
+// InvokeAndCount executes the sequence of commands, waiting for each delay, and increments the count of successes if
+// every command succeeds, or failures if any command fails or panics
+func (ci *SequenceInvoker) InvokeAndCount() SequenceResult {
+	clock := ci.clock
+	if clock == nil {
+		clock = RealClock{}
+	}
+
+	e := ci.newExecution()
+	for !e.done() {
+		if d := ci.delays[e.index]; d > 0 {
+			clock.Sleep(d)
+		}
+		e.step()
+	}
+
+	return e.finish()
+}
+
+// finished counts an execution of the sequence as a success or failure
+func (ci *SequenceInvoker) finished(ok bool) {
+	ci.mu.Lock()
+	if ok {
+		ci.successes++
+	} else {
+		ci.failures++
+	}
+	successes, failures := ci.successes, ci.failures
+	ci.mu.Unlock()
+
+	fmt.Println("Sequence succeeded", successes, "times, failed", failures, "times")
+}
+
+// Client is the client
+type Client struct {
+	invokers map[int]*SequenceInvoker
+}
+
+// NewClient constructs a Client
+func NewClient() *Client {
+	return &Client{invokers: map[int]*SequenceInvoker{}}
+}
+
+// WithSequence adds a sequence to the client
+func (c *Client) WithSequence(seqNo int, seq *SequenceInvoker) *Client {
+	c.invokers[seqNo] = seq
+	return c
+}
+
+// ScheduleSequence schedules a specific sequence, returning a func that cancels it
+func (c Client) ScheduleSequence(s *Scheduler, seqNo int, trigger Trigger, policy MissedRunPolicy) (cancel func(), err error) {
+	seq, have := c.invokers[seqNo]
+	if !have {
+		return nil, fmt.Errorf("%w: %d", ErrNoSuchSequence, seqNo)
+	}
+
+	return s.Schedule(seq, trigger, policy)
+}
+
+// PerformSequence performs a specific sequence
+func (c Client) PerformSequence(seqNo int) (SequenceResult, error) {
+	seq, have := c.invokers[seqNo]
+	if !have {
+		return SequenceResult{}, fmt.Errorf("%w: %d", ErrNoSuchSequence, seqNo)
+	}
+
+	result := seq.InvokeAndCount()
+	fmt.Println("Performed sequence", seqNo)
+
+	return result, nil
+}
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSequenceCountsConcurrent(t *testing.T) {
+	var (
+		seq = NewSequenceInvoker().WithClock(NewFakeClock(time.Time{})).WithCommand(CommandFunc(func() {}))
+		wg  sync.WaitGroup
+	)
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seq.InvokeAndCount()
+			seq.Counts()
+		}()
+	}
+	wg.Wait()
+
+	if successes, failures := seq.Counts(); (successes != 20) || (failures != 0) {
+		t.Errorf("counts = %d, %d, want 20, 0", successes, failures)
+	}
+}
+
+func TestSequencesShareBulb(t *testing.T) {
+	var (
+		bulb = NewSmartBulb(1)
+		wg   sync.WaitGroup
+	)
+
+	// Sequences run concurrently on the same bulb, which the race detector checks
+	for _, colour := range []func(*SmartBulb) *BulbCommand{ColourRed, ColourGreen, ColourBlue} {
+		seq := NewSequenceInvoker().WithTransaction(true).WithCommand(SwitchOn(bulb)).WithCommand(colour(bulb))
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				seq.InvokeAndCount()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if state := bulb.State(); !state.On || (state.Colour == "") {
+		t.Errorf("state %+v, want on and coloured", state)
+	}
+}
+
+func TestClientNoSuchSequence(t *testing.T) {
+	cl := NewClient().WithSequence(1, NewSequenceInvoker())
+
+	if _, err := cl.PerformSequence(2); !errors.Is(err, ErrNoSuchSequence) {
+		t.Errorf("PerformSequence err = %v, want %v", err, ErrNoSuchSequence)
+	}
+
+	sched := NewScheduler(NewFakeClock(time.Time{}))
+	if _, err := cl.ScheduleSequence(sched, 2, At(time.Time{}), Skip); !errors.Is(err, ErrNoSuchSequence) {
+		t.Errorf("ScheduleSequence err = %v, want %v", err, ErrNoSuchSequence)
+	}
+
+	if result, err := cl.PerformSequence(1); (err != nil) || !result.OK() {
+		t.Errorf("PerformSequence = %v, %v, want an empty sequence to succeed", result, err)
+	}
+}
@@ -3,131 +3,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
 )
 
-// receiver performs useful function
-// command contains receiver and invokes receiver with hard-coded params
-// invoker executes command, optional bookkeeping
-// client has commands, receivers, and invokers.
-//   assigns receivers to commands with params to use.
-//   decides which commands to execute as needed.
-//   executes commands by passing them to invoker.
-
-// SmartBulb is a receiver that can be on or off, and select one of 3 colours
-type SmartBulb struct {
-	ID     int
-	On     bool
-	Colour string
-}
-
-// NewSmartBulb constructs a SmartBulb
-func NewSmartBulb(id int) *SmartBulb {
-	return &SmartBulb{ID: id}
-}
-
-// Switch switches bulb on or off
-func (s *SmartBulb) Switch(on bool) {
-	s.On = on
-	msg := "On"
-	if !on {
-		msg = "Off"
-	}
-	fmt.Println("Bulb", s.ID, "switched", msg)
-}
-
-// Changes sets colour
-func (s *SmartBulb) Change(colour string) {
-	s.Colour = colour
-	fmt.Println("Bulb", s.ID, "coloured", colour)
-}
-
-// Command is an interface for a method of no args
-type Command interface {
-	Execute()
-}
-
-// CommandFunc is an adapter to allow use of ordinary functions as commands
-type CommandFunc func()
-
-// Execute calls c()
-func (c CommandFunc) Execute() {
-	c()
-}
-
-// SwitchOn generates a Command for a bulb to turn it on
-func SwitchOn(s *SmartBulb) Command {
-	return CommandFunc(func() { s.Switch(true) })
-}
-
-// SwitchOff generates a Command for a bulb to turn it off
-func SwitchOff(s *SmartBulb) Command {
-	return CommandFunc(func() { s.Switch(false) })
-}
-
-// ColourRed generates a Command for a bulb to set the colour red
-func ColourRed(s *SmartBulb) Command {
-	return CommandFunc(func() { s.Change("Red") })
-}
-
-// ColourGreen generates a Command for a bulb to set the colour green
-func ColourGreen(s *SmartBulb) Command {
-	return CommandFunc(func() { s.Change("Green") })
-}
-
-// ColourBlue generates a Command for a bulb to set the colour blue
-func ColourBlue(s *SmartBulb) Command {
-	return CommandFunc(func() { s.Change("Blue") })
-}
-
-// SequenceInvoker is the invoker, it executes a sequence of commands and tracks how many times it has been called
-type SequenceInvoker struct {
-	commands []Command
-	count    int
-}
-
-// NewSequenceInvoker constructs a SequenceInvoker
-func NewSequenceInvoker() *SequenceInvoker {
-	return &SequenceInvoker{}
-}
-
-// WithCommand adds a command to the sequence
-func (s *SequenceInvoker) WithCommand(cmd Command) *SequenceInvoker {
-	s.commands = append(s.commands, cmd)
-	return s
-}
-
-// InvokeAndCount executes the sequence of commands and increments the count if no command panics
-func (ci *SequenceInvoker) InvokeAndCount() {
-	for _, cmd := range ci.commands {
-		cmd.Execute()
-	}
-	ci.count++
-	fmt.Println("Sequence invoked", ci.count, "times")
-}
-
-// Client is the client
-type Client struct {
-	invokers map[int]*SequenceInvoker
-}
-
-// NewClient constructs a Client
-func NewClient() *Client {
-	return &Client{invokers: map[int]*SequenceInvoker{}}
-}
-
-// WithSequence adds a sequence to the client
-func (c *Client) WithSequence(seqNo int, seq *SequenceInvoker) *Client {
-	c.invokers[seqNo] = seq
-	return c
-}
-
-// PerformSequence performs a specific sequence
-func (c Client) PerformSequence(seqNo int) {
-	c.invokers[seqNo].InvokeAndCount()
-	fmt.Println("Performed sequence", seqNo)
-}
-
 func main() {
 	bulb := NewSmartBulb(1)
 	seq := NewSequenceInvoker().
@@ -137,6 +20,225 @@ func main() {
 		WithCommand(ColourGreen(bulb)).
 		WithCommand(SwitchOff(bulb))
 	cl := NewClient().WithSequence(1, seq)
-	cl.PerformSequence(1)
-	cl.PerformSequence(1)
+	for i := 0; i < 2; i++ {
+		if _, err := cl.PerformSequence(1); err != nil {
+			panic(err)
+		}
+	}
+
+	// Undo and redo, remembering at most 3 commands
+	fmt.Println("\nUndo and redo:")
+	var (
+		bulb2 = NewSmartBulb(2)
+		h     = NewHistory(3)
+	)
+	h.Execute(SwitchOn(bulb2))
+	h.Execute(ColourRed(bulb2))
+	h.Execute(ColourGreen(bulb2))
+	h.Execute(ColourBlue(bulb2))
+	fmt.Println(h)
+
+	for h.CanUndo() {
+		h.Undo()
+		fmt.Printf("  undone, bulb 2 is %+v\n", bulb2.State())
+	}
+	fmt.Println(h.Undo())
+
+	h.Redo()
+	h.Redo()
+	fmt.Println(h)
+
+	// A new command discards the commands that could be redone
+	h.Execute(SwitchOff(bulb2))
+	fmt.Println(h)
+	fmt.Println(h.Redo())
+
+	// A batch of commands is undone in one step
+	fmt.Println("\nBatch:")
+	h.Execute(Batch{SwitchOn(bulb), ColourBlue(bulb), SwitchOn(bulb2), ColourBlue(bulb2)})
+	h.Undo()
+	fmt.Printf("bulb 1 is %+v, bulb 2 is %+v\n", bulb.State(), bulb2.State())
+
+	// Record live commands into a sequence file, then load and replay it
+	fmt.Println("\nRecord and replay:")
+	dir, err := ioutil.TempDir("", "sequences")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		rec      Recorder
+		filename = filepath.Join(dir, "evening.json")
+		bulbs    = map[int]*SmartBulb{1: bulb, 2: bulb2}
+		bulb5    = NewSmartBulb(5)
+	)
+	bulb5.SetOffline(true)
+	rec.Execute(SwitchOff(bulb))
+	rec.Start()
+	rec.Execute(SwitchOn(bulb))
+	rec.Execute(ColourRed(bulb))
+	rec.Execute(SwitchOn(bulb2))
+	// A command for an offline bulb fails, and is not recorded
+	fmt.Println(rec.Execute(SwitchOn(bulb5)))
+	rec.Stop()
+	rec.Execute(SwitchOff(bulb2))
+	if err := rec.Save(filename); err != nil {
+		panic(err)
+	}
+
+	data, _ := ioutil.ReadFile(filename)
+	fmt.Print(string(data))
+
+	specs, err := LoadSpecs(filename)
+	if err != nil {
+		panic(err)
+	}
+	replay, err := BuildSequence(specs, bulbs)
+	if err != nil {
+		panic(err)
+	}
+	if _, err := NewClient().WithSequence(2, replay).PerformSequence(2); err != nil {
+		panic(err)
+	}
+
+	// Sequences are validated against the known bulbs
+	_, err = BuildSequence([]CommandSpec{
+		{Bulb: 1, Op: "colour", Value: "BLUE"},
+		{Bulb: 3, Op: "switch", Value: "on"},
+		{Bulb: 2, Op: "dim", Value: "50%"},
+		{Bulb: 2, Op: "colour", Value: "purple"},
+		{Bulb: 2, Op: "switch", Value: "maybe"},
+	}, bulbs)
+	fmt.Println(err)
+
+	ioutil.WriteFile(filename, []byte(`[{"bulb": 1, "op": "switch", "value": "on", "brightness": 50}]`), 0644)
+	_, err = LoadSpecs(filename)
+	fmt.Println(err)
+
+	// Schedule a fade from blue to red over 10 minutes at 18:00 every weekday, starting on a Friday
+	fmt.Println("\nSchedule:")
+	var (
+		clock = NewFakeClock(time.Date(2024, 1, 5, 17, 58, 0, 0, time.UTC))
+		sched = NewScheduler(clock)
+		bulb3 = NewSmartBulb(3)
+		fade  = NewSequenceInvoker().
+			WithCommand(SwitchOn(bulb3)).
+			WithCommand(ColourBlue(bulb3)).
+			WithDelay(5 * time.Minute).
+			WithCommand(ColourGreen(bulb3)).
+			WithDelay(5 * time.Minute).
+			WithCommand(ColourRed(bulb3))
+	)
+	weekdays, err := ParseCron("0 18 * * mon-fri")
+	if err != nil {
+		panic(err)
+	}
+
+	cl.WithSequence(3, fade).WithSequence(4, NewSequenceInvoker().WithCommand(SwitchOn(bulb2)))
+	cancelFade, err := cl.ScheduleSequence(sched, 3, weekdays, Skip)
+	if err != nil {
+		panic(err)
+	}
+	if _, err := cl.ScheduleSequence(sched, 4, At(time.Date(2024, 1, 5, 18, 3, 0, 0, time.UTC)), Skip); err != nil {
+		panic(err)
+	}
+	_, err = cl.ScheduleSequence(sched, 4, At(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)), Skip)
+	fmt.Println(err)
+	_, err = cl.ScheduleSequence(sched, 5, weekdays, Skip)
+	fmt.Println(err)
+	_, err = cl.PerformSequence(5)
+	fmt.Println(err)
+
+	tick := func(d time.Duration) {
+		clock.Advance(d)
+		if next, have := sched.Next(); have && !next.After(clock.Now()) {
+			fmt.Println(clock.Now().Format("Mon 15:04"))
+		}
+		sched.Tick()
+	}
+	for i := 0; i < 15; i++ {
+		tick(time.Minute)
+	}
+
+	next, _ := sched.Next()
+	fmt.Println("next:", next.Format("Mon 15:04"))
+
+	// Cancel the fade on Monday, after it turns green
+	tick(next.Sub(clock.Now()))
+	tick(5 * time.Minute)
+	cancelFade()
+	tick(5 * time.Minute)
+	_, have := sched.Next()
+	fmt.Println("anything scheduled:", have)
+
+	// Runs missed while the clock jumps 3 days, from Monday 18:10 to Thursday 18:10, missing the 18:00 runs of Tuesday to Thursday
+	fmt.Println("\nMissed runs:")
+	for _, policy := range []MissedRunPolicy{Skip, RunOnce, RunAll} {
+		policy := policy
+		seq := NewSequenceInvoker().WithCommand(CommandFunc(func() { fmt.Println(policy, "run") }))
+		if _, err := sched.Schedule(seq, weekdays, policy); err != nil {
+			panic(err)
+		}
+	}
+	tick(3 * 24 * time.Hour)
+
+	// Delays are waited for by sleeping on the clock of the sequence
+	fmt.Println("\nInvoke with delays:")
+	fmt.Println(clock.Now().Format("Mon 15:04"))
+	fade.WithClock(clock).InvokeAndCount()
+	fmt.Println(clock.Now().Format("Mon 15:04"), "after", fade.Duration())
+
+	// Run a scheduler on the real clock
+	fmt.Println("\nReal clock:")
+	var (
+		realSched   = NewScheduler(RealClock{})
+		quick       = NewSequenceInvoker().WithCommand(ColourBlue(bulb)).WithDelay(20 * time.Millisecond).WithCommand(ColourRed(bulb))
+		ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	)
+	defer cancel()
+	if _, err := realSched.Schedule(quick, At(time.Now().Add(50*time.Millisecond)), Skip); err != nil {
+		panic(err)
+	}
+	realSched.Run(ctx)
+
+	// A sequence stops at a bulb that is offline, leaving the bulbs half updated
+	fmt.Println("\nFailures:")
+	var (
+		bulb4 = NewSmartBulb(4)
+		party = NewSequenceInvoker().
+			WithCommand(ColourRed(bulb)).
+			WithCommand(ColourRed(bulb2)).
+			WithCommand(ColourRed(bulb4)).
+			WithCommand(ColourRed(bulb3))
+	)
+	bulb4.SetOffline(true)
+	fmt.Println(party.InvokeAndCount())
+	fmt.Printf("bulb 1 is %+v, bulb 2 is %+v\n", bulb.State(), bulb2.State())
+
+	// In a transaction, the bulbs already coloured are restored, in reverse order
+	ColourBlue(bulb).Execute()
+	ColourBlue(bulb2).Execute()
+	party.WithTransaction(true)
+	fmt.Println(party.InvokeAndCount())
+	fmt.Printf("bulb 1 is %+v, bulb 2 is %+v\n", bulb.State(), bulb2.State())
+
+	// Once the bulb is online, the transaction succeeds
+	bulb4.SetOffline(false)
+	result := party.InvokeAndCount()
+	fmt.Println("ok:", result.OK())
+	successes, failures := party.Counts()
+	fmt.Println("successes:", successes, "failures:", failures)
+
+	// A panic is a failure, and a command that is not reversible cannot be compensated for
+	fmt.Println(NewSequenceInvoker().
+		WithTransaction(true).
+		WithCommand(CommandFunc(func() { fmt.Println("Not reversible") })).
+		WithCommand(SwitchOff(bulb)).
+		WithCommand(CommandFunc(func() { panic("Fuse blown") })).
+		InvokeAndCount())
+
+	// A history does not remember a command that fails
+	bulb4.SetOffline(true)
+	fmt.Println(h.Execute(SwitchOn(bulb4)), h)
 }
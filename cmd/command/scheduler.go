@@ -0,0 +1,322 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNoSuchSequence is the error returned when there is no sequence with the requested number
+	ErrNoSuchSequence = fmt.Errorf("No such sequence")
+	// ErrNeverFires is the error returned when scheduling a sequence with a trigger that never fires
+	ErrNeverFires = fmt.Errorf("Trigger never fires")
+)
+
+// MissedRunPolicy decides what a Scheduler does with runs it missed, such as while the program was not running.
+// A run is missed if the Scheduler notices it is due more than the grace period after it was due.
+type MissedRunPolicy uint
+
+// MissedRunPolicy constants
+const (
+	// Skip does not make up for missed runs
+	Skip MissedRunPolicy = iota
+	// RunOnce runs once for any number of missed runs
+	RunOnce
+	// RunAll runs once for each missed run
+	RunAll
+)
+
+var (
+	missedRunPolicyToString = map[MissedRunPolicy]string{
+		Skip:    "Skip",
+		RunOnce: "RunOnce",
+		RunAll:  "RunAll",
+	}
+)
+
+// String is MissedRunPolicy Stringer
+func (p MissedRunPolicy) String() string {
+	return missedRunPolicyToString[p]
+}
+
+// job is a scheduled sequence
+type job struct {
+	id      int
+	seq     *SequenceInvoker
+	trigger Trigger
+	policy  MissedRunPolicy
+	// next is when the trigger next fires, zero if it never fires again
+	next time.Time
+}
+
+// run is a sequence that is being executed, one command at a time as each delay passes
+type run struct {
+	jobID int
+	exec  *execution
+	// due is when the next command is due
+	due time.Time
+	// stepping is true while a Tick is executing the next command, without holding the mutex
+	stepping bool
+}
+
+// Scheduler executes sequences when their triggers fire.
+// Delays between the commands of a sequence do not hold up other sequences: each command is executed when it is due.
+//
+// Tick executes whatever is due, and Run calls Tick whenever something is due, so a test can advance a FakeClock and
+// call Tick instead of sleeping. Commands are executed without holding the lock of the Scheduler, so they can call it,
+// eg to schedule or cancel a sequence.
+//
+// It is safe for concurrent use.
+type Scheduler struct {
+	clock Clock
+	grace time.Duration
+
+	mu     sync.Mutex
+	jobs   map[int]*job
+	runs   []*run
+	nextID int
+	wake   chan struct{}
+}
+
+// NewScheduler constructs a Scheduler that uses a clock, with a grace period of 1 minute
+func NewScheduler(clock Clock) *Scheduler {
+	return &Scheduler{
+		clock: clock,
+		grace: time.Minute,
+		jobs:  map[int]*job{},
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// WithGrace sets how late a run can be before it is considered missed
+func (s *Scheduler) WithGrace(grace time.Duration) *Scheduler {
+	s.grace = grace
+	return s
+}
+
+// Schedule executes a sequence whenever a trigger fires, until the returned func is called.
+// Cancelling also stops any runs of the sequence that are waiting for a delay.
+// The delays of the sequence are waited for by the Scheduler, and the clock of the sequence is not used.
+func (s *Scheduler) Schedule(seq *SequenceInvoker, trigger Trigger, policy MissedRunPolicy) (cancel func(), err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next, fires := trigger.Next(s.clock.Now())
+	if !fires {
+		return nil, ErrNeverFires
+	}
+
+	id := s.nextID
+	s.nextID++
+	s.jobs[id] = &job{id: id, seq: seq, trigger: trigger, policy: policy, next: next}
+	s.signal()
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(s.jobs, id)
+
+		runs := s.runs[:0:0]
+		for _, r := range s.runs {
+			if r.jobID != id {
+				runs = append(runs, r)
+			}
+		}
+		s.runs = runs
+	}, nil
+}
+
+// signal wakes up Run, because something may be due sooner.
+// Must be called with the mutex held.
+func (s *Scheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Next returns when something is next due, or false if nothing is scheduled or running
+func (s *Scheduler) Next() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next time.Time
+	earliest := func(t time.Time) {
+		if !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+
+	for _, j := range s.jobs {
+		earliest(j.next)
+	}
+
+	for _, r := range s.runs {
+		earliest(r.due)
+	}
+
+	return next, !next.IsZero()
+}
+
+// Tick starts the runs of triggers that have fired, applying the missed run policies, then executes every command
+// that is due, in the order they are due
+func (s *Scheduler) Tick() {
+	now := s.clock.Now()
+	for _, e := range s.startRuns(now) {
+		e.finish()
+	}
+
+	// Execute the command that is due first, until none are due
+	for {
+		r := s.nextDue(now)
+		if r == nil {
+			break
+		}
+
+		r.exec.step()
+		if s.stepped(r) {
+			r.exec.finish()
+		}
+	}
+}
+
+// startRuns starts the runs of triggers that have fired, and returns the executions of any empty sequences, which are
+// already done
+func (s *Scheduler) startRuns(now time.Time) []*execution {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Start runs in the order jobs were scheduled
+	ids := make([]int, 0, len(s.jobs))
+	for id := range s.jobs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	var done []*execution
+	for _, id := range ids {
+		j := s.jobs[id]
+
+		onTime, missed := 0, 0
+		for !j.next.IsZero() && !j.next.After(now) {
+			if now.Sub(j.next) <= s.grace {
+				onTime++
+			} else {
+				missed++
+			}
+
+			next, fires := j.trigger.Next(j.next)
+			if !fires {
+				next = time.Time{}
+			}
+			j.next = next
+		}
+
+		starts := onTime
+		switch j.policy {
+		case RunOnce:
+			if (missed > 0) && (onTime == 0) {
+				starts = 1
+			}
+
+		case RunAll:
+			starts += missed
+		}
+
+		for i := 0; i < starts; i++ {
+			if e := s.start(j, now); e != nil {
+				done = append(done, e)
+			}
+		}
+
+		if j.next.IsZero() {
+			delete(s.jobs, id)
+		}
+	}
+
+	return done
+}
+
+// start starts a run of a job, returning its execution if the sequence is empty, so it is already done.
+// Must be called with the mutex held.
+func (s *Scheduler) start(j *job, now time.Time) *execution {
+	r := &run{jobID: j.id, exec: j.seq.newExecution()}
+	if r.exec.done() {
+		return r.exec
+	}
+
+	r.due = now.Add(j.seq.delays[0])
+	s.runs = append(s.runs, r)
+	return nil
+}
+
+// nextDue returns the run whose next command is due first, and marks it as stepping, or nil if none are due
+func (s *Scheduler) nextDue(now time.Time) *run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var first *run
+	for _, r := range s.runs {
+		if !r.stepping && !r.due.After(now) && ((first == nil) || r.due.Before(first.due)) {
+			first = r
+		}
+	}
+
+	if first != nil {
+		first.stepping = true
+	}
+
+	return first
+}
+
+// stepped updates a run after its next command has been executed, removing it if it is finished, and returns true if
+// it is finished. A run that was cancelled while its command was executed is not finished.
+func (s *Scheduler) stepped(r *run) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r.stepping = false
+
+	i := 0
+	for (i < len(s.runs)) && (s.runs[i] != r) {
+		i++
+	}
+
+	if i == len(s.runs) {
+		return false
+	}
+
+	if !r.exec.done() {
+		r.due = r.due.Add(r.exec.seq.delays[r.exec.index])
+		return false
+	}
+
+	s.runs = append(s.runs[:i:i], s.runs[i+1:]...)
+	return true
+}
+
+// Run calls Tick whenever something is due, until the context is done
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		s.Tick()
+
+		// Wait until something is due, or something is scheduled
+		var after <-chan time.Time
+		if next, have := s.Next(); have {
+			after = s.clock.After(next.Sub(s.clock.Now()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-after:
+		case <-s.wake:
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// counter is a Command that counts how many times it is executed
+type counter struct {
+	n int
+}
+
+// Execute is Command for counter
+func (c *counter) Execute() {
+	c.n++
+}
+
+// assertCount fails if a counter was not executed the expected number of times
+func assertCount(t *testing.T, what string, c *counter, want int) {
+	t.Helper()
+
+	if c.n != want {
+		t.Errorf("%s executed %d times, want %d", what, c.n, want)
+	}
+}
+
+// newTestScheduler returns a Scheduler with a grace period of one minute, and its FakeClock at 00:30 on a Monday
+func newTestScheduler() (*Scheduler, *FakeClock) {
+	clock := NewFakeClock(date(2024, 1, 1, 0, 30))
+	return NewScheduler(clock).WithGrace(time.Minute), clock
+}
+
+func TestSchedulerDelays(t *testing.T) {
+	var (
+		sched, clock = newTestScheduler()
+		first        = &counter{}
+		second       = &counter{}
+		seq          = NewSequenceInvoker().WithCommand(first).WithDelay(5 * time.Minute).WithCommand(second)
+	)
+
+	if _, err := sched.Schedule(seq, At(clock.Now().Add(time.Minute)), Skip); err != nil {
+		t.Fatal(err)
+	}
+
+	sched.Tick()
+	assertCount(t, "first", first, 0)
+
+	clock.Advance(time.Minute)
+	sched.Tick()
+	assertCount(t, "first", first, 1)
+	assertCount(t, "second", second, 0)
+
+	if next, have := sched.Next(); !have || !next.Equal(clock.Now().Add(5*time.Minute)) {
+		t.Errorf("next = %s %v, want the second command in 5 minutes", next, have)
+	}
+
+	clock.Advance(5 * time.Minute)
+	sched.Tick()
+	assertCount(t, "second", second, 1)
+
+	if successes, failures := seq.Counts(); (successes != 1) || (failures != 0) {
+		t.Errorf("counts = %d, %d, want 1, 0", successes, failures)
+	}
+
+	if next, have := sched.Next(); have {
+		t.Errorf("next = %s, want nothing scheduled", next)
+	}
+}
+
+func TestSchedulerMissedRuns(t *testing.T) {
+	hourly, err := ParseCron("0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		policy MissedRunPolicy
+		missed int
+	}{
+		{Skip, 0},
+		{RunOnce, 1},
+		{RunAll, 3},
+	} {
+		var (
+			sched, clock = newTestScheduler()
+			c            = &counter{}
+		)
+
+		if _, err := sched.Schedule(NewSequenceInvoker().WithCommand(c), hourly, test.policy); err != nil {
+			t.Fatal(err)
+		}
+
+		// 01:00, 02:00 and 03:00 are missed
+		clock.Advance(3 * time.Hour)
+		sched.Tick()
+		assertCount(t, test.policy.String()+" missed", c, test.missed)
+
+		// 04:00 is on time, within the grace period
+		clock.Advance(30*time.Minute + 30*time.Second)
+		sched.Tick()
+		assertCount(t, test.policy.String()+" on time", c, test.missed+1)
+	}
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	var (
+		sched, clock = newTestScheduler()
+		first        = &counter{}
+		second       = &counter{}
+		seq          = NewSequenceInvoker().WithCommand(first).WithDelay(time.Minute).WithCommand(second)
+	)
+
+	everyMinute, err := ParseCron("* * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel, err := sched.Schedule(seq, everyMinute, Skip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(time.Minute)
+	sched.Tick()
+	assertCount(t, "first", first, 1)
+
+	// Cancelling stops the run that is waiting for its delay, and future runs
+	cancel()
+	clock.Advance(time.Hour)
+	sched.Tick()
+	assertCount(t, "first", first, 1)
+	assertCount(t, "second", second, 0)
+
+	if next, have := sched.Next(); have {
+		t.Errorf("next = %s, want nothing scheduled", next)
+	}
+}
+
+func TestSchedulerCommandCallsScheduler(t *testing.T) {
+	var (
+		sched, clock = newTestScheduler()
+		later        = &counter{}
+		cancel       func()
+	)
+
+	// A command can schedule another sequence, and cancel its own
+	seq := NewSequenceInvoker().WithCommand(CommandFunc(func() {
+		sched.Schedule(NewSequenceInvoker().WithCommand(later), At(clock.Now().Add(time.Minute)), Skip)
+		cancel()
+	}))
+
+	everyMinute, err := ParseCron("* * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cancel, err = sched.Schedule(seq, everyMinute, Skip); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for i := 0; i < 3; i++ {
+			clock.Advance(time.Minute)
+			sched.Tick()
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Tick deadlocked when a command called the Scheduler")
+	}
+
+	assertCount(t, "scheduled by a command", later, 1)
+}
+
+func TestSchedulerNeverFires(t *testing.T) {
+	sched, clock := newTestScheduler()
+
+	_, err := sched.Schedule(NewSequenceInvoker(), At(clock.Now().Add(-time.Minute)), Skip)
+	if !errors.Is(err, ErrNeverFires) {
+		t.Errorf("err = %v, want ErrNeverFires", err)
+	}
+
+	never, err := ParseCron("0 0 31 feb *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = sched.Schedule(NewSequenceInvoker(), never, Skip); !errors.Is(err, ErrNeverFires) {
+		t.Errorf("err = %v, want ErrNeverFires", err)
+	}
+}
@@ -0,0 +1,208 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// colours are the colours a SmartBulb can select, keyed by lower case name
+var colours = map[string]string{
+	"red":   "Red",
+	"green": "Green",
+	"blue":  "Blue",
+}
+
+// CommandSpec describes a BulbCommand as data, so that sequences can be saved and loaded as JSON.
+// Op is switch, with a Value of on or off, or colour, with a Value of red, green or blue.
+type CommandSpec struct {
+	Bulb  int    `json:"bulb"`
+	Op    string `json:"op"`
+	Value string `json:"value"`
+}
+
+// Spec returns the CommandSpec that describes the command
+func (c *BulbCommand) Spec() CommandSpec {
+	spec := CommandSpec{Bulb: c.bulb.ID, Op: c.op.String(), Value: strings.ToLower(c.colour)}
+	if c.op == SwitchOp {
+		spec.Value = "off"
+		if c.on {
+			spec.Value = "on"
+		}
+	}
+
+	return spec
+}
+
+// Command returns the BulbCommand described by the spec, for one of the given bulbs keyed by ID.
+// Op and Value are not case sensitive.
+func (s CommandSpec) Command(bulbs map[int]*SmartBulb) (*BulbCommand, error) {
+	bulb, have := bulbs[s.Bulb]
+	if !have {
+		return nil, fmt.Errorf("unknown bulb %d", s.Bulb)
+	}
+
+	value := strings.ToLower(s.Value)
+	switch strings.ToLower(s.Op) {
+	case SwitchOp.String():
+		if (value != "on") && (value != "off") {
+			return nil, fmt.Errorf("switch value %q must be on or off", s.Value)
+		}
+
+		return &BulbCommand{bulb: bulb, op: SwitchOp, on: value == "on"}, nil
+
+	case ColourOp.String():
+		colour, have := colours[value]
+		if !have {
+			names := make([]string, 0, len(colours))
+			for name := range colours {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			return nil, fmt.Errorf("colour value %q must be one of %s", s.Value, strings.Join(names, ", "))
+		}
+
+		return &BulbCommand{bulb: bulb, op: ColourOp, colour: colour}, nil
+
+	default:
+		return nil, fmt.Errorf("op %q must be switch or colour", s.Op)
+	}
+}
+
+// SpecError is a problem with one CommandSpec of a sequence, which is at Index, counting from 0
+type SpecError struct {
+	Index   int
+	Message string
+}
+
+// Error is error for SpecError
+func (e SpecError) Error() string {
+	return fmt.Sprintf("command %d: %s", e.Index, e.Message)
+}
+
+// SpecErrors is all the problems found in a sequence
+type SpecErrors []SpecError
+
+// Error is error for SpecErrors, with one problem per line
+func (e SpecErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// BuildSequence returns a SequenceInvoker that executes the commands described by specs, for the given bulbs keyed
+// by ID. If any spec is invalid, the error is SpecErrors describing every problem.
+func BuildSequence(specs []CommandSpec, bulbs map[int]*SmartBulb) (*SequenceInvoker, error) {
+	var (
+		seq      = NewSequenceInvoker()
+		problems SpecErrors
+	)
+
+	for i, spec := range specs {
+		cmd, err := spec.Command(bulbs)
+		if err != nil {
+			problems = append(problems, SpecError{Index: i, Message: err.Error()})
+			continue
+		}
+
+		seq.WithCommand(cmd)
+	}
+
+	if len(problems) > 0 {
+		return nil, problems
+	}
+
+	return seq, nil
+}
+
+// LoadSpecs reads a JSON array of CommandSpecs from a file
+func LoadSpecs(filename string) ([]CommandSpec, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		dec   = json.NewDecoder(bytes.NewReader(data))
+		specs []CommandSpec
+	)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&specs); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return specs, nil
+}
+
+// SaveSpecs writes CommandSpecs to a file as a JSON array, one command per line
+func SaveSpecs(filename string, specs []CommandSpec) error {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i, spec := range specs {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+
+		data, err := json.Marshal(spec)
+		if err != nil {
+			return err
+		}
+
+		buf.WriteString("\n    ")
+		buf.Write(data)
+	}
+	buf.WriteString("\n]\n")
+
+	return ioutil.WriteFile(filename, buf.Bytes(), 0644)
+}
+
+// Recorder executes BulbCommands, and while it is recording, captures them as CommandSpecs that can be saved as a
+// sequence file
+type Recorder struct {
+	recording bool
+	specs     []CommandSpec
+}
+
+// Start starts recording, discarding anything recorded before
+func (r *Recorder) Start() {
+	r.recording, r.specs = true, nil
+}
+
+// Stop stops recording, and returns what was recorded
+func (r *Recorder) Stop() []CommandSpec {
+	r.recording = false
+	return r.specs
+}
+
+// Recording returns true if the recorder is recording
+func (r *Recorder) Recording() bool {
+	return r.recording
+}
+
+// Execute executes a command, and captures it if the recorder is recording and the command succeeds.
+// A command that fails, such as one for an offline bulb, is not captured, and its error is returned.
+func (r *Recorder) Execute(cmd *BulbCommand) error {
+	if err := cmd.TryExecute(); err != nil {
+		return err
+	}
+
+	if r.recording {
+		r.specs = append(r.specs, cmd.Spec())
+	}
+
+	return nil
+}
+
+// Save writes what has been recorded to a file
+func (r *Recorder) Save(filename string) error {
+	return SaveSpecs(filename, r.specs)
+}
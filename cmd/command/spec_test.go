@@ -0,0 +1,32 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	var (
+		rec     Recorder
+		bulb    = NewSmartBulb(1)
+		offline = &SmartBulb{ID: 2, offline: true}
+	)
+
+	rec.Start()
+	for _, cmd := range []*BulbCommand{SwitchOn(bulb), SwitchOn(offline), ColourRed(bulb)} {
+		err := rec.Execute(cmd)
+		if (cmd.bulb == offline) != errors.Is(err, ErrOffline) {
+			t.Errorf("bulb %d: err = %v", cmd.bulb.ID, err)
+		}
+	}
+
+	// The command for the offline bulb is not recorded
+	want := []CommandSpec{SwitchOn(bulb).Spec(), ColourRed(bulb).Spec()}
+	if got := rec.Stop(); !reflect.DeepEqual(got, want) {
+		t.Errorf("recorded %+v, want %+v", got, want)
+	}
+	assertState(t, bulb, BulbState{On: true, Colour: "Red"})
+}
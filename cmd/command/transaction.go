@@ -0,0 +1,217 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrOffline is the error of a command for a bulb that is offline
+	ErrOffline = fmt.Errorf("Bulb is offline")
+	// ErrNotReversible is the error of compensating for a command that cannot be undone
+	ErrNotReversible = fmt.Errorf("Command is not reversible")
+)
+
+// FallibleCommand is a Command that can fail, returning an error instead of panicking
+type FallibleCommand interface {
+	Command
+	TryExecute() error
+}
+
+// tryExecute executes a command, using TryExecute if it is a FallibleCommand, and returns an error if it fails or
+// panics
+func tryExecute(cmd Command) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	if fc, isa := cmd.(FallibleCommand); isa {
+		return fc.TryExecute()
+	}
+
+	cmd.Execute()
+	return nil
+}
+
+// CommandStatus is what happened to one command of a sequence
+type CommandStatus uint
+
+// CommandStatus constants
+const (
+	// NotRun means the command was not executed, because an earlier command failed
+	NotRun CommandStatus = iota
+	// Succeeded means the command was executed
+	Succeeded
+	// Failed means the command failed
+	Failed
+	// Compensated means the command was executed, then undone because a later command failed
+	Compensated
+	// CompensationFailed means the command was executed, but could not be undone when a later command failed
+	CompensationFailed
+)
+
+var (
+	commandStatusToString = map[CommandStatus]string{
+		NotRun:             "not run",
+		Succeeded:          "succeeded",
+		Failed:             "failed",
+		Compensated:        "compensated",
+		CompensationFailed: "compensation failed",
+	}
+)
+
+// String is CommandStatus Stringer
+func (s CommandStatus) String() string {
+	return commandStatusToString[s]
+}
+
+// CommandResult is the result of one command of a sequence.
+// Err is the error of a command that failed, or that could not be compensated for.
+type CommandResult struct {
+	Command Command
+	Status  CommandStatus
+	Err     error
+}
+
+// String is CommandResult Stringer, describing the command by its type if it is not a Stringer
+func (r CommandResult) String() string {
+	desc := fmt.Sprintf("%T", r.Command)
+	if s, isa := r.Command.(fmt.Stringer); isa {
+		desc = s.String()
+	}
+
+	if r.Err != nil {
+		return fmt.Sprintf("%s: %s (%s)", desc, r.Status, r.Err)
+	}
+
+	return fmt.Sprintf("%s: %s", desc, r.Status)
+}
+
+// SequenceResult is the result of executing a sequence, with the result of each command in order
+type SequenceResult struct {
+	Results []CommandResult
+}
+
+// OK returns true if every command succeeded.
+// A sequence with a command whose status is CompensationFailed is not OK, and has left some changes in place.
+func (r SequenceResult) OK() bool {
+	for _, result := range r.Results {
+		if result.Status != Succeeded {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NotCompensated returns the number of commands that could not be compensated for
+func (r SequenceResult) NotCompensated() int {
+	n := 0
+	for _, result := range r.Results {
+		if result.Status == CompensationFailed {
+			n++
+		}
+	}
+
+	return n
+}
+
+// String is SequenceResult Stringer, with one command per line,
+// followed by a warning if any commands could not be compensated for
+func (r SequenceResult) String() string {
+	strs := make([]string, len(r.Results))
+	for i, result := range r.Results {
+		strs[i] = result.String()
+	}
+
+	if n := r.NotCompensated(); n > 0 {
+		strs = append(strs, fmt.Sprintf("%d command(s) could not be compensated for", n))
+	}
+
+	return strings.Join(strs, "\n")
+}
+
+// execution is one execution of a sequence, executing one command at a time
+type execution struct {
+	seq     *SequenceInvoker
+	results []CommandResult
+	// inverses undo the commands that have been executed, and are nil for commands that are not reversible
+	inverses []Command
+	// index is the next command to execute
+	index int
+}
+
+// newExecution starts an execution of the sequence
+func (s *SequenceInvoker) newExecution() *execution {
+	results := make([]CommandResult, len(s.commands))
+	for i, cmd := range s.commands {
+		results[i] = CommandResult{Command: cmd}
+	}
+
+	return &execution{seq: s, results: results, inverses: make([]Command, len(results))}
+}
+
+// done returns true if there are no more commands to execute
+func (e *execution) done() bool {
+	return e.index >= len(e.results)
+}
+
+// step executes the next command. If it fails, the execution is done, and in a transaction the commands already
+// executed are compensated for in reverse order.
+func (e *execution) step() {
+	var (
+		result = &e.results[e.index]
+		err    error
+	)
+
+	if rc, isa := result.Command.(ReversibleCommand); isa {
+		e.inverses[e.index], err = tryExecuteReversible(rc)
+	} else {
+		err = tryExecute(result.Command)
+	}
+
+	if err != nil {
+		result.Status, result.Err = Failed, err
+		if e.seq.transactional {
+			e.compensate()
+		}
+
+		e.index = len(e.results)
+		return
+	}
+
+	result.Status = Succeeded
+	e.index++
+}
+
+// compensate undoes the commands executed before the current command, in reverse order
+func (e *execution) compensate() {
+	for i := e.index - 1; i >= 0; i-- {
+		result := &e.results[i]
+
+		inverse := e.inverses[i]
+		if inverse == nil {
+			result.Status, result.Err = CompensationFailed, ErrNotReversible
+			continue
+		}
+
+		if err := tryExecute(inverse); err != nil {
+			result.Status, result.Err = CompensationFailed, err
+			continue
+		}
+
+		result.Status = Compensated
+	}
+}
+
+// finish counts the execution as a success or failure, and returns the result
+func (e *execution) finish() SequenceResult {
+	result := SequenceResult{Results: e.results}
+	e.seq.finished(result.OK())
+
+	return result
+}
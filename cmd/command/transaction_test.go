@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTransactionCompensation(t *testing.T) {
+	var (
+		bulb    = NewSmartBulb(1)
+		offline = &SmartBulb{ID: 2, offline: true}
+	)
+
+	result := NewSequenceInvoker().
+		WithTransaction(true).
+		WithCommand(CommandFunc(func() {})).
+		WithCommand(undoCommand{}).
+		WithCommand(SwitchOn(bulb)).
+		WithCommand(SwitchOn(offline)).
+		InvokeAndCount()
+
+	want := []CommandStatus{CompensationFailed, CompensationFailed, Compensated, Failed}
+	for i, status := range want {
+		if got := result.Results[i].Status; got != status {
+			t.Errorf("command %d is %s, want %s", i, got, status)
+		}
+	}
+
+	if err := result.Results[0].Err; !errors.Is(err, ErrNotReversible) {
+		t.Errorf("err = %v, want ErrNotReversible", err)
+	}
+
+	if err := result.Results[1].Err; (err == nil) || !strings.Contains(err.Error(), errUndo.Error()) {
+		t.Errorf("err = %v, want %v", err, errUndo)
+	}
+	assertState(t, bulb, BulbState{})
+
+	if result.OK() {
+		t.Error("result should not be OK")
+	}
+
+	if n := result.NotCompensated(); n != 2 {
+		t.Errorf("%d commands not compensated, want 2", n)
+	}
+
+	if str := result.String(); !strings.HasSuffix(str, "2 command(s) could not be compensated for") {
+		t.Errorf("String() = %q, should report the commands not compensated", str)
+	}
+}
+
+func TestTransactionCompensated(t *testing.T) {
+	bulb := NewSmartBulb(1)
+
+	result := NewSequenceInvoker().
+		WithTransaction(true).
+		WithCommand(SwitchOn(bulb)).
+		WithCommand(CommandFunc(func() { panic("Fuse blown") })).
+		InvokeAndCount()
+
+	if got := result.Results[0].Status; got != Compensated {
+		t.Errorf("command 0 is %s, want %s", got, Compensated)
+	}
+
+	if n := result.NotCompensated(); n != 0 {
+		t.Errorf("%d commands not compensated, want 0", n)
+	}
+
+	if strings.Contains(result.String(), "could not be compensated") {
+		t.Errorf("String() = %q, should not report commands not compensated", result.String())
+	}
+	assertState(t, bulb, BulbState{})
+}
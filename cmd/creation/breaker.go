@@ -0,0 +1,194 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is the error returned without calling a service while its circuit breaker is open
+var ErrCircuitOpen = fmt.Errorf("Circuit breaker is open")
+
+// CircuitState is the state of a circuit breaker
+type CircuitState uint
+
+// CircuitState constants
+const (
+	// Closed allows calls, and opens after too many consecutive failures
+	Closed CircuitState = iota
+	// Open rejects calls, and becomes half open after a while
+	Open
+	// HalfOpen allows a limited number of trial calls, and closes after enough successes, or opens after any failure
+	HalfOpen
+)
+
+var (
+	circuitStateToString = map[CircuitState]string{
+		Closed:   "closed",
+		Open:     "open",
+		HalfOpen: "half-open",
+	}
+)
+
+// String is CircuitState Stringer
+func (s CircuitState) String() string {
+	return circuitStateToString[s]
+}
+
+// CircuitBreaker stops calling a service that keeps failing, so that callers fail fast instead of waiting for
+// timeouts, and the service has time to recover.
+//
+// It is safe for concurrent use.
+type CircuitBreaker struct {
+	// failureThreshold is the number of consecutive failures that opens the circuit
+	failureThreshold int
+	// openFor is how long the circuit stays open before allowing trial calls
+	openFor time.Duration
+	// halfOpenCalls is the number of successful trial calls that closes the circuit
+	halfOpenCalls int
+	now           func() time.Time
+
+	mu        sync.Mutex
+	state     CircuitState
+	failures  int
+	successes int
+	inFlight  int
+	openedAt  time.Time
+}
+
+// NewCircuitBreaker constructs a closed CircuitBreaker.
+// Zero or negative values are replaced with the defaults of 5 failures, open for 30 seconds, and 1 half open call.
+func NewCircuitBreaker(failureThreshold int, openFor time.Duration, halfOpenCalls int) *CircuitBreaker {
+	if failureThreshold <= 0 {
+		failureThreshold = 5
+	}
+
+	if openFor <= 0 {
+		openFor = 30 * time.Second
+	}
+
+	if halfOpenCalls <= 0 {
+		halfOpenCalls = 1
+	}
+
+	return &CircuitBreaker{
+		failureThreshold: failureThreshold,
+		openFor:          openFor,
+		halfOpenCalls:    halfOpenCalls,
+		now:              time.Now,
+	}
+}
+
+// WithClock overrides the default clock of time.Now, so that tests can control when an open circuit becomes half open
+func (cb *CircuitBreaker) WithClock(now func() time.Time) *CircuitBreaker {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.now = now
+	return cb
+}
+
+// State returns the current state, which becomes half open once the circuit has been open long enough
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.update()
+	return cb.state
+}
+
+// ConsecutiveFailures returns the number of failures since the last success
+func (cb *CircuitBreaker) ConsecutiveFailures() int {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return cb.failures
+}
+
+// update moves an open circuit to half open once it has been open long enough.
+// Must be called with the mutex held.
+func (cb *CircuitBreaker) update() {
+	if (cb.state == Open) && !cb.now().Before(cb.openedAt.Add(cb.openFor)) {
+		cb.state, cb.successes, cb.inFlight = HalfOpen, 0, 0
+	}
+}
+
+// Allow returns true if a call may be made, which must then be followed by Done.
+// A half open circuit allows only as many calls at a time as it needs successes to close.
+func (cb *CircuitBreaker) Allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.update()
+	switch cb.state {
+	case Open:
+		return false
+
+	case HalfOpen:
+		if cb.inFlight >= cb.halfOpenCalls-cb.successes {
+			return false
+		}
+		cb.inFlight++
+	}
+
+	return true
+}
+
+// Done records the result of a call that was allowed
+func (cb *CircuitBreaker) Done(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if (cb.state == HalfOpen) && (cb.inFlight > 0) {
+		cb.inFlight--
+	}
+
+	cb.record(err)
+}
+
+// Release ends a call that was allowed without recording a result, eg because the caller gave up
+func (cb *CircuitBreaker) Release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if (cb.state == HalfOpen) && (cb.inFlight > 0) {
+		cb.inFlight--
+	}
+}
+
+// Record records the result of a health probe, which counts as a call that was not limited by Allow
+func (cb *CircuitBreaker) Record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.update()
+	if (err == nil) && (cb.state == Open) {
+		// A healthy probe allows trial calls without waiting for the rest of the open period
+		cb.state, cb.successes, cb.inFlight = HalfOpen, 0, 0
+		return
+	}
+
+	cb.record(err)
+}
+
+// record updates the state for the result of a call or probe.
+// Must be called with the mutex held.
+func (cb *CircuitBreaker) record(err error) {
+	if err != nil {
+		cb.failures++
+		if (cb.state == HalfOpen) || ((cb.state == Closed) && (cb.failures >= cb.failureThreshold)) {
+			cb.state, cb.openedAt = Open, cb.now()
+		}
+
+		return
+	}
+
+	cb.failures = 0
+	if cb.state == HalfOpen {
+		if cb.successes++; cb.successes >= cb.halfOpenCalls {
+			cb.state = Closed
+		}
+	}
+}
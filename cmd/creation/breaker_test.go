@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// errTest is the error of a failed call
+var errTest = fmt.Errorf("Test failure")
+
+// newTestBreaker returns a CircuitBreaker with a clock that the test moves forward
+func newTestBreaker(failureThreshold int, openFor time.Duration, halfOpenCalls int) (*CircuitBreaker, *time.Time) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	cb := NewCircuitBreaker(failureThreshold, openFor, halfOpenCalls).WithClock(func() time.Time { return now })
+
+	return cb, &now
+}
+
+// call makes a call through a breaker, and fails if it is not allowed
+func call(t *testing.T, cb *CircuitBreaker, err error) {
+	t.Helper()
+
+	if !cb.Allow() {
+		t.Fatalf("a %s circuit did not allow a call", cb.State())
+	}
+	cb.Done(err)
+}
+
+// assertState fails if a breaker is not in the expected state
+func assertState(t *testing.T, cb *CircuitBreaker, want CircuitState) {
+	t.Helper()
+
+	if got := cb.State(); got != want {
+		t.Fatalf("state %s, want %s", got, want)
+	}
+}
+
+func TestBreakerOpens(t *testing.T) {
+	cb, _ := newTestBreaker(3, time.Minute, 1)
+
+	// A success resets the consecutive failures
+	call(t, cb, errTest)
+	call(t, cb, errTest)
+	call(t, cb, nil)
+	if n := cb.ConsecutiveFailures(); n != 0 {
+		t.Errorf("%d consecutive failures after a success", n)
+	}
+
+	call(t, cb, errTest)
+	call(t, cb, errTest)
+	assertState(t, cb, Closed)
+
+	call(t, cb, errTest)
+	assertState(t, cb, Open)
+	if cb.Allow() {
+		t.Error("an open circuit allowed a call")
+	}
+
+	if n := cb.ConsecutiveFailures(); n != 3 {
+		t.Errorf("%d consecutive failures, want 3", n)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	cb, now := newTestBreaker(1, time.Minute, 2)
+	call(t, cb, errTest)
+
+	*now = now.Add(time.Minute - time.Nanosecond)
+	assertState(t, cb, Open)
+
+	*now = now.Add(time.Nanosecond)
+	assertState(t, cb, HalfOpen)
+
+	// Only as many trial calls as successes needed are allowed at a time, and a released call frees its place
+	if !cb.Allow() || !cb.Allow() {
+		t.Fatal("a half open circuit did not allow its trial calls")
+	}
+	if cb.Allow() {
+		t.Error("a half open circuit allowed too many trial calls")
+	}
+
+	cb.Release()
+	if !cb.Allow() {
+		t.Error("a released trial call was not replaced")
+	}
+
+	// Enough successes close the circuit
+	cb.Done(nil)
+	assertState(t, cb, HalfOpen)
+	cb.Done(nil)
+	assertState(t, cb, Closed)
+}
+
+func TestBreakerHalfOpenFails(t *testing.T) {
+	cb, now := newTestBreaker(3, time.Minute, 2)
+	for i := 0; i < 3; i++ {
+		call(t, cb, errTest)
+	}
+
+	// Any failure reopens a half open circuit, for the whole open period again
+	*now = now.Add(time.Minute)
+	call(t, cb, nil)
+	call(t, cb, errTest)
+	assertState(t, cb, Open)
+
+	*now = now.Add(time.Minute - time.Nanosecond)
+	assertState(t, cb, Open)
+
+	*now = now.Add(time.Nanosecond)
+	call(t, cb, nil)
+	call(t, cb, nil)
+	assertState(t, cb, Closed)
+
+	// Once closed, the circuit takes the full threshold of failures to open again
+	call(t, cb, errTest)
+	call(t, cb, errTest)
+	assertState(t, cb, Closed)
+}
+
+func TestBreakerRecord(t *testing.T) {
+	cb, _ := newTestBreaker(2, time.Minute, 1)
+
+	// Probe failures count towards the threshold
+	cb.Record(errTest)
+	cb.Record(errTest)
+	assertState(t, cb, Open)
+
+	// A healthy probe half opens the circuit without waiting
+	cb.Record(nil)
+	assertState(t, cb, HalfOpen)
+
+	cb.Record(nil)
+	assertState(t, cb, Closed)
+}
+
+func TestBreakerDefaults(t *testing.T) {
+	cb, now := newTestBreaker(0, 0, -1)
+
+	for i := 0; i < 4; i++ {
+		call(t, cb, errTest)
+	}
+	assertState(t, cb, Closed)
+
+	call(t, cb, errTest)
+	assertState(t, cb, Open)
+
+	*now = now.Add(30 * time.Second)
+	if !cb.Allow() || cb.Allow() {
+		t.Error("a half open circuit should allow one trial call")
+	}
+}
+
+func TestCircuitStateString(t *testing.T) {
+	for state, want := range map[CircuitState]string{Closed: "closed", Open: "open", HalfOpen: "half-open"} {
+		if got := state.String(); got != want {
+			t.Errorf("%d: %q, want %q", state, got, want)
+		}
+	}
+}
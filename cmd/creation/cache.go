@@ -0,0 +1,212 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheEntry is the cached response of a service
+type cacheEntry struct {
+	products []Product
+	etag     string
+	expires  time.Time
+}
+
+// fetcher is a service that can revalidate cached products with an ETag, and returns the caching headers of the
+// response, such as a RemoteService
+type fetcher interface {
+	fetch(ctx context.Context, etag string) ([]Product, fetched, error)
+}
+
+// cacheCall is a call to the cached service that is in flight, shared by all callers that need it
+type cacheCall struct {
+	// entry is the cache entry the call revalidates, if any
+	entry   *cacheEntry
+	cancel  context.CancelFunc
+	waiters int
+
+	// done is closed once products and err are set
+	done     chan struct{}
+	products []Product
+	err      error
+}
+
+// CachingService is a service that caches the products of a RemoteService, as directed by the HTTP Cache-Control and
+// ETag response headers:
+// - products are returned from the cache until Cache-Control max-age expires
+// - once expired, or if Cache-Control is no-cache, the request is sent with If-None-Match, and 304 Not Modified
+// refreshes the cached products
+// - if Cache-Control is no-store, or there is no max-age or ETag, nothing is cached
+//
+// The RemoteService may be wrapped, eg in a MonitoredService. A service that does not provide caching headers, such as
+// a LocalService, is called every time.
+//
+// It is safe for concurrent use, and must be used by pointer.
+type CachingService struct {
+	svc   Service
+	now   func() time.Time
+	mu    sync.Mutex
+	entry *cacheEntry
+	call  *cacheCall
+}
+
+// WithRemote sets the service to cache, and clears the cache
+func (cs *CachingService) WithRemote(svc Service) *CachingService {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.svc = svc
+	cs.entry = nil
+	cs.call = nil
+	return cs
+}
+
+// WithClock overrides the default clock of time.Now, so that tests can control when cached products expire
+func (cs *CachingService) WithClock(now func() time.Time) *CachingService {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.now = now
+	return cs
+}
+
+// clock returns the clock.
+// Must be called with the mutex held.
+func (cs *CachingService) clock() func() time.Time {
+	if cs.now == nil {
+		return time.Now
+	}
+
+	return cs.now
+}
+
+// Call returns Products from the cache, or from the cached service
+func (cs *CachingService) Call() ([]Product, error) {
+	return cs.CallContext(context.Background())
+}
+
+// CallContext returns Products from the cache, or from the cached service.
+// Concurrent callers share one call to the service, and each stops waiting for it as soon as its context is done.
+// The call is cancelled once no caller is waiting for it.
+func (cs *CachingService) CallContext(ctx context.Context) ([]Product, error) {
+	cs.mu.Lock()
+	if (cs.entry != nil) && cs.clock()().Before(cs.entry.expires) {
+		products := cs.entry.products
+		cs.mu.Unlock()
+		return products, nil
+	}
+
+	call := cs.call
+	if call == nil {
+		callCtx, cancel := context.WithCancel(context.Background())
+		call = &cacheCall{entry: cs.entry, cancel: cancel, done: make(chan struct{})}
+		cs.call = call
+		go cs.fetch(callCtx, call, cs.svc)
+	}
+	call.waiters++
+	cs.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.products, call.err
+
+	case <-ctx.Done():
+		cs.mu.Lock()
+		defer cs.mu.Unlock()
+
+		if call.waiters--; call.waiters == 0 {
+			call.cancel()
+			// Later callers start a new call, rather than wait for this one to be cancelled
+			if cs.call == call {
+				cs.call = nil
+			}
+		}
+
+		return nil, ctx.Err()
+	}
+}
+
+// fetch calls the cached service for a call, updates the cache, and tells the callers waiting for it
+func (cs *CachingService) fetch(ctx context.Context, call *cacheCall, svc Service) {
+	defer call.cancel()
+
+	var (
+		products []Product
+		resp     fetched
+		err      error
+	)
+
+	if f, isa := svc.(fetcher); isa {
+		var etag string
+		if call.entry != nil {
+			etag = call.entry.etag
+		}
+
+		products, resp, err = f.fetch(ctx, etag)
+		if (err == nil) && resp.notModified {
+			products = call.entry.products
+			if resp.etag == "" {
+				resp.etag = etag
+			}
+		}
+	} else {
+		products, err = svc.CallContext(ctx)
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	// Only the current call updates the cache, not one that was abandoned or cleared by WithRemote
+	if (err == nil) && (cs.call == call) {
+		maxAge, store := parseCacheControl(resp.cacheControl)
+		switch {
+		case !store || ((maxAge == 0) && (resp.etag == "")):
+			cs.entry = nil
+		default:
+			cs.entry = &cacheEntry{products: products, etag: resp.etag, expires: cs.clock()().Add(maxAge)}
+		}
+	}
+
+	if cs.call == call {
+		cs.call = nil
+	}
+
+	call.products, call.err = products, err
+	close(call.done)
+}
+
+// parseCacheControl returns the max-age of a Cache-Control header value, and false if the response must not be stored.
+// no-cache and a missing or invalid max-age are a max-age of 0, which means every use must be revalidated.
+func parseCacheControl(cacheControl string) (time.Duration, bool) {
+	var (
+		maxAge  time.Duration
+		noCache bool
+	)
+
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store":
+			return 0, false
+
+		case directive == "no-cache":
+			noCache = true
+
+		case strings.HasPrefix(directive, "max-age="):
+			if seconds, err := strconv.Atoi(directive[len("max-age="):]); (err == nil) && (seconds > 0) {
+				maxAge = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+
+	if noCache {
+		maxAge = 0
+	}
+
+	return maxAge, true
+}
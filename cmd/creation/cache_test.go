@@ -0,0 +1,191 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// cachedServer is a server that sends an ETag and a max-age of 60 seconds, and counts its requests
+type cachedServer struct {
+	*httptest.Server
+	requests    int32
+	notModified int32
+	// release, if not nil, is waited for before each response
+	release chan struct{}
+	// cancelled is closed if a request is cancelled by the client
+	cancelled chan struct{}
+	status    int32
+}
+
+// newCachedServer starts a cachedServer, which is closed when the test finishes
+func newCachedServer(t *testing.T, release chan struct{}) *cachedServer {
+	t.Helper()
+
+	cs := &cachedServer{release: release, cancelled: make(chan struct{}), status: http.StatusOK}
+	cs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&cs.requests, 1)
+
+		if cs.release != nil {
+			select {
+			case <-cs.release:
+			case <-r.Context().Done():
+				close(cs.cancelled)
+				return
+			}
+		}
+
+		if status := int(atomic.LoadInt32(&cs.status)); status != http.StatusOK {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+
+		const etag = `"v1"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.Header.Get("If-None-Match") == etag {
+			atomic.AddInt32(&cs.notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		json.NewEncoder(w).Encode([]Product{{Name: "Tangerines", Price: "3.25/lb"}})
+	}))
+	t.Cleanup(cs.Close)
+
+	return cs
+}
+
+// assertCounts fails if the server did not receive the expected number of requests and revalidations
+func (cs *cachedServer) assertCounts(t *testing.T, requests, notModified int32) {
+	t.Helper()
+
+	if got := atomic.LoadInt32(&cs.requests); got != requests {
+		t.Errorf("server received %d requests, want %d", got, requests)
+	}
+
+	if got := atomic.LoadInt32(&cs.notModified); got != notModified {
+		t.Errorf("server sent %d not modified responses, want %d", got, notModified)
+	}
+}
+
+// callCache calls a CachingService, failing if it does not return the server products
+func callCache(t *testing.T, cached *CachingService) {
+	t.Helper()
+
+	products, err := cached.Call()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if (len(products) != 1) || (products[0].Name != "Tangerines") {
+		t.Errorf("products = %+v", products)
+	}
+}
+
+func TestCachingServiceExpiry(t *testing.T) {
+	srv := newCachedServer(t, nil)
+
+	now := time.Now()
+	cached := (&CachingService{}).
+		WithRemote(*(&RemoteService{}).WithRemote(srv.URL)).
+		WithClock(func() time.Time { return now })
+
+	callCache(t, cached)
+	srv.assertCounts(t, 1, 0)
+
+	now = now.Add(30 * time.Second)
+	callCache(t, cached)
+	srv.assertCounts(t, 1, 0)
+
+	now = now.Add(31 * time.Second)
+	callCache(t, cached)
+	srv.assertCounts(t, 2, 1)
+
+	callCache(t, cached)
+	srv.assertCounts(t, 2, 1)
+}
+
+func TestCachingServiceSharesCalls(t *testing.T) {
+	var (
+		release = make(chan struct{})
+		srv     = newCachedServer(t, release)
+		cached  = (&CachingService{}).WithRemote(*(&RemoteService{}).WithRemote(srv.URL))
+		wg      sync.WaitGroup
+	)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			callCache(t, cached)
+		}()
+	}
+
+	// Wait for the shared request to arrive, then let it respond
+	for atomic.LoadInt32(&srv.requests) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	srv.assertCounts(t, 1, 0)
+}
+
+func TestCachingServiceContext(t *testing.T) {
+	var (
+		release = make(chan struct{})
+		srv     = newCachedServer(t, release)
+		cached  = (&CachingService{}).WithRemote(*(&RemoteService{}).WithRemote(srv.URL))
+	)
+
+	// A caller stops waiting when its context is done, even though the call is still in flight
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := cached.CallContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+
+	// With no callers left, the call is cancelled
+	select {
+	case <-srv.cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the abandoned call was not cancelled")
+	}
+
+	// The next caller starts a new call
+	close(release)
+	callCache(t, cached)
+	srv.assertCounts(t, 2, 0)
+}
+
+func TestCachingServiceKeepsBreaker(t *testing.T) {
+	srv := newCachedServer(t, nil)
+	atomic.StoreInt32(&srv.status, http.StatusInternalServerError)
+
+	services := buildServices([]ServiceConfig{
+		{Name: "remote", URL: srv.URL, Breaker: &BreakerConfig{Failures: 2, OpenFor: "1m"}},
+		{Name: "cached", Cache: "remote"},
+	}, nil, time.Now)
+
+	for i := 0; i < 2; i++ {
+		if _, err := services["cached"].Call(); err == nil {
+			t.Fatal("call to a failing service succeeded")
+		}
+	}
+
+	// The circuit is open, so the server is not called
+	if _, err := services["cached"].Call(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("err = %v, want ErrCircuitOpen", err)
+	}
+	srv.assertCounts(t, 2, 0)
+}
@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ErrNoServices is the error returned when a composite service has no services to call
+var ErrNoServices = fmt.Errorf("No services to call")
+
+// FallbackService is a service that calls other services in order, returning the products of the first one that
+// succeeds, eg a RemoteService that falls back to a LocalService.
+// The zero value is ready to use, but has no services to call.
+type FallbackService struct {
+	services []Service
+}
+
+// WithServices sets the services to call, in order
+func (fs *FallbackService) WithServices(services ...Service) *FallbackService {
+	fs.services = services
+	return fs
+}
+
+// Call returns the Products of the first service that succeeds
+func (fs FallbackService) Call() ([]Product, error) {
+	return fs.CallContext(context.Background())
+}
+
+// CallContext returns the Products of the first service that succeeds.
+// If every service fails, the error describes every failure.
+// It stops trying services as soon as the context is done.
+func (fs FallbackService) CallContext(ctx context.Context) ([]Product, error) {
+	if len(fs.services) == 0 {
+		return nil, ErrNoServices
+	}
+
+	var errs []string
+	for _, svc := range fs.services {
+		products, err := svc.CallContext(ctx)
+		if err == nil {
+			return products, nil
+		}
+
+		errs = append(errs, err.Error())
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%w (failures: %s)", ctx.Err(), strings.Join(errs, "; "))
+		}
+	}
+
+	return nil, fmt.Errorf("All services failed: %s", strings.Join(errs, "; "))
+}
+
+// MergingService is a service that calls other services, returning the union of their products.
+// A product is identified by name; if several services return the same product, the first service wins.
+// The zero value is ready to use, but has no services to call.
+type MergingService struct {
+	services []Service
+}
+
+// WithServices sets the services to call, in order of precedence
+func (ms *MergingService) WithServices(services ...Service) *MergingService {
+	ms.services = services
+	return ms
+}
+
+// Call returns the union of the Products of all services
+func (ms MergingService) Call() ([]Product, error) {
+	return ms.CallContext(context.Background())
+}
+
+// CallContext returns the union of the Products of all services.
+// If any service fails, the error is returned: wrap it in a FallbackService to tolerate failures.
+func (ms MergingService) CallContext(ctx context.Context) ([]Product, error) {
+	if len(ms.services) == 0 {
+		return nil, ErrNoServices
+	}
+
+	var (
+		products []Product
+		seen     = map[string]bool{}
+	)
+
+	for _, svc := range ms.services {
+		svcProducts, err := svc.CallContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range svcProducts {
+			if !seen[p.Name] {
+				seen[p.Name] = true
+				products = append(products, p)
+			}
+		}
+	}
+
+	return products, nil
+}
@@ -0,0 +1,740 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServiceConfig is the configuration of one service, which is one of:
+// - a FallbackService, if Fallback lists the names of services to try in order
+// - a MergingService, if Merge lists the names of services to merge
+// - a CachingService, if Cache is the name of a remote service to cache
+// - a RemoteService, if it has a method or URL
+// - otherwise a LocalService
+//
+// The query and form parameters of a RemoteService are strings, numbers, bools, or arrays of them.
+// Path parameters fill {name} placeholders in the URL, and cannot be arrays.
+// A POST or PUT is only retried if RetryWrites is true, which is only safe if the remote handles repeated requests.
+type ServiceConfig struct {
+	Name        string                 `json:"name"`
+	Products    []Product              `json:"products"`
+	Method      string                 `json:"method"`
+	URL         string                 `json:"url"`
+	Timeout     string                 `json:"timeout"`
+	Attempts    int                    `json:"attempts"`
+	RetryWrites bool                   `json:"retryWrites"`
+	Query       map[string]interface{} `json:"query"`
+	Path        map[string]interface{} `json:"path"`
+	Body        json.RawMessage        `json:"body"`
+	Form        map[string]interface{} `json:"form"`
+	Fallback    []string               `json:"fallback"`
+	Merge       []string               `json:"merge"`
+	Cache       string                 `json:"cache"`
+	Health      *HealthConfig          `json:"health"`
+	Breaker     *BreakerConfig         `json:"breaker"`
+
+	// source is where the config was last changed, eg services.json:12, for error messages
+	source string
+}
+
+// HealthConfig configures the health probe of a service.
+// The probe is a GET of URL if it is set, otherwise a call to the service.
+// Interval and Timeout are durations, such as 10s, and default to 30s and 5s.
+type HealthConfig struct {
+	URL      string `json:"url"`
+	Interval string `json:"interval"`
+	Timeout  string `json:"timeout"`
+}
+
+// BreakerConfig configures the circuit breaker of a service: Failures consecutive failures open the circuit for
+// OpenFor (a duration, such as 30s), after which HalfOpenCalls successful trial calls close it.
+// Zero values default to 5 failures, 30s and 1 call.
+type BreakerConfig struct {
+	Failures      int    `json:"failures"`
+	OpenFor       string `json:"openFor"`
+	HalfOpenCalls int    `json:"halfOpenCalls"`
+}
+
+// Service kinds
+const (
+	localKind    = "local"
+	remoteKind   = "remote"
+	fallbackKind = "fallback"
+	mergeKind    = "merge"
+	cacheKind    = "cache"
+)
+
+// kinds returns the kinds of service the fields of the config are for, which is only valid if there is at most one
+func (c ServiceConfig) kinds() []string {
+	var kinds []string
+	if c.Fallback != nil {
+		kinds = append(kinds, fallbackKind)
+	}
+
+	if c.Merge != nil {
+		kinds = append(kinds, mergeKind)
+	}
+
+	if c.Cache != "" {
+		kinds = append(kinds, cacheKind)
+	}
+
+	if (c.Method != "") || (c.URL != "") {
+		kinds = append(kinds, remoteKind)
+	}
+
+	if c.Products != nil {
+		kinds = append(kinds, localKind)
+	}
+
+	return kinds
+}
+
+// kind returns the kind of service the config is for
+func (c ServiceConfig) kind() string {
+	if kinds := c.kinds(); len(kinds) > 0 {
+		return kinds[0]
+	}
+
+	return localKind
+}
+
+// refs returns the names of the services the config refers to
+func (c ServiceConfig) refs() []string {
+	refs := append(append([]string(nil), c.Fallback...), c.Merge...)
+	if c.Cache != "" {
+		refs = append(refs, c.Cache)
+	}
+
+	return refs
+}
+
+// merge overrides the fields of the config with the non-zero fields of another config
+func (c *ServiceConfig) merge(o ServiceConfig) {
+	if o.Products != nil {
+		c.Products = o.Products
+	}
+
+	if o.Method != "" {
+		c.Method = o.Method
+	}
+
+	if o.URL != "" {
+		c.URL = o.URL
+	}
+
+	if o.Timeout != "" {
+		c.Timeout = o.Timeout
+	}
+
+	if o.Attempts != 0 {
+		c.Attempts = o.Attempts
+	}
+
+	if o.RetryWrites {
+		c.RetryWrites = true
+	}
+
+	if o.Query != nil {
+		c.Query = o.Query
+	}
+
+	if o.Path != nil {
+		c.Path = o.Path
+	}
+
+	if o.Body != nil {
+		c.Body = o.Body
+	}
+
+	if o.Form != nil {
+		c.Form = o.Form
+	}
+
+	if o.Fallback != nil {
+		c.Fallback = o.Fallback
+	}
+
+	if o.Merge != nil {
+		c.Merge = o.Merge
+	}
+
+	if o.Cache != "" {
+		c.Cache = o.Cache
+	}
+
+	if o.Health != nil {
+		c.Health = o.Health
+	}
+
+	if o.Breaker != nil {
+		c.Breaker = o.Breaker
+	}
+
+	c.source = o.source
+}
+
+// ConfigError is a problem with the configuration of a service.
+// Source is the file and line, environment variable, or override the problem was found in.
+type ConfigError struct {
+	Source  string
+	Service string
+	Message string
+}
+
+// Error is error for ConfigError
+func (e ConfigError) Error() string {
+	if e.Service == "" {
+		return fmt.Sprintf("%s: %s", e.Source, e.Message)
+	}
+
+	return fmt.Sprintf("%s: service %q: %s", e.Source, e.Service, e.Message)
+}
+
+// ConfigErrors is all the problems found in a configuration
+type ConfigErrors []ConfigError
+
+// Error is error for ConfigErrors, with one problem per line
+func (e ConfigErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// lineOf returns the line number of a byte offset in data
+func lineOf(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// parseConfig parses a JSON array of service configs from a file.
+// Each config has its source set to the file name and the line it starts on.
+// Unknown fields are errors, and parsing stops at the first error.
+func parseConfig(filename string, data []byte) ([]ServiceConfig, error) {
+	var (
+		dec     = json.NewDecoder(bytes.NewReader(data))
+		configs []ServiceConfig
+	)
+	dec.DisallowUnknownFields()
+
+	fail := func(offset int64, err error) ([]ServiceConfig, error) {
+		var (
+			syntaxErr *json.SyntaxError
+			typeErr   *json.UnmarshalTypeError
+		)
+
+		switch {
+		case errors.As(err, &syntaxErr):
+			offset = syntaxErr.Offset
+		case errors.As(err, &typeErr):
+			// Relative to the start of the element being decoded
+			offset += typeErr.Offset
+		case err == io.EOF:
+			err = io.ErrUnexpectedEOF
+		}
+
+		return nil, ConfigError{
+			Source:  fmt.Sprintf("%s:%d", filename, lineOf(data, offset)),
+			Message: strings.TrimPrefix(err.Error(), "json: "),
+		}
+	}
+
+	if tok, err := dec.Token(); err != nil {
+		return fail(dec.InputOffset(), err)
+	} else if tok != json.Delim('[') {
+		return fail(dec.InputOffset(), fmt.Errorf("expected an array of services"))
+	}
+
+	for dec.More() {
+		// Skip whitespace and the comma before the element, so the offset is where the element starts
+		start := dec.InputOffset()
+		for (start < int64(len(data))) && strings.ContainsRune(" \t\r\n,", rune(data[start])) {
+			start++
+		}
+
+		var config ServiceConfig
+		if err := dec.Decode(&config); err != nil {
+			return fail(start, err)
+		}
+
+		config.source = fmt.Sprintf("%s:%d", filename, lineOf(data, start))
+		configs = append(configs, config)
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fail(dec.InputOffset(), err)
+	}
+
+	return configs, nil
+}
+
+// envFields are the fields that can be set by environment variables, eg SERVICES_REMOTETANGERINES_URL
+var envFields = map[string]func(*ServiceConfig, string) error{
+	"METHOD": func(c *ServiceConfig, value string) error {
+		c.Method = value
+		return nil
+	},
+	"URL": func(c *ServiceConfig, value string) error {
+		c.URL = value
+		return nil
+	},
+	"TIMEOUT": func(c *ServiceConfig, value string) error {
+		c.Timeout = value
+		return nil
+	},
+	"ATTEMPTS": func(c *ServiceConfig, value string) (err error) {
+		c.Attempts, err = strconv.Atoi(value)
+		return
+	},
+	"RETRYWRITES": func(c *ServiceConfig, value string) (err error) {
+		c.RetryWrites, err = strconv.ParseBool(value)
+		return
+	},
+	"PRODUCTS": func(c *ServiceConfig, value string) error {
+		return json.Unmarshal([]byte(value), &c.Products)
+	},
+	"FALLBACK": func(c *ServiceConfig, value string) error {
+		c.Fallback = splitNames(value)
+		return nil
+	},
+	"MERGE": func(c *ServiceConfig, value string) error {
+		c.Merge = splitNames(value)
+		return nil
+	},
+	"CACHE": func(c *ServiceConfig, value string) error {
+		c.Cache = value
+		return nil
+	},
+}
+
+// splitNames splits a comma separated list of service names
+func splitNames(value string) []string {
+	names := []string{}
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// parseEnv parses environment variables of the form PREFIX_NAME_FIELD=value into service configs.
+// NAME matches the name of a service case insensitively, or is the name of a new service.
+// Variables without the prefix are ignored.
+func parseEnv(prefix string, environ []string, names []string) ([]ServiceConfig, error) {
+	var (
+		configs  []ServiceConfig
+		byName   = map[string]int{}
+		problems ConfigErrors
+	)
+
+	// Sort so that the order of configs and errors does not depend on the order of the environment
+	environ = append([]string(nil), environ...)
+	sort.Strings(environ)
+
+	for _, kv := range environ {
+		eq := strings.IndexByte(kv, '=')
+		if (eq < 0) || !strings.HasPrefix(kv[:eq], prefix+"_") {
+			continue
+		}
+
+		var (
+			key   = kv[:eq]
+			value = kv[eq+1:]
+			rest  = strings.TrimPrefix(key, prefix+"_")
+			sep   = strings.LastIndexByte(rest, '_')
+		)
+
+		if sep <= 0 {
+			problems = append(problems, ConfigError{Source: key, Message: "expected " + prefix + "_<NAME>_<FIELD>"})
+			continue
+		}
+
+		name, fieldName := rest[:sep], strings.ToUpper(rest[sep+1:])
+		for _, known := range names {
+			if strings.EqualFold(known, name) {
+				name = known
+				break
+			}
+		}
+
+		setField, haveField := envFields[fieldName]
+		if !haveField {
+			problems = append(problems, ConfigError{Source: key, Service: name, Message: fmt.Sprintf("unknown field %q", fieldName)})
+			continue
+		}
+
+		i, have := byName[name]
+		if !have {
+			i = len(configs)
+			byName[name] = i
+			configs = append(configs, ServiceConfig{Name: name})
+		}
+
+		var config ServiceConfig
+		if err := setField(&config, value); err != nil {
+			problems = append(problems, ConfigError{Source: key, Service: name, Message: err.Error()})
+			continue
+		}
+
+		config.source = key
+		configs[i].merge(config)
+	}
+
+	if len(problems) > 0 {
+		return nil, problems
+	}
+
+	return configs, nil
+}
+
+// validMethods are the methods a RemoteService may use
+var validMethods = map[string]bool{
+	http.MethodGet:  true,
+	http.MethodPost: true,
+	http.MethodPut:  true,
+}
+
+// validate returns the problems with a merged service config, other than problems with the services it refers to
+func (c ServiceConfig) validate() []ConfigError {
+	var problems []ConfigError
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, ConfigError{Source: c.source, Service: c.Name, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if kinds := c.kinds(); len(kinds) > 1 {
+		problem("cannot be more than one of %s", strings.Join(kinds, ", "))
+		return problems
+	}
+
+	kind := c.kind()
+	if (kind != remoteKind) && ((c.Timeout != "") || (c.Attempts != 0) || c.RetryWrites) {
+		problem("a %s service cannot have a timeout, attempts or retryWrites", kind)
+	}
+
+	if (kind != remoteKind) && ((c.Query != nil) || (c.Path != nil) || (c.Body != nil) || (c.Form != nil)) {
+		problem("a %s service cannot have query, path, body or form parameters", kind)
+	}
+
+	if h := c.Health; h != nil {
+		if h.URL != "" {
+			if u, err := url.Parse(h.URL); (err != nil) || ((u.Scheme != "http") && (u.Scheme != "https")) || (u.Host == "") {
+				problem("health url %q is not an absolute http or https url", h.URL)
+			}
+		}
+
+		validateDuration(problem, "health interval", h.Interval)
+		validateDuration(problem, "health timeout", h.Timeout)
+	}
+
+	if b := c.Breaker; b != nil {
+		if (b.Failures < 0) || (b.HalfOpenCalls < 0) {
+			problem("breaker failures and halfOpenCalls cannot be negative")
+		}
+
+		validateDuration(problem, "breaker openFor", b.OpenFor)
+	}
+
+	switch kind {
+	case remoteKind:
+		if (c.Method != "") && !validMethods[c.Method] {
+			problem("method %q is not one of GET, POST or PUT", c.Method)
+		}
+
+		if c.URL == "" {
+			problem("a remote service requires a url")
+		} else if u, err := url.Parse(c.URL); (err != nil) || ((u.Scheme != "http") && (u.Scheme != "https")) || (u.Host == "") {
+			problem("url %q is not an absolute http or https url", c.URL)
+		}
+
+		validateDuration(problem, "timeout", c.Timeout)
+
+		if c.Attempts < 0 {
+			problem("attempts cannot be negative")
+		}
+
+		for _, params := range []struct {
+			kind       string
+			values     map[string]interface{}
+			allowArray bool
+		}{{"query", c.Query, true}, {"path", c.Path, false}, {"form", c.Form, true}} {
+			for _, name := range sortedParamNames(params.values) {
+				if _, err := paramValues(params.values[name], params.allowArray); err != nil {
+					problem("%s parameter %q: %s", params.kind, name, err)
+				}
+			}
+		}
+
+		for _, match := range pathParamRegex.FindAllStringSubmatch(c.URL, -1) {
+			if _, have := c.Path[match[1]]; !have {
+				problem("no value for path parameter %q", match[1])
+			}
+		}
+
+		if (c.Body != nil) && (c.Form != nil) {
+			problem("cannot have both a body and a form")
+		}
+
+	case localKind:
+		for i, p := range c.Products {
+			if p.Name == "" {
+				problem("product %d has no name", i+1)
+			}
+		}
+
+	case fallbackKind, mergeKind:
+		if len(c.refs()) == 0 {
+			problem("a %s service requires at least one service", kind)
+		}
+	}
+
+	return problems
+}
+
+// validateDuration reports a problem if a duration is not empty or positive
+func validateDuration(problem func(string, ...interface{}), what, value string) {
+	if value != "" {
+		if d, err := time.ParseDuration(value); (err != nil) || (d <= 0) {
+			problem("%s %q is not a positive duration, such as 5s", what, value)
+		}
+	}
+}
+
+// validateRefs returns the problems with the services that configs refer to: unknown services, caching a service
+// that is not remote, and services that refer to themselves directly or indirectly
+func validateRefs(configs []ServiceConfig) []ConfigError {
+	var (
+		problems []ConfigError
+		byName   = map[string]ServiceConfig{}
+	)
+
+	for _, config := range configs {
+		byName[config.Name] = config
+	}
+
+	for _, config := range configs {
+		for _, ref := range config.refs() {
+			if refConfig, have := byName[ref]; !have {
+				problems = append(problems, ConfigError{Source: config.source, Service: config.Name, Message: fmt.Sprintf("unknown service %q", ref)})
+			} else if (ref == config.Cache) && (refConfig.kind() != remoteKind) {
+				problems = append(problems, ConfigError{Source: config.source, Service: config.Name, Message: fmt.Sprintf("cannot cache %s service %q", refConfig.kind(), ref)})
+			}
+		}
+	}
+
+	// Depth first search for cycles, reporting each cycle once, at the first service in it
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	var (
+		state = map[string]int{}
+		path  []string
+		visit func(name string)
+	)
+
+	visit = func(name string) {
+		config, have := byName[name]
+		if !have || (state[name] == visited) {
+			return
+		}
+
+		if state[name] == visiting {
+			var start int
+			for (start < len(path)) && (path[start] != name) {
+				start++
+			}
+
+			cycle := append(append([]string(nil), path[start:]...), name)
+			first := byName[cycle[0]]
+			problems = append(problems, ConfigError{Source: first.source, Service: first.Name, Message: "refers to itself: " + strings.Join(cycle, " -> ")})
+			return
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		for _, ref := range config.refs() {
+			visit(ref)
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+	}
+
+	for _, config := range configs {
+		visit(config.Name)
+	}
+
+	return problems
+}
+
+// parseDuration parses a valid duration, which is 0 if it is empty
+func parseDuration(value string) time.Duration {
+	d, _ := time.ParseDuration(value)
+	return d
+}
+
+// buildServices builds the services described by valid configs, keyed by name.
+// A service referred to by several others is the same instance, so that a CachingService has one cache.
+// Services in reuse are used as is, so that unchanged services keep their state (caches, circuits) across reloads,
+// and so that overridden services replace the configured ones.
+// Services with a health probe or circuit breaker are wrapped in a MonitoredService that uses the clock.
+func buildServices(configs []ServiceConfig, reuse map[string]Service, now func() time.Time) map[string]Service {
+	var (
+		byName   = map[string]ServiceConfig{}
+		services = map[string]Service{}
+		build    func(name string) Service
+	)
+
+	for _, config := range configs {
+		byName[config.Name] = config
+	}
+
+	build = func(name string) Service {
+		if svc, built := services[name]; built {
+			return svc
+		}
+
+		if svc, reused := reuse[name]; reused {
+			services[name] = svc
+			return svc
+		}
+
+		var (
+			c   = byName[name]
+			svc Service
+		)
+
+		refServices := func(names []string) []Service {
+			svcs := make([]Service, len(names))
+			for i, name := range names {
+				svcs[i] = build(name)
+			}
+
+			return svcs
+		}
+
+		switch c.kind() {
+		case fallbackKind:
+			svc = *(&FallbackService{}).WithServices(refServices(c.Fallback)...)
+
+		case mergeKind:
+			svc = *(&MergingService{}).WithServices(refServices(c.Merge)...)
+
+		case cacheKind:
+			svc = (&CachingService{}).WithRemote(build(c.Cache))
+
+		case remoteKind:
+			rs := &RemoteService{}
+			rs.WithMethod(c.Method).WithRemote(c.URL).WithRetry(c.Attempts, 0, 0).WithRetryWrites(c.RetryWrites)
+			if timeout, err := time.ParseDuration(c.Timeout); err == nil {
+				rs.WithTimeout(timeout)
+			}
+
+			for _, name := range sortedParamNames(c.Query) {
+				values, _ := paramValues(c.Query[name], true)
+				rs.WithQuery(name, values...)
+			}
+
+			for _, name := range sortedParamNames(c.Path) {
+				values, _ := paramValues(c.Path[name], false)
+				rs.WithPathParam(name, values[0])
+			}
+
+			for _, name := range sortedParamNames(c.Form) {
+				values, _ := paramValues(c.Form[name], true)
+				rs.WithForm(name, values...)
+			}
+
+			if c.Body != nil {
+				rs.WithJSONBody(c.Body)
+			}
+			svc = *rs
+
+		default:
+			svc = LocalService{products: c.Products}
+		}
+
+		if (c.Health != nil) || (c.Breaker != nil) {
+			ms := NewMonitoredService(svc).WithClock(now)
+			if b := c.Breaker; b != nil {
+				ms.WithBreaker(NewCircuitBreaker(b.Failures, parseDuration(b.OpenFor), b.HalfOpenCalls).WithClock(now))
+			}
+
+			if h := c.Health; h != nil {
+				probe := CallProbe(svc)
+				if h.URL != "" {
+					probe = HTTPProbe(h.URL)
+				}
+				ms.WithProbe(probe, parseDuration(h.Interval), parseDuration(h.Timeout))
+			}
+
+			svc = ms
+		}
+
+		services[name] = svc
+		return svc
+	}
+
+	for _, config := range configs {
+		build(config.Name)
+	}
+
+	return services
+}
+
+// sortedParamNames returns the names of parameters, sorted so that they are always sent in the same order
+func sortedParamNames(params map[string]interface{}) []string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// paramValues returns the values of a parameter decoded from JSON, which is a string, number, bool, or if allowed, an
+// array of them
+func paramValues(value interface{}, allowArray bool) ([]interface{}, error) {
+	values := []interface{}{value}
+	if array, isArray := value.([]interface{}); isArray {
+		if !allowArray {
+			return nil, fmt.Errorf("cannot be an array")
+		}
+		values = array
+	}
+
+	for _, v := range values {
+		switch v.(type) {
+		case string, float64, bool:
+		default:
+			if !allowArray {
+				return nil, fmt.Errorf("must be a string, number or bool")
+			}
+
+			return nil, fmt.Errorf("must be a string, number or bool, or an array of them")
+		}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,258 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes a config file in a temporary directory, and returns its name
+func writeConfig(t *testing.T, dir, name, data string) string {
+	t.Helper()
+
+	filename := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return filename
+}
+
+// loadConfig loads a factory from one config file named services.json, with the given environment, and returns the
+// error text, with the directory of the file removed
+func loadConfig(t *testing.T, data string, environ ...string) (*ServiceFactory, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	f := (&ServiceFactory{}).
+		WithFilename(writeConfig(t, dir, "services.json", data)).
+		WithEnviron(func() []string { return environ })
+
+	if err := f.Load(); err != nil {
+		return f, strings.ReplaceAll(err.Error(), dir+string(filepath.Separator), "")
+	}
+
+	return f, ""
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		data string
+		want string
+	}{
+		{
+			name: "not an array",
+			data: `{"name": "local"}`,
+			want: "test.json:1: expected an array of services",
+		},
+		{
+			name: "syntax",
+			data: "[\n  {\"name\": \"local\"},\n  {\"name\": \"remote\",,}\n]",
+			want: "test.json:3: invalid character ',' looking for beginning of object key string",
+		},
+		{
+			name: "unknown field",
+			data: "[\n  {\"name\": \"local\"},\n  {\n    \"name\": \"remote\",\n    \"urls\": \"http://localhost\"\n  }\n]",
+			want: `test.json:3: unknown field "urls"`,
+		},
+		{
+			name: "type",
+			data: "[\n  {\n    \"name\": \"remote\",\n    \"attempts\": \"3\"\n  }\n]",
+			want: "test.json:4: cannot unmarshal string into Go struct field ServiceConfig.attempts of type int",
+		},
+		{
+			name: "truncated",
+			data: "[\n  {\"name\": \"local\"}",
+			want: "test.json:2: unexpected end of JSON input",
+		},
+		{
+			name: "empty",
+			data: "",
+			want: "test.json:1: unexpected EOF",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseConfig("test.json", []byte(test.data))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			if got := err.Error(); got != test.want {
+				t.Errorf("error %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseConfigSources(t *testing.T) {
+	configs, err := parseConfig("test.json", []byte("[\n  {\"name\": \"a\"},\n\n  {\n    \"name\": \"b\"\n  }\n]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sources []string
+	for _, config := range configs {
+		sources = append(sources, config.source)
+	}
+
+	if want := []string{"test.json:2", "test.json:4"}; !reflect.DeepEqual(sources, want) {
+		t.Errorf("sources %v, want %v", sources, want)
+	}
+}
+
+func TestParseEnvErrors(t *testing.T) {
+	_, err := parseEnv("SERVICES", []string{
+		"SERVICES_REMOTE_ATTEMPTS=many",
+		"SERVICES_REMOTE_COLOUR=blue",
+		"SERVICES_URL=http://localhost",
+		"SERVICES_LOCAL_PRODUCTS=[",
+		"OTHER_REMOTE_URL=ignored",
+	}, []string{"remote"})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	want := `SERVICES_LOCAL_PRODUCTS: service "LOCAL": unexpected end of JSON input
+SERVICES_REMOTE_ATTEMPTS: service "remote": strconv.Atoi: parsing "many": invalid syntax
+SERVICES_REMOTE_COLOUR: service "remote": unknown field "COLOUR"
+SERVICES_URL: expected SERVICES_<NAME>_<FIELD>`
+	if got := err.Error(); got != want {
+		t.Errorf("error\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestConfigLayers(t *testing.T) {
+	var (
+		dir  = t.TempDir()
+		base = writeConfig(t, dir, "base.json", `[
+  {"name": "remote", "url": "http://base", "timeout": "1s", "attempts": 1},
+  {"name": "local", "products": [{"name": "Apples", "price": "1.00/lb"}]}
+]`)
+		local = writeConfig(t, dir, "local.json", `[
+  {"name": "remote", "url": "http://local", "attempts": 2},
+  {"name": "other", "url": "http://other"}
+]`)
+	)
+
+	f := (&ServiceFactory{}).
+		WithFilename(base).
+		WithFilename(local).
+		WithEnviron(func() []string {
+			return []string{"SERVICES_REMOTE_ATTEMPTS=3", "SERVICES_Other_METHOD=POST"}
+		}).
+		WithOverride(ServiceConfig{Name: "remote", Attempts: 4}).
+		WithOverride(ServiceConfig{Name: "added", Products: []Product{}})
+	if err := f.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each layer overrides the non-empty fields of the layers before it
+	for name, want := range map[string]ServiceConfig{
+		"remote": {Name: "remote", URL: "http://local", Timeout: "1s", Attempts: 4, source: "override 1"},
+		"other":  {Name: "other", Method: "POST", URL: "http://other", source: "SERVICES_Other_METHOD"},
+		"added":  {Name: "added", Products: []Product{}, source: "override 2"},
+	} {
+		if got := f.configs[name]; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: config %+v, want %+v", name, got, want)
+		}
+	}
+
+	if got, want := f.Names(), []string{"added", "local", "other", "remote"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("names %v, want %v", got, want)
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		data string
+		env  []string
+		want string
+	}{
+		{
+			name: "kinds",
+			data: `[{"name": "both", "url": "http://localhost", "products": []}]`,
+			want: `services.json:1: service "both": cannot be more than one of remote, local`,
+		},
+		{
+			name: "remote",
+			data: `[
+  {"name": "remote", "method": "DELETE", "url": "localhost/{id}", "timeout": "soon", "attempts": -1}
+]`,
+			want: `services.json:2: service "remote": method "DELETE" is not one of GET, POST or PUT
+services.json:2: service "remote": url "localhost/{id}" is not an absolute http or https url
+services.json:2: service "remote": timeout "soon" is not a positive duration, such as 5s
+services.json:2: service "remote": attempts cannot be negative
+services.json:2: service "remote": no value for path parameter "id"`,
+		},
+		{
+			name: "not remote",
+			data: `[{"name": "local", "products": [{"price": "1"}], "attempts": 2, "query": {"a": 1}}]`,
+			want: `services.json:1: service "local": a local service cannot have a timeout, attempts or retryWrites
+services.json:1: service "local": a local service cannot have query, path, body or form parameters
+services.json:1: service "local": product 1 has no name`,
+		},
+		{
+			name: "params",
+			data: `[{"name": "remote", "url": "http://localhost/{id}", "path": {"id": [1]}, "form": {"a": {}}, "body": {}}]`,
+			want: `services.json:1: service "remote": path parameter "id": cannot be an array
+services.json:1: service "remote": form parameter "a": must be a string, number or bool, or an array of them
+services.json:1: service "remote": cannot have both a body and a form`,
+		},
+		{
+			name: "health and breaker",
+			data: `[{"name": "local", "products": [], "health": {"url": "/health", "interval": "0s"}, "breaker": {"failures": -1, "openFor": "x"}}]`,
+			want: `services.json:1: service "local": health url "/health" is not an absolute http or https url
+services.json:1: service "local": health interval "0s" is not a positive duration, such as 5s
+services.json:1: service "local": breaker failures and halfOpenCalls cannot be negative
+services.json:1: service "local": breaker openFor "x" is not a positive duration, such as 5s`,
+		},
+		{
+			name: "refs",
+			data: `[
+  {"name": "local", "products": []},
+  {"name": "fallback", "fallback": ["local", "missing"]},
+  {"name": "cached", "cache": "local"},
+  {"name": "merged", "merge": []},
+  {"name": "a", "fallback": ["b"]},
+  {"name": "b", "merge": ["local", "a"]}
+]`,
+			want: `services.json:5: service "merged": a merge service requires at least one service
+services.json:3: service "fallback": unknown service "missing"
+services.json:4: service "cached": cannot cache local service "local"
+services.json:6: service "a": refers to itself: a -> b -> a`,
+		},
+		{
+			name: "names",
+			data: `[
+  {"products": []},
+  {"name": "local", "products": []},
+  {"name": "local", "products": []}
+]`,
+			want: `services.json:2: a service requires a name
+services.json:4: service "local": already configured at services.json:3`,
+		},
+		{
+			name: "env",
+			data: `[{"name": "remote", "url": "http://localhost"}]`,
+			env:  []string{"SERVICES_REMOTE_TIMEOUT=-1s"},
+			want: `SERVICES_REMOTE_TIMEOUT: service "remote": timeout "-1s" is not a positive duration, such as 5s`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			f, got := loadConfig(t, test.data, test.env...)
+			if got != test.want {
+				t.Errorf("error\n%s\nwant\n%s", got, test.want)
+			}
+
+			if names := f.Names(); len(names) != 0 {
+				t.Errorf("an invalid configuration loaded %v", names)
+			}
+		})
+	}
+}
@@ -0,0 +1,340 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Configuration defaults
+const (
+	defaultFilename  = "services.json"
+	defaultEnvPrefix = "SERVICES"
+)
+
+// ServiceFactory creates services from layered configuration:
+// JSON files merged in order, then environment variables, then explicit overrides.
+// Each layer overrides the non-empty fields of services with the same name, and can add new services.
+type ServiceFactory struct {
+	filenames []string
+	envPrefix string
+	environ   func() []string
+	overrides []ServiceConfig
+	now       func() time.Time
+
+	// mu guards the fields below, which are replaced when the configuration is loaded
+	mu          sync.RWMutex
+	configs     map[string]ServiceConfig
+	services    map[string]Service
+	stamps      map[string]fileStamp
+	version     int
+	subscribers map[int]func(ReloadEvent)
+	nextID      int
+}
+
+// WithFilename adds a file to load (default is services.json).
+// Files are merged in the order they are added.
+func (f *ServiceFactory) WithFilename(filename string) *ServiceFactory {
+	f.filenames = append(f.filenames, filename)
+	return f
+}
+
+// WithEnvPrefix overrides the default environment variable prefix of SERVICES.
+// A variable named PREFIX_NAME_FIELD sets a field of the service with the given name, where FIELD is one of METHOD,
+// URL, TIMEOUT, ATTEMPTS, or PRODUCTS (a JSON array).
+func (f *ServiceFactory) WithEnvPrefix(prefix string) *ServiceFactory {
+	f.envPrefix = prefix
+	return f
+}
+
+// WithEnviron overrides the default environment of os.Environ
+func (f *ServiceFactory) WithEnviron(environ func() []string) *ServiceFactory {
+	f.environ = environ
+	return f
+}
+
+// WithClock overrides the default clock of time.Now, used by circuit breakers and health probes
+func (f *ServiceFactory) WithClock(now func() time.Time) *ServiceFactory {
+	f.now = now
+	return f
+}
+
+// clock returns the clock
+func (f *ServiceFactory) clock() func() time.Time {
+	if f.now == nil {
+		return time.Now
+	}
+
+	return f.now
+}
+
+// WithOverride adds a config that overrides the files and environment.
+// Overrides are merged in the order they are added.
+func (f *ServiceFactory) WithOverride(config ServiceConfig) *ServiceFactory {
+	f.overrides = append(f.overrides, config)
+	return f
+}
+
+// configure ensures the factory has values
+func (f *ServiceFactory) configure() {
+	if len(f.filenames) == 0 {
+		f.filenames = []string{defaultFilename}
+	}
+
+	if f.envPrefix == "" {
+		f.envPrefix = defaultEnvPrefix
+	}
+
+	if f.environ == nil {
+		f.environ = os.Environ
+	}
+
+	if len(f.services) == 0 {
+		f.services = map[string]Service{}
+	}
+
+	if f.subscribers == nil {
+		f.subscribers = map[int]func(ReloadEvent){}
+	}
+}
+
+// layers is the merged configs of all layers, in the order services were first configured
+type layers struct {
+	configs []ServiceConfig
+	byName  map[string]int
+}
+
+// merge merges a layer of configs into the configs of previous layers.
+// A service that is configured more than once in the same layer is a problem.
+func (l *layers) merge(configs []ServiceConfig) []ConfigError {
+	var (
+		problems []ConfigError
+		seen     = map[string]string{}
+	)
+
+	for _, config := range configs {
+		if config.Name == "" {
+			problems = append(problems, ConfigError{Source: config.source, Message: "a service requires a name"})
+			continue
+		}
+
+		if prev, have := seen[config.Name]; have {
+			problems = append(problems, ConfigError{Source: config.source, Service: config.Name, Message: "already configured at " + prev})
+			continue
+		}
+		seen[config.Name] = config.source
+
+		if i, have := l.byName[config.Name]; have {
+			l.configs[i].merge(config)
+		} else {
+			l.byName[config.Name] = len(l.configs)
+			l.configs = append(l.configs, config)
+		}
+	}
+
+	return problems
+}
+
+// names returns the names of the services configured so far
+func (l *layers) names() []string {
+	names := make([]string, len(l.configs))
+	for i, config := range l.configs {
+		names[i] = config.Name
+	}
+
+	return names
+}
+
+// read reads the configs of all layers, and the stamps of the files read.
+// Stamps are returned even if there are problems, so that a watcher does not reload the same invalid file again.
+func (f *ServiceFactory) read() ([]ServiceConfig, map[string]fileStamp, error) {
+	var (
+		l        = layers{byName: map[string]int{}}
+		stamps   = map[string]fileStamp{}
+		problems ConfigErrors
+	)
+
+	for _, filename := range f.filenames {
+		stamps[filename] = stampOf(filename)
+
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, stamps, err
+		}
+
+		configs, err := parseConfig(filename, data)
+		if err != nil {
+			return nil, stamps, ConfigErrors{err.(ConfigError)}
+		}
+
+		problems = append(problems, l.merge(configs)...)
+	}
+
+	envConfigs, err := parseEnv(f.envPrefix, f.environ(), l.names())
+	if err != nil {
+		problems = append(problems, err.(ConfigErrors)...)
+	}
+	problems = append(problems, l.merge(envConfigs)...)
+
+	overrides := make([]ServiceConfig, len(f.overrides))
+	for i, config := range f.overrides {
+		config.source = fmt.Sprintf("override %d", i+1)
+		overrides[i] = config
+	}
+	problems = append(problems, l.merge(overrides)...)
+
+	for _, config := range l.configs {
+		problems = append(problems, config.validate()...)
+	}
+	problems = append(problems, validateRefs(l.configs)...)
+
+	if len(problems) > 0 {
+		return nil, stamps, problems
+	}
+
+	return l.configs, stamps, nil
+}
+
+// Load loads the services from all layers of configuration, replacing any previously loaded services all at once.
+// If there are any problems, they are all returned as ConfigErrors, and the services are not changed.
+// Subscribers are notified of the services that were added, removed or changed, or of the problems.
+func (f *ServiceFactory) Load() error {
+	f.mu.Lock()
+	f.configure()
+
+	configs, stamps, err := f.read()
+	f.stamps = stamps
+
+	var event ReloadEvent
+	if err != nil {
+		event.Err = err
+	} else {
+		newConfigs := map[string]ServiceConfig{}
+		for _, config := range configs {
+			newConfigs[config.Name] = config
+		}
+
+		event = diff(f.configs, newConfigs)
+
+		// Keep services that have not changed
+		reuse := map[string]Service{}
+		for name, svc := range f.services {
+			reuse[name] = svc
+		}
+		for _, names := range [][]string{event.Added, event.Removed, event.Changed} {
+			for _, name := range names {
+				delete(reuse, name)
+			}
+		}
+
+		f.configs, f.services = newConfigs, buildServices(configs, reuse, f.clock())
+		if !event.Empty() {
+			f.version++
+		}
+	}
+
+	subscribers := f.subscriberList()
+	f.mu.Unlock()
+
+	// Notify outside the lock, so that subscribers can use the factory
+	if (event.Err != nil) || !event.Empty() {
+		for _, subscriber := range subscribers {
+			subscriber(event)
+		}
+	}
+
+	return err
+}
+
+// Names returns the names of the loaded services, sorted
+func (f *ServiceFactory) Names() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	names := make([]string, 0, len(f.services))
+	for name := range f.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Service returns a loaded service by name, and false if there is no such service.
+// A service that is removed or changed by a reload keeps working for callers that already have it.
+func (f *ServiceFactory) Service(name string) (Service, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	svc, have := f.services[name]
+	return svc, have
+}
+
+// configVersion returns the version of the configuration, which changes whenever a reload changes the services
+func (f *ServiceFactory) configVersion() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.version
+}
+
+// servicesWith returns the loaded services where some services are replaced, and the version of the configuration
+// they were built from, which changes whenever a reload changes the services. Services that refer to a replaced
+// service, directly or indirectly, are rebuilt to use the replacement. If localize is not nil, it is called with
+// each service that is not replaced, and a service it returns is a replacement too.
+func (f *ServiceFactory) servicesWith(
+	replace map[string]Service, localize func(Service) (Service, bool),
+) (map[string]Service, int) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	reuse := map[string]Service{}
+	for name, svc := range replace {
+		reuse[name] = svc
+	}
+
+	if localize != nil {
+		for name, svc := range f.services {
+			if _, replaced := reuse[name]; !replaced {
+				if local, isLocal := localize(svc); isLocal {
+					reuse[name] = local
+				}
+			}
+		}
+	}
+
+	// A service depends on a replacement if it is replaced, or refers to a service that depends on one
+	var (
+		depends = map[string]bool{}
+		visit   func(name string) bool
+	)
+
+	visit = func(name string) bool {
+		if dependsOn, visited := depends[name]; visited {
+			return dependsOn
+		}
+
+		_, depends[name] = reuse[name]
+		for _, ref := range f.configs[name].refs() {
+			// Visit every ref, so that each is marked
+			depends[name] = visit(ref) || depends[name]
+		}
+
+		return depends[name]
+	}
+
+	configs := make([]ServiceConfig, 0, len(f.configs))
+	for name, config := range f.configs {
+		configs = append(configs, config)
+		if !visit(name) {
+			reuse[name] = f.services[name]
+		}
+	}
+
+	return buildServices(configs, reuse, f.clock()), f.version
+}
@@ -0,0 +1,228 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthState is the result of the most recent health probe of a service
+type HealthState uint
+
+// HealthState constants
+const (
+	// Unknown means the service has not been probed yet
+	Unknown HealthState = iota
+	Up
+	Down
+)
+
+var (
+	healthStateToString = map[HealthState]string{
+		Unknown: "unknown",
+		Up:      "up",
+		Down:    "down",
+	}
+)
+
+// String is HealthState Stringer
+func (s HealthState) String() string {
+	return healthStateToString[s]
+}
+
+// Probe checks if a service is healthy, returning nil if it is
+type Probe func(ctx context.Context) error
+
+// HTTPProbe returns a Probe that is healthy if a GET of a url responds with a 2xx status
+func HTTPProbe(url string) Probe {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+
+		defer resp.Body.Close()
+		io.Copy(ioutil.Discard, resp.Body)
+
+		if (resp.StatusCode < 200) || (resp.StatusCode > 299) {
+			return fmt.Errorf("GET %s: %d %s", url, resp.StatusCode, http.StatusText(resp.StatusCode))
+		}
+
+		return nil
+	}
+}
+
+// CallProbe returns a Probe that is healthy if a call to a service succeeds
+func CallProbe(svc Service) Probe {
+	return func(ctx context.Context) error {
+		_, err := svc.CallContext(ctx)
+		return err
+	}
+}
+
+// MonitoredService is a service with an optional circuit breaker and health probe, that keeps statistics of its calls.
+// Failed probes count towards opening the circuit, and a successful probe makes an open circuit half open.
+//
+// It is safe for concurrent use, and must be used by pointer.
+type MonitoredService struct {
+	svc           Service
+	breaker       *CircuitBreaker
+	probe         Probe
+	probeInterval time.Duration
+	probeTimeout  time.Duration
+	now           func() time.Time
+
+	mu        sync.Mutex
+	calls     int
+	errors    int
+	lastError string
+	health    HealthState
+	lastProbe time.Time
+}
+
+// NewMonitoredService constructs a MonitoredService with no circuit breaker or health probe
+func NewMonitoredService(svc Service) *MonitoredService {
+	return &MonitoredService{svc: svc, now: time.Now}
+}
+
+// WithBreaker sets the circuit breaker, which is nil for none
+func (ms *MonitoredService) WithBreaker(breaker *CircuitBreaker) *MonitoredService {
+	ms.breaker = breaker
+	return ms
+}
+
+// WithProbe sets the health probe, which is run every interval with a timeout, or nil for none.
+// Zero durations are replaced with the defaults of every 30 seconds with a 5 second timeout.
+func (ms *MonitoredService) WithProbe(probe Probe, interval, timeout time.Duration) *MonitoredService {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	ms.probe, ms.probeInterval, ms.probeTimeout = probe, interval, timeout
+	return ms
+}
+
+// WithClock overrides the default clock of time.Now, which decides when probes are due
+func (ms *MonitoredService) WithClock(now func() time.Time) *MonitoredService {
+	ms.now = now
+	return ms
+}
+
+// Call returns Products from the service, unless the circuit is open
+func (ms *MonitoredService) Call() ([]Product, error) {
+	return ms.CallContext(context.Background())
+}
+
+// CallContext returns Products from the service, unless the circuit is open, in which case it fails immediately with
+// ErrCircuitOpen. Calls that fail because the context is done do not count as failures of the service.
+func (ms *MonitoredService) CallContext(ctx context.Context) ([]Product, error) {
+	var products []Product
+	err := ms.guard(ctx, func() (err error) {
+		products, err = ms.svc.CallContext(ctx)
+		return
+	})
+
+	return products, err
+}
+
+// fetch fetches Products from the service for a CachingService, in the same way as CallContext.
+// If the service cannot revalidate with an ETag, it is called without one.
+func (ms *MonitoredService) fetch(ctx context.Context, etag string) ([]Product, fetched, error) {
+	var (
+		products []Product
+		resp     fetched
+	)
+
+	err := ms.guard(ctx, func() (err error) {
+		if f, isa := ms.svc.(fetcher); isa {
+			products, resp, err = f.fetch(ctx, etag)
+		} else {
+			products, err = ms.svc.CallContext(ctx)
+		}
+		return
+	})
+
+	return products, resp, err
+}
+
+// guard makes a call to the service through the circuit breaker, and records the result
+func (ms *MonitoredService) guard(ctx context.Context, call func() error) error {
+	if (ms.breaker != nil) && !ms.breaker.Allow() {
+		ms.record(ErrCircuitOpen)
+		return ErrCircuitOpen
+	}
+
+	err := call()
+	if ms.breaker != nil {
+		if (err != nil) && (ctx.Err() != nil) {
+			ms.breaker.Release()
+		} else {
+			ms.breaker.Done(err)
+		}
+	}
+	ms.record(err)
+
+	return err
+}
+
+// record updates the statistics for a call
+func (ms *MonitoredService) record(err error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.calls++
+	if err != nil {
+		ms.errors++
+		ms.lastError = err.Error()
+	}
+}
+
+// probeDue returns true if the service has a probe that has not been run for an interval
+func (ms *MonitoredService) probeDue() bool {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return (ms.probe != nil) && !ms.now().Before(ms.lastProbe.Add(ms.probeInterval))
+}
+
+// Probe runs the health probe now, if there is one, and returns its result
+func (ms *MonitoredService) Probe(ctx context.Context) error {
+	if ms.probe == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ms.probeTimeout)
+	defer cancel()
+
+	err := ms.probe(ctx)
+	if ms.breaker != nil {
+		ms.breaker.Record(err)
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.lastProbe = ms.now()
+	ms.health = Up
+	if err != nil {
+		ms.health = Down
+		ms.lastError = err.Error()
+	}
+
+	return err
+}
@@ -3,178 +3,305 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
 )
 
-// Product is the data returned by a Service
-type Product struct {
-	Name  string `json:"name"`
-	Price string `json:"price"`
-}
-
-// Service is an interface describing a Service that returns Products.
-type Service interface {
-	Call() []Product
-}
-
-// LocalService is a service that runs locally, no tnet connection reqiured
-type LocalService struct {
-	products []Product
-}
-
-// WithProducts sets the products to return on future invocations of Call()
-func (ls *LocalService) WithProducts(products ...Product) {
-	ls.products = products
-}
-
-// configure ensures the service has default products if none were provided
-func (ls *LocalService) configure() {
-	if len(ls.products) == 0 {
-		ls.products = []Product{
-			{Name: "Oranges", Price: "2.50/lb"},
-			{Name: "Apples", Price: "5.00/lb"},
-		}
+func main() {
+	// Set up a factory from services.json, overriding the tangerines url with the environment, and adding a service
+	f := (&ServiceFactory{}).
+		WithEnviron(func() []string {
+			return []string{"SERVICES_REMOTETANGERINES_URL=https://tangerines-r-us.com/v2/products"}
+		}).
+		WithOverride(ServiceConfig{Name: "localCherries", Products: []Product{{Name: "Cherries", Price: "7.99/lb"}}})
+	if err := f.Load(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-}
-
-// Call returns Products
-func (ls LocalService) Call() []Product {
-	// Ensure we use a configured service
-	(&ls).configure()
-
-	return ls.products
-}
 
-// RenoteService is a service that acquires info from a remote RESTful service.
-// The zero value is ready to use.
-type RemoteService struct {
-	method string
-	theURL string
-}
-
-// WithMethod override the default method of GET
-func (rs *RemoteService) WithMethod(method string) *RemoteService {
-	rs.method = method
-	return rs
-}
-
-// WithRemote overrides the default remote of http://localhost:80
-func (rs *RemoteService) WithRemote(URL string) *RemoteService {
-	rs.theURL = URL
-
-	return rs
-}
-
-// configure ensures the service has non-empty values for method and remote
-func (rs *RemoteService) configure() {
-	if rs.method == "" {
-		rs.method = http.MethodGet
-	}
+	// Dump the services
+	fmt.Println("Services:")
+
+	for _, name := range f.Names() {
+		svc, _ := f.Service(name)
+		switch s := svc.(type) {
+		case LocalService:
+			(&s).configure()
+			fmt.Printf("- configured = %+v\n", s)
+		case RemoteService:
+			(&s).configure()
+			fmt.Printf("- configured = %+v\n", s)
+		case *CachingService:
+			fmt.Printf("Name: %s, Type: %T, caching %T\n", name, svc, s.svc)
+			continue
+		case *MonitoredService:
+			fmt.Printf("Name: %s, Type: %T, monitoring %T, breaker = %v, probe = %v\n", name, svc, s.svc, s.breaker != nil, s.probe != nil)
+			continue
+		}
 
-	if rs.theURL == "" {
-		rs.theURL = "http://localhost:80"
+		fmt.Printf("Name: %s, Type: %T, %+v\n", name, svc, svc)
 	}
-}
-
-// Call fetches data from the remote service and returns it
-// Params only have to be provided if the service requires it
-func (rs RemoteService) Call() []Product {
-	// Ensure we use a configured service
-	(&rs).configure()
 
-	req, err := http.NewRequest(rs.method, rs.theURL, nil)
+	// Invalid configuration reports every problem, with the line it is on
+	fmt.Println("\nInvalid configuration:")
+	invalid, err := ioutil.TempFile("", "services-*.json")
 	if err != nil {
 		panic(err)
 	}
-
-	req.Header.Set("Accept", "application/json")
-	resp, err := (&http.Client{}).Do(req)
+	defer os.Remove(invalid.Name())
+
+	invalid.WriteString(`[
+    {
+        "name": "noURL",
+        "method": "GET"
+    },
+    {
+        "name": "badTimeout",
+        "url": "http://localhost:8081/products",
+        "timeout": "soon"
+    },
+    {
+        "name": "noURL",
+        "method": "POST"
+    },
+    {
+        "name": "badHealth",
+        "url": "http://localhost:8081/products",
+        "health": {"url": "/health", "interval": "-10s"},
+        "breaker": {"failures": -1, "openFor": "a while"}
+    }
+]
+`)
+	invalid.Close()
+
+	err = (&ServiceFactory{}).WithFilename("services.json").WithFilename(invalid.Name()).Load()
+	fmt.Println(err)
+
+	// Watch a copy of services.json, and change it while a caller is using one of its services
+	fmt.Println("\nHot reload:")
+	dir, err := ioutil.TempDir("", "services")
 	if err != nil {
 		panic(err)
 	}
+	defer os.RemoveAll(dir)
 
-	defer resp.Body.Close()
-	var products []Product
-	if err = json.NewDecoder(resp.Body).Decode(&products); err != nil {
+	watched := filepath.Join(dir, "services.json")
+	original, err := ioutil.ReadFile("services.json")
+	if err != nil {
 		panic(err)
 	}
-
-	return products
-}
-
-// ServiceFactory creates services definitions from a JSON file
-type ServiceFactory struct {
-	filename string
-	services map[string]Service
-}
-
-// WithFilename provides a filename to load (default is services.json)
-func (f *ServiceFactory) WithFilename(filename string) *ServiceFactory {
-	f.filename = filename
-	return f
-}
-
-// configure ensures the factory has values
-func (f *ServiceFactory) configure() {
-	if f.filename == "" {
-		f.filename = "services.json"
+	ioutil.WriteFile(watched, original, 0644)
+
+	var (
+		rf     = (&ServiceFactory{}).WithFilename(watched)
+		events = make(chan ReloadEvent, 10)
+	)
+	unsubscribe := rf.Subscribe(func(event ReloadEvent) { events <- event })
+	if err := rf.Load(); err != nil {
+		panic(err)
 	}
-
-	if len(f.services) == 0 {
-		f.services = map[string]Service{}
+	fmt.Println("loaded:", <-events)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rf.Watch(ctx, 20*time.Millisecond)
+
+	bananas, _ := rf.Service("localBananas")
+	ioutil.WriteFile(watched, []byte(`[
+    {"name": "localBananas", "products": [{"name": "Bananas", "price": "0.99/lb"}]},
+    {"name": "localKiwis", "products": [{"name": "Kiwis", "price": "0.50/ea"}]},
+    {"name": "remoteTangerines", "method": "POST", "url": "http://tangerines-r-us.com/products"}
+]`), 0644)
+	fmt.Println("reloaded:", <-events)
+
+	oldProducts, _ := bananas.Call()
+	newBananas, _ := rf.Service("localBananas")
+	newProducts, _ := newBananas.Call()
+	fmt.Printf("caller still has %+v, new callers get %+v\n", oldProducts, newProducts)
+
+	ioutil.WriteFile(watched, []byte(`[{"name": "localBananas", "method": "FETCH"}]`), 0644)
+	fmt.Println("reloaded:", <-events)
+	fmt.Println("services kept:", rf.Names())
+
+	cancel()
+	unsubscribe()
+
+	// The Services singleton loads services.json the first time a service is needed
+	fmt.Println("\nServices singleton:")
+	svcs := DefaultServices()
+	fmt.Println("same instance:", svcs == DefaultServices())
+
+	showService := func(svcs *Services, name string) {
+		svc, err := svcs.Get(name)
+		fmt.Printf("%s: %T %v\n", name, svc, err)
 	}
-}
-
-// Load loads the services based on a config file
-func (f *ServiceFactory) Load() {
-	f.configure()
+	showService(svcs, "remoteTangerines")
+	showService(svcs, "remoteApples")
+
+	// A test can replace a service, then restore it
+	restore := svcs.Override("remoteTangerines", LocalService{products: []Product{{Name: "Tangerines", Price: "3.00/lb"}}})
+	showService(svcs, "remoteTangerines")
+	restore()
+	showService(svcs, "remoteTangerines")
+
+	// Or use only local services
+	showService(svcs.LocalOnly(), "remoteTangerines")
+
+	// Fall back to a local service when the remote service fails
+	fmt.Println("\nFallback and merge:")
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
 
-	file, err := os.Open(f.filename)
-	if err != nil {
-		panic(err)
-	}
+		json.NewEncoder(w).Encode([]Product{{Name: "Tangerines", Price: "3.25/lb"}})
+	}))
+	remote := *(&RemoteService{}).WithRemote(srv.URL)
+	local := LocalService{products: []Product{{Name: "Tangerines", Price: "2.99/lb"}, {Name: "Limes", Price: "0.40/ea"}}}
+	fallback := *(&FallbackService{}).WithServices(remote, local)
+	products, err := fallback.Call()
+	fmt.Printf("fallback (remote fails) = %+v, err = %v\n", products, err)
+	products, err = fallback.Call()
+	fmt.Printf("fallback (remote succeeds) = %+v, err = %v\n", products, err)
+
+	// Merge remote and local products, the remote price of tangerines wins
+	products, err = (&MergingService{}).WithServices(remote, local).Call()
+	fmt.Printf("merged = %+v, err = %v\n", products, err)
+	srv.Close()
+
+	// Cache a remote service that sends an ETag and a max-age of 60 seconds, using a clock that can be advanced
+	fmt.Println("\nCaching:")
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const etag = `"v1"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.Header.Get("If-None-Match") == etag {
+			fmt.Println("  server: not modified")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
 
-	var svcs []struct {
-		Name     string    `json:"name"`
-		Products []Product `json:"products"`
-		Method   string    `json:"method"`
-		URL      string    `json:"url"`
+		fmt.Println("  server: products")
+		json.NewEncoder(w).Encode([]Product{{Name: "Tangerines", Price: "3.25/lb"}})
+	}))
+
+	now := time.Now()
+	cached := (&CachingService{}).
+		WithRemote(*(&RemoteService{}).WithRemote(srv.URL)).
+		WithClock(func() time.Time { return now })
+	for _, advance := range []time.Duration{0, 30 * time.Second, 31 * time.Second, 0} {
+		now = now.Add(advance)
+		products, err = cached.Call()
+		fmt.Printf("after %v: %+v, err = %v\n", advance, products, err)
 	}
+	srv.Close()
+
+	// A service that goes down opens its circuit after 2 failures, and closes again once a probe and a call succeed
+	fmt.Println("\nHealth and circuit breaking:")
+	var down int32
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&down) == 1 {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
 
-	if err := json.NewDecoder(file).Decode(&svcs); err != nil {
+		json.NewEncoder(w).Encode([]Product{{Name: "Tangerines", Price: "3.25/lb"}})
+	}))
+
+	monitoredFile := filepath.Join(dir, "monitored.json")
+	ioutil.WriteFile(monitoredFile, []byte(fmt.Sprintf(`[
+    {
+        "name": "monitoredTangerines",
+        "url": %q,
+        "health": {"interval": "10s"},
+        "breaker": {"failures": 2, "openFor": "1m"}
+    },
+    {"name": "localBananas", "products": [{"name": "Bananas", "price": "1.23/lb"}]}
+]`, srv.URL)), 0644)
+
+	now = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mf := (&ServiceFactory{}).WithFilename(monitoredFile).WithClock(func() time.Time { return now })
+	if err := mf.Load(); err != nil {
 		panic(err)
 	}
+	monitored, _ := mf.Service("monitoredTangerines")
 
-	for _, svc := range svcs {
-		if svc.Method == "" {
-			f.services[svc.Name] = LocalService{products: svc.Products}
-		} else {
-			f.services[svc.Name] = RemoteService{method: svc.Method, theURL: svc.URL}
-		}
+	callMonitored := func(what string) {
+		products, err := monitored.Call()
+		fmt.Printf("%s: %+v, err = %v, circuit %v\n", what, products, err, monitored.(*MonitoredService).breaker.State())
+	}
+	callMonitored("up")
+
+	atomic.StoreInt32(&down, 1)
+	callMonitored("down")
+	callMonitored("down")
+	callMonitored("open, server not called")
+
+	// A failed probe keeps the circuit open, a healthy probe makes it half open
+	mf.CheckHealth(context.Background())
+	atomic.StoreInt32(&down, 0)
+	mf.CheckHealth(context.Background())
+	fmt.Println("probe not due yet, circuit", monitored.(*MonitoredService).breaker.State())
+	now = now.Add(10 * time.Second)
+	mf.CheckHealth(context.Background())
+	fmt.Println("healthy probe, circuit", monitored.(*MonitoredService).breaker.State())
+	callMonitored("trial call")
+
+	// The status report, as served by StatusHandler
+	statusRec := httptest.NewRecorder()
+	mf.StatusHandler().ServeHTTP(statusRec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	fmt.Printf("status %d:\n%s", statusRec.Code, statusRec.Body)
+	srv.Close()
+
+	// Call the catalog server, if it is running (go run ./cmd/catalog)
+	fmt.Println("\nCatalog:")
+	for _, name := range []string{"remoteDefault", "remoteCheapFruit", "remoteProduct", "remoteSearch"} {
+		svc, _ := f.Service(name)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		products, err := svc.CallContext(ctx)
+		fmt.Printf("%s = %+v, err = %v\n", name, products, err)
+		cancel()
 	}
-}
-
-func main() {
-	// Set up a factory
-	var f ServiceFactory
-	f.Load()
-
-	// Dump the services
-	fmt.Printf("%s:\n", f.filename)
-
-	for name, svc := range f.services {
-		if ls, isa := svc.(LocalService); isa {
-			(&ls).configure()
-			fmt.Printf("- configured = %+v\n", ls)
-		} else {
-			rs := svc.(RemoteService)
-			(&rs).configure()
-			fmt.Printf("- configured = %+v\n", rs)
-		}
 
-		fmt.Printf("Name: %s, Type: %T, %+v\n", name, svc, svc)
+	// Parameters and bodies, echoed back by a server
+	fmt.Println("\nParameters and bodies:")
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		json.NewEncoder(w).Encode([]Product{
+			{Name: r.Method + " " + r.URL.String(), Price: r.Header.Get("Content-Type")},
+			{Name: string(body)},
+		})
+	}))
+
+	for _, rs := range []*RemoteService{
+		(&RemoteService{}).
+			WithRemote(srv.URL+"/stores/{store}/products").
+			WithPathParam("store", "Fruit & Veg").
+			WithQuery("since", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)).
+			WithQuery("tag", "citrus", "organic"),
+		(&RemoteService{}).
+			WithMethod(http.MethodPost).
+			WithRemote(srv.URL + "/orders").
+			WithJSONBody(map[string]interface{}{"product": "Tangerines", "quantity": 3}),
+		(&RemoteService{}).
+			WithMethod(http.MethodPost).
+			WithRemote(srv.URL+"/orders").
+			WithForm("product", "Limes").
+			WithForm("quantity", 12),
+		(&RemoteService{}).WithRemote(srv.URL + "/stores/{store}/products"),
+		(&RemoteService{}).WithRemote(srv.URL).WithQuery("weight", []float64{1.5}),
+	} {
+		products, err := rs.Call()
+		fmt.Printf("%+v, err = %v\n", products, err)
 	}
+	srv.Close()
 }
@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Content types of request bodies
+const (
+	jsonContentType = "application/json"
+	formContentType = "application/x-www-form-urlencoded"
+)
+
+// pathParamRegex matches a {name} placeholder in a url
+var pathParamRegex = regexp.MustCompile(`\{(\w+)\}`)
+
+// formatParam formats a query, path or form parameter value.
+// Strings, bools, numbers, time.Time (as RFC 3339), time.Duration and fmt.Stringer are supported.
+func formatParam(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case time.Time:
+		return v.Format(time.RFC3339), nil
+	case time.Duration:
+		return v.String(), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	default:
+		return "", fmt.Errorf("unsupported parameter type %T", value)
+	}
+}
+
+// addParams formats values and adds them to a copy of params, so that copies of the service made before are not
+// affected. The first unsupported value is recorded as an error, which is returned when the service is called.
+func (rs *RemoteService) addParams(params url.Values, kind, name string, values []interface{}) url.Values {
+	result := url.Values{}
+	for key, vals := range params {
+		result[key] = append([]string(nil), vals...)
+	}
+
+	for _, value := range values {
+		s, err := formatParam(value)
+		if (err != nil) && (rs.paramErr == nil) {
+			rs.paramErr = fmt.Errorf("%s parameter %q: %w", kind, name, err)
+		}
+
+		result.Add(name, s)
+	}
+
+	return result
+}
+
+// WithQuery adds query parameter values, in addition to any in the url.
+// Several values add the parameter several times, eg name=a&name=b.
+func (rs *RemoteService) WithQuery(name string, values ...interface{}) *RemoteService {
+	rs.query = rs.addParams(rs.query, "query", name, values)
+	return rs
+}
+
+// WithPathParam sets the value of a {name} placeholder in the url, which is path escaped
+func (rs *RemoteService) WithPathParam(name string, value interface{}) *RemoteService {
+	// Replace any previous value
+	params := rs.addParams(rs.pathParams, "path", name, []interface{}{value})
+	params[name] = params[name][len(params[name])-1:]
+	rs.pathParams = params
+
+	return rs
+}
+
+// WithJSONBody sets a value to send as a JSON request body, eg with POST
+func (rs *RemoteService) WithJSONBody(body interface{}) *RemoteService {
+	rs.jsonBody = body
+	return rs
+}
+
+// WithForm adds form values to send as a form encoded request body, eg with POST.
+// Several values add the field several times.
+func (rs *RemoteService) WithForm(name string, values ...interface{}) *RemoteService {
+	rs.form = rs.addParams(rs.form, "form", name, values)
+	return rs
+}
+
+// request returns the url, body and content type of the request, with the path and query parameters applied
+func (rs RemoteService) request() (string, []byte, string, error) {
+	if rs.paramErr != nil {
+		return "", nil, "", rs.paramErr
+	}
+
+	// Fill in path parameters, and check none are missing
+	var missing []string
+	target := pathParamRegex.ReplaceAllStringFunc(rs.theURL, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+		if values, have := rs.pathParams[name]; have {
+			return url.PathEscape(values[0])
+		}
+
+		missing = append(missing, name)
+		return placeholder
+	})
+
+	if len(missing) > 0 {
+		return "", nil, "", fmt.Errorf("%s: no value for path parameters %s", rs.theURL, strings.Join(missing, ", "))
+	}
+
+	if len(rs.query) > 0 {
+		u, err := url.Parse(target)
+		if err != nil {
+			return "", nil, "", err
+		}
+
+		query := u.Query()
+		for name, values := range rs.query {
+			query[name] = append(query[name], values...)
+		}
+		u.RawQuery = query.Encode()
+		target = u.String()
+	}
+
+	switch {
+	case (rs.jsonBody != nil) && (rs.form != nil):
+		return "", nil, "", fmt.Errorf("%s: cannot send both a JSON body and a form", rs.theURL)
+
+	case rs.jsonBody != nil:
+		body, err := json.Marshal(rs.jsonBody)
+		if err != nil {
+			return "", nil, "", fmt.Errorf("%s: JSON body: %w", rs.theURL, err)
+		}
+
+		return target, body, jsonContentType, nil
+
+	case rs.form != nil:
+		return target, []byte(rs.form.Encode()), formContentType, nil
+	}
+
+	return target, nil, "", nil
+}
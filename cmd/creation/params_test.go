@@ -0,0 +1,215 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testStringer is a fmt.Stringer parameter value
+type testStringer struct{}
+
+func (testStringer) String() string { return "stringer" }
+
+func TestFormatParam(t *testing.T) {
+	for _, test := range []struct {
+		value interface{}
+		want  string
+	}{
+		{"a b", "a b"},
+		{true, "true"},
+		{-12, "-12"},
+		{int64(1) << 40, "1099511627776"},
+		{int32(-3), "-3"},
+		{uint(7), "7"},
+		{uint64(1) << 63, "9223372036854775808"},
+		{uint32(9), "9"},
+		{1.5, "1.5"},
+		{float64(3), "3"},
+		{float32(0.1), "0.1"},
+		{time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC), "2021-02-03T04:05:06Z"},
+		{90 * time.Second, "1m30s"},
+		{testStringer{}, "stringer"},
+	} {
+		got, err := formatParam(test.value)
+		if err != nil {
+			t.Errorf("%T %v: %s", test.value, test.value, err)
+		} else if got != test.want {
+			t.Errorf("%T %v: formatted %q, want %q", test.value, test.value, got, test.want)
+		}
+	}
+
+	for _, value := range []interface{}{nil, []string{"a"}, struct{}{}, int8(1)} {
+		if _, err := formatParam(value); err == nil {
+			t.Errorf("%T should be unsupported", value)
+		}
+	}
+}
+
+func TestRemoteRequest(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		rs          *RemoteService
+		url         string
+		body        string
+		contentType string
+		err         string
+	}{
+		{
+			name: "no params",
+			rs:   (&RemoteService{}).WithRemote("http://localhost/products"),
+			url:  "http://localhost/products",
+		},
+		{
+			name: "query",
+			rs: (&RemoteService{}).WithRemote("http://localhost/products?size=10").
+				WithQuery("name", "a b", "c").
+				WithQuery("size", 20),
+			url: "http://localhost/products?name=a+b&name=c&size=10&size=20",
+		},
+		{
+			name: "path",
+			rs: (&RemoteService{}).WithRemote("http://localhost/{kind}/{id}").
+				WithPathParam("kind", "products").
+				WithPathParam("id", "first").
+				WithPathParam("id", "a/b c"),
+			url: "http://localhost/products/a%2Fb%20c",
+		},
+		{
+			name: "missing path",
+			rs:   (&RemoteService{}).WithRemote("http://localhost/{kind}/{id}/{page}").WithPathParam("id", 1),
+			err:  "http://localhost/{kind}/{id}/{page}: no value for path parameters kind, page",
+		},
+		{
+			name: "unsupported",
+			rs: (&RemoteService{}).WithRemote("http://localhost/products").
+				WithQuery("size", 10).
+				WithQuery("names", []string{"a"}).
+				WithForm("when", struct{}{}),
+			err: `query parameter "names": unsupported parameter type []string`,
+		},
+		{
+			name:        "JSON body",
+			rs:          (&RemoteService{}).WithRemote("http://localhost/products").WithJSONBody(map[string]int{"size": 2}),
+			url:         "http://localhost/products",
+			body:        `{"size":2}`,
+			contentType: jsonContentType,
+		},
+		{
+			name: "bad JSON body",
+			rs:   (&RemoteService{}).WithRemote("http://localhost/products").WithJSONBody(func() {}),
+			err:  "http://localhost/products: JSON body: json: unsupported type: func()",
+		},
+		{
+			name:        "form",
+			rs:          (&RemoteService{}).WithRemote("http://localhost/products").WithForm("name", "a", true),
+			url:         "http://localhost/products",
+			body:        "name=a&name=true",
+			contentType: formContentType,
+		},
+		{
+			name: "JSON body and form",
+			rs: (&RemoteService{}).WithRemote("http://localhost/products").
+				WithJSONBody(map[string]int{}).
+				WithForm("name", "a"),
+			err: "http://localhost/products: cannot send both a JSON body and a form",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			url, body, contentType, err := test.rs.request()
+			if test.err != "" {
+				if (err == nil) || (err.Error() != test.err) {
+					t.Errorf("err = %v, want %s", err, test.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if (url != test.url) || (string(body) != test.body) || (contentType != test.contentType) {
+				t.Errorf("request %s %q %q, want %s %q %q", url, body, contentType, test.url, test.body, test.contentType)
+			}
+		})
+	}
+}
+
+func TestRemoteParamsCopied(t *testing.T) {
+	base := (&RemoteService{}).WithRemote("http://localhost/{id}").WithPathParam("id", 1).WithQuery("a", 1)
+
+	// Adding parameters to a copy does not change the original
+	copied := *base
+	copied.WithPathParam("id", 2).WithQuery("a", 2).WithForm("b", 3)
+
+	if url, body, _, _ := base.request(); (url != "http://localhost/1?a=1") || (body != nil) {
+		t.Errorf("original request %s %q, want http://localhost/1?a=1 and no body", url, body)
+	}
+
+	if url, body, _, _ := copied.request(); (url != "http://localhost/2?a=1&a=2") || (string(body) != "b=3") {
+		t.Errorf("copied request %s %q, want http://localhost/2?a=1&a=2 and b=3", url, body)
+	}
+}
+
+func TestRemoteParamsSent(t *testing.T) {
+	var got struct {
+		method, uri, contentType, body string
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		got.method, got.uri, got.contentType, got.body = r.Method, r.RequestURI, r.Header.Get("Content-Type"), string(body)
+		json.NewEncoder(w).Encode([]Product{})
+	}))
+	defer srv.Close()
+
+	rs := (&RemoteService{}).
+		WithMethod(http.MethodPost).
+		WithRemote(srv.URL+"/products/{name}").
+		WithPathParam("name", "Red Apples").
+		WithQuery("size", 2).
+		WithForm("minPrice", 1.25)
+
+	if _, err := rs.Call(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := fmt.Sprintf("%s %s %s %s", http.MethodPost, "/products/Red%20Apples?size=2", formContentType, "minPrice=1.25")
+	if s := fmt.Sprintf("%s %s %s %s", got.method, got.uri, got.contentType, got.body); s != want {
+		t.Errorf("request %s, want %s", s, want)
+	}
+}
+
+func TestParamValues(t *testing.T) {
+	for _, test := range []struct {
+		value      interface{}
+		allowArray bool
+		want       []interface{}
+		err        string
+	}{
+		{"a", false, []interface{}{"a"}, ""},
+		{1.5, false, []interface{}{1.5}, ""},
+		{true, true, []interface{}{true}, ""},
+		{[]interface{}{"a", 2.0}, true, []interface{}{"a", 2.0}, ""},
+		{[]interface{}{"a"}, false, nil, "cannot be an array"},
+		{map[string]interface{}{}, false, nil, "must be a string, number or bool"},
+		{nil, true, nil, "must be a string, number or bool, or an array of them"},
+		{[]interface{}{[]interface{}{}}, true, nil, "must be a string, number or bool, or an array of them"},
+	} {
+		values, err := paramValues(test.value, test.allowArray)
+		if test.err != "" {
+			if (err == nil) || (err.Error() != test.err) {
+				t.Errorf("%v: err = %v, want %s", test.value, err, test.err)
+			}
+		} else if !reflect.DeepEqual(values, test.want) || (err != nil) {
+			t.Errorf("%v: values %v, err %v, want %v", test.value, values, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// fileStamp is the modification time and size of a file, which change when the file is written.
+// A file that cannot be read has a zero stamp.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// stampOf returns the stamp of a file
+func stampOf(filename string) fileStamp {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return fileStamp{}
+	}
+
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
+
+// equal returns true if two stamps are the same
+func (s fileStamp) equal(o fileStamp) bool {
+	return s.modTime.Equal(o.modTime) && (s.size == o.size)
+}
+
+// ReloadEvent describes the result of loading the services.
+// If the configuration is invalid, Err is the problems, and the services are unchanged.
+type ReloadEvent struct {
+	Added   []string
+	Removed []string
+	Changed []string
+	Err     error
+}
+
+// Empty returns true if no services were added, removed or changed
+func (e ReloadEvent) Empty() bool {
+	return (len(e.Added) == 0) && (len(e.Removed) == 0) && (len(e.Changed) == 0)
+}
+
+// String is Stringer for ReloadEvent
+func (e ReloadEvent) String() string {
+	if e.Err != nil {
+		return fmt.Sprintf("invalid, services unchanged:\n%s", e.Err)
+	}
+
+	return fmt.Sprintf("added %v, removed %v, changed %v", e.Added, e.Removed, e.Changed)
+}
+
+// diff returns the names of the services that were added, removed or changed between two sets of configs, sorted
+func diff(oldConfigs, newConfigs map[string]ServiceConfig) ReloadEvent {
+	var event ReloadEvent
+	for name, newConfig := range newConfigs {
+		oldConfig, have := oldConfigs[name]
+		if !have {
+			event.Added = append(event.Added, name)
+			continue
+		}
+
+		// Moving a service within a file is not a change
+		oldConfig.source, newConfig.source = "", ""
+		if !reflect.DeepEqual(oldConfig, newConfig) {
+			event.Changed = append(event.Changed, name)
+		}
+	}
+
+	for name := range oldConfigs {
+		if _, have := newConfigs[name]; !have {
+			event.Removed = append(event.Removed, name)
+		}
+	}
+
+	// A service that refers to a service that was added, removed or changed has also changed
+	affected := map[string]bool{}
+	for _, names := range [][]string{event.Added, event.Removed, event.Changed} {
+		for _, name := range names {
+			affected[name] = true
+		}
+	}
+
+	for more := true; more; {
+		more = false
+		for name, newConfig := range newConfigs {
+			if affected[name] {
+				continue
+			}
+
+			for _, ref := range newConfig.refs() {
+				if affected[ref] {
+					affected[name] = true
+					event.Changed = append(event.Changed, name)
+					more = true
+					break
+				}
+			}
+		}
+	}
+
+	sort.Strings(event.Added)
+	sort.Strings(event.Removed)
+	sort.Strings(event.Changed)
+
+	return event
+}
+
+// Subscribe calls fn after each load that adds, removes or changes services, or that fails.
+// fn is called on the goroutine that loaded the services, and must not block for long.
+// The returned function unsubscribes.
+func (f *ServiceFactory) Subscribe(fn func(ReloadEvent)) func() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.configure()
+	id := f.nextID
+	f.nextID++
+	f.subscribers[id] = fn
+
+	return func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		delete(f.subscribers, id)
+	}
+}
+
+// subscriberList returns the subscribers in the order they subscribed.
+// Must be called with the mutex held.
+func (f *ServiceFactory) subscriberList() []func(ReloadEvent) {
+	ids := make([]int, 0, len(f.subscribers))
+	for id := range f.subscribers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	subscribers := make([]func(ReloadEvent), len(ids))
+	for i, id := range ids {
+		subscribers[i] = f.subscribers[id]
+	}
+
+	return subscribers
+}
+
+// changed returns true if any configuration file has been written since it was last loaded
+func (f *ServiceFactory) changed() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for _, filename := range f.filenames {
+		if !stampOf(filename).equal(f.stamps[filename]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Watch checks the configuration files every interval in a new goroutine, until the context is done.
+// When any file is written, the services are loaded again: see Load.
+// Environment variables are only read when a file changes.
+func (f *ServiceFactory) Watch(ctx context.Context, interval time.Duration) {
+	f.mu.Lock()
+	f.configure()
+	f.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case <-ticker.C:
+				if f.changed() {
+					// Errors are reported to subscribers
+					f.Load()
+				}
+			}
+		}
+	}()
+}
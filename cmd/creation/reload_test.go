@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// rewriteConfig rewrites a config file, with a later modification time so that the change is seen even if the size
+// and time are otherwise unchanged
+func rewriteConfig(t *testing.T, filename, data string, modTime time.Time) {
+	t.Helper()
+
+	writeConfig(t, "", filename, data)
+	if err := os.Chtimes(filename, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// nextEvent waits for the next reload event
+func nextEvent(t *testing.T, events <-chan ReloadEvent) ReloadEvent {
+	t.Helper()
+
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload event")
+	}
+
+	return ReloadEvent{}
+}
+
+func TestWatch(t *testing.T) {
+	var (
+		modTime  = time.Now().Add(-time.Hour)
+		filename = writeConfig(t, t.TempDir(), "services.json", "")
+		f        = (&ServiceFactory{}).WithFilename(filename).WithEnviron(func() []string { return nil })
+		events   = make(chan ReloadEvent, 10)
+	)
+
+	rewriteConfig(t, filename, `[
+  {"name": "apples", "url": "http://localhost:1", "breaker": {}},
+  {"name": "pears", "products": [{"name": "Pears", "price": "2.00/lb"}]},
+  {"name": "fruit", "merge": ["apples", "pears"]},
+  {"name": "kiwis", "products": []}
+]`, modTime)
+	if err := f.Load(); err != nil {
+		t.Fatal(err)
+	}
+	apples, _ := f.Service("apples")
+
+	unsubscribe := f.Subscribe(func(event ReloadEvent) { events <- event })
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f.Watch(ctx, 5*time.Millisecond)
+
+	// Changing pears also changes fruit, which merges it
+	modTime = modTime.Add(time.Second)
+	rewriteConfig(t, filename, `[
+  {"name": "fruit", "merge": ["apples", "pears"]},
+  {"name": "apples", "url": "http://localhost:1", "breaker": {}},
+  {"name": "pears", "products": [{"name": "Pears", "price": "2.50/lb"}]},
+  {"name": "plums", "products": []}
+]`, modTime)
+
+	event := nextEvent(t, events)
+	want := ReloadEvent{Added: []string{"plums"}, Removed: []string{"kiwis"}, Changed: []string{"fruit", "pears"}}
+	if !reflect.DeepEqual(event, want) {
+		t.Errorf("event %+v, want %+v", event, want)
+	}
+
+	// An unchanged service, even if it moved within the file, is the same instance, which keeps its circuit
+	if svc, _ := f.Service("apples"); svc.(*MonitoredService) != apples.(*MonitoredService) {
+		t.Error("an unchanged service was rebuilt")
+	}
+
+	// A bad edit is reported, and the previous configuration is kept
+	modTime = modTime.Add(time.Second)
+	rewriteConfig(t, filename, `[{"name": "apples", "fallback": ["missing"]}]`, modTime)
+
+	if event := nextEvent(t, events); event.Err == nil {
+		t.Errorf("event %+v, want an error", event)
+	}
+
+	if got, want := f.Names(), []string{"apples", "fruit", "pears", "plums"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("names %v, want %v", got, want)
+	}
+
+	// Fixing the file loads it, compared with the last valid configuration
+	modTime = modTime.Add(time.Second)
+	rewriteConfig(t, filename, `[{"name": "apples", "url": "http://localhost:1", "breaker": {}}]`, modTime)
+
+	event = nextEvent(t, events)
+	want = ReloadEvent{Removed: []string{"fruit", "pears", "plums"}}
+	if !reflect.DeepEqual(event, want) {
+		t.Errorf("event %+v, want %+v", event, want)
+	}
+
+	// Once the context is done, changes are not loaded
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+
+	modTime = modTime.Add(time.Second)
+	rewriteConfig(t, filename, `[]`, modTime)
+	time.Sleep(20 * time.Millisecond)
+
+	select {
+	case event := <-events:
+		t.Errorf("event %+v after the watch stopped", event)
+	default:
+	}
+}
+
+func TestDiff(t *testing.T) {
+	var (
+		local  = ServiceConfig{Name: "local", Products: []Product{}}
+		remote = ServiceConfig{Name: "remote", URL: "http://localhost"}
+		cached = ServiceConfig{Name: "cached", Cache: "remote"}
+		first  = ServiceConfig{Name: "first", Fallback: []string{"cached", "local"}}
+	)
+
+	moved := local
+	moved.source = "services.json:10"
+
+	changed := remote
+	changed.Attempts = 3
+
+	// Services that refer to a changed service, directly or indirectly, have changed
+	event := diff(
+		map[string]ServiceConfig{"local": local, "remote": remote, "cached": cached, "first": first},
+		map[string]ServiceConfig{"local": moved, "remote": changed, "cached": cached, "first": first},
+	)
+
+	want := ReloadEvent{Changed: []string{"cached", "first", "remote"}}
+	if !reflect.DeepEqual(event, want) {
+		t.Errorf("event %+v, want %+v", event, want)
+	}
+
+	if event := diff(map[string]ServiceConfig{"local": local}, map[string]ServiceConfig{"local": moved}); !event.Empty() {
+		t.Errorf("moving a service is not a change, got %+v", event)
+	}
+}
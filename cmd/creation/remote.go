@@ -0,0 +1,302 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RemoteService defaults
+const (
+	defaultTimeout    = 10 * time.Second
+	defaultBackoff    = 100 * time.Millisecond
+	defaultMaxBackoff = 5 * time.Second
+)
+
+// StatusError is the error returned when the remote service responds with a status other than 2xx
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	// RetryAfter is the delay requested by a Retry-After header, or 0 if there is none
+	RetryAfter time.Duration
+}
+
+// Error is error for StatusError
+func (e StatusError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Temporary returns true if the status indicates the request may succeed if it is tried again
+func (e StatusError) Temporary() bool {
+	return (e.StatusCode == http.StatusTooManyRequests) || (e.StatusCode >= 500)
+}
+
+// RenoteService is a service that acquires info from a remote RESTful service.
+// The zero value is ready to use.
+type RemoteService struct {
+	method     string
+	theURL     string
+	timeout    time.Duration
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	// retryWrites retries methods that may change the server, such as POST and PUT
+	retryWrites bool
+	header      http.Header
+	query       url.Values
+	pathParams  url.Values
+	jsonBody    interface{}
+	form        url.Values
+	paramErr    error
+}
+
+// WithMethod override the default method of GET
+func (rs *RemoteService) WithMethod(method string) *RemoteService {
+	rs.method = method
+	return rs
+}
+
+// WithRemote overrides the default remote of http://localhost:80.
+// The url may contain {name} placeholders for path parameters: see WithPathParam.
+func (rs *RemoteService) WithRemote(URL string) *RemoteService {
+	rs.theURL = URL
+
+	return rs
+}
+
+// WithTimeout overrides the default timeout of 10 seconds for each attempt
+func (rs *RemoteService) WithTimeout(timeout time.Duration) *RemoteService {
+	rs.timeout = timeout
+	return rs
+}
+
+// WithRetry overrides the default of one attempt with no retries.
+// After a failed attempt, the next attempt waits for a random delay between half and all of backoff, which doubles
+// after each attempt up to maxBackoff. A Retry-After header overrides the delay, up to maxBackoff.
+// Only network errors, 5xx and 429 Too Many Requests responses are retried.
+// Only methods that do not change the server (GET, HEAD and OPTIONS) are retried, unless WithRetryWrites is used,
+// since a failed attempt of a write such as a POST may still have been applied.
+// A zero backoff or maxBackoff is replaced with the default of 100ms or 5s.
+func (rs *RemoteService) WithRetry(attempts int, backoff, maxBackoff time.Duration) *RemoteService {
+	rs.attempts = attempts
+	rs.backoff = backoff
+	rs.maxBackoff = maxBackoff
+	return rs
+}
+
+// WithRetryWrites enables or disables retrying methods that may change the server, such as POST and PUT, which is
+// only safe if the remote service handles repeated requests, for example with an idempotency key header
+func (rs *RemoteService) WithRetryWrites(retryWrites bool) *RemoteService {
+	rs.retryWrites = retryWrites
+	return rs
+}
+
+// readOnlyMethods are the methods that are retried without WithRetryWrites
+var readOnlyMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+}
+
+// WithHeader adds a header to send with each request, in addition to Accept: application/json
+func (rs *RemoteService) WithHeader(name, value string) *RemoteService {
+	// Copy the headers, so that copies of the service made before this call are not affected
+	header := rs.header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Add(name, value)
+	rs.header = header
+
+	return rs
+}
+
+// configure ensures the service has non-empty values for method, remote, timeout and retries
+func (rs *RemoteService) configure() {
+	if rs.method == "" {
+		rs.method = http.MethodGet
+	}
+
+	if rs.theURL == "" {
+		rs.theURL = "http://localhost:80"
+	}
+
+	if rs.timeout <= 0 {
+		rs.timeout = defaultTimeout
+	}
+
+	if rs.attempts <= 0 {
+		rs.attempts = 1
+	}
+
+	if rs.backoff <= 0 {
+		rs.backoff = defaultBackoff
+	}
+
+	if rs.maxBackoff <= 0 {
+		rs.maxBackoff = defaultMaxBackoff
+	}
+}
+
+// Call fetches data from the remote service and returns it
+func (rs RemoteService) Call() ([]Product, error) {
+	return rs.CallContext(context.Background())
+}
+
+// CallContext fetches data from the remote service and returns it, retrying temporary failures.
+// It gives up as soon as the context is done, including while waiting to retry.
+func (rs RemoteService) CallContext(ctx context.Context) ([]Product, error) {
+	products, _, err := rs.fetch(ctx, "")
+	return products, err
+}
+
+// fetched describes a response from the remote service, for caching
+type fetched struct {
+	// notModified is true if the products have not changed since the ETag sent with the request
+	notModified  bool
+	etag         string
+	cacheControl string
+}
+
+// fetch fetches data from the remote service, retrying temporary failures.
+// If etag is not empty, it is sent as If-None-Match, and a 304 Not Modified response returns no products.
+func (rs RemoteService) fetch(ctx context.Context, etag string) ([]Product, fetched, error) {
+	// Ensure we use a configured service
+	(&rs).configure()
+
+	// Errors refer to the url with the path and query parameters applied
+	target, body, contentType, err := rs.request()
+	if err != nil {
+		return nil, fetched{}, err
+	}
+	rs.theURL = target
+
+	attempts := rs.attempts
+	if !readOnlyMethods[rs.method] && !rs.retryWrites {
+		attempts = 1
+	}
+
+	backoff := rs.backoff
+	for attempt := 1; ; attempt++ {
+		products, resp, err := rs.attempt(ctx, etag, body, contentType)
+		if (err == nil) || (attempt == attempts) || !retryable(ctx, err) {
+			return products, resp, err
+		}
+
+		// Wait a random delay between half and all of the backoff, or as long as the server asked for
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		var statusErr StatusError
+		if errors.As(err, &statusErr) && (statusErr.RetryAfter > 0) {
+			delay = statusErr.RetryAfter
+		}
+		if delay > rs.maxBackoff {
+			delay = rs.maxBackoff
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fetched{}, fmt.Errorf("%s %s: %w (after %d attempts, last error: %v)", rs.method, rs.theURL, ctx.Err(), attempt, err)
+		case <-timer.C:
+		}
+
+		if backoff *= 2; backoff > rs.maxBackoff {
+			backoff = rs.maxBackoff
+		}
+	}
+}
+
+// attempt makes one request to the remote service, with a body if it is not nil.
+// The attempt fails with an error wrapping context.DeadlineExceeded if it takes longer than the timeout.
+func (rs RemoteService) attempt(ctx context.Context, etag string, body []byte, contentType string) ([]Product, fetched, error) {
+	ctx, cancel := context.WithTimeout(ctx, rs.timeout)
+	defer cancel()
+
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, rs.method, rs.theURL, bodyReader)
+	if err != nil {
+		// Not wrapped, an invalid request is not retryable
+		return nil, fetched{}, fmt.Errorf("%s %s: invalid request: %v", rs.method, rs.theURL, err)
+	}
+
+	for name, values := range rs.header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fetched{}, err
+	}
+
+	defer resp.Body.Close()
+	result := fetched{
+		notModified:  (etag != "") && (resp.StatusCode == http.StatusNotModified),
+		etag:         resp.Header.Get("ETag"),
+		cacheControl: resp.Header.Get("Cache-Control"),
+	}
+	if result.notModified {
+		return nil, result, nil
+	}
+
+	if (resp.StatusCode < 200) || (resp.StatusCode > 299) {
+		// Read the body, so the connection can be reused
+		io.Copy(ioutil.Discard, resp.Body)
+
+		statusErr := StatusError{Method: rs.method, URL: rs.theURL, StatusCode: resp.StatusCode}
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); (err == nil) && (seconds > 0) {
+			statusErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+
+		return nil, fetched{}, statusErr
+	}
+
+	var products []Product
+	if err = json.NewDecoder(resp.Body).Decode(&products); err != nil {
+		return nil, fetched{}, fmt.Errorf("%s %s: invalid response: %w", rs.method, rs.theURL, err)
+	}
+
+	return products, result, nil
+}
+
+// retryable returns true if a failed attempt may succeed if it is tried again.
+// Network errors and temporary statuses are retryable, unless the context is done.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var statusErr StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Temporary()
+	}
+
+	// Errors from the client are always *url.Error, while an invalid request or response is not
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer fails with a status a number of times, then responds with products
+type flakyServer struct {
+	*httptest.Server
+	requests int32
+}
+
+// newFlakyServer starts a server that fails with the given status the given number of times, then responds with
+// products. Each request waits for delay before responding. The server is closed when the test finishes.
+func newFlakyServer(t *testing.T, failures int32, status int, delay time.Duration) *flakyServer {
+	t.Helper()
+
+	fs := &flakyServer{}
+	fs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&fs.requests, 1)
+
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+
+		if n <= failures {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+
+		json.NewEncoder(w).Encode([]Product{{Name: "Tangerines", Price: "3.25/lb"}})
+	}))
+	t.Cleanup(fs.Close)
+
+	return fs
+}
+
+// assertRequests fails if the server did not receive the expected number of requests
+func (fs *flakyServer) assertRequests(t *testing.T, want int32) {
+	t.Helper()
+
+	if got := atomic.LoadInt32(&fs.requests); got != want {
+		t.Errorf("server received %d requests, want %d", got, want)
+	}
+}
+
+// assertStatusError fails if an error is not a StatusError with the expected status
+func assertStatusError(t *testing.T, err error, want int) {
+	t.Helper()
+
+	var statusErr StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("err = %v, want a StatusError", err)
+	}
+
+	if statusErr.StatusCode != want {
+		t.Errorf("status = %d, want %d", statusErr.StatusCode, want)
+	}
+}
+
+func TestRemoteRetrySucceeds(t *testing.T) {
+	srv := newFlakyServer(t, 2, http.StatusServiceUnavailable, 0)
+
+	products, err := (&RemoteService{}).
+		WithRemote(srv.URL).
+		WithRetry(3, time.Millisecond, 10*time.Millisecond).
+		Call()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if (len(products) != 1) || (products[0].Name != "Tangerines") {
+		t.Errorf("products = %+v", products)
+	}
+	srv.assertRequests(t, 3)
+}
+
+func TestRemoteRetryGivesUp(t *testing.T) {
+	srv := newFlakyServer(t, 5, http.StatusTooManyRequests, 0)
+
+	_, err := (&RemoteService{}).
+		WithRemote(srv.URL).
+		WithRetry(3, time.Millisecond, 10*time.Millisecond).
+		Call()
+	assertStatusError(t, err, http.StatusTooManyRequests)
+	srv.assertRequests(t, 3)
+}
+
+func TestRemoteNoRetries(t *testing.T) {
+	srv := newFlakyServer(t, 2, http.StatusServiceUnavailable, 0)
+
+	_, err := (&RemoteService{}).WithRemote(srv.URL).Call()
+	assertStatusError(t, err, http.StatusServiceUnavailable)
+	srv.assertRequests(t, 1)
+}
+
+func TestRemoteNotRetryable(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict} {
+		srv := newFlakyServer(t, 5, status, 0)
+
+		_, err := (&RemoteService{}).
+			WithRemote(srv.URL).
+			WithRetry(5, time.Millisecond, 10*time.Millisecond).
+			Call()
+		assertStatusError(t, err, status)
+		srv.assertRequests(t, 1)
+	}
+}
+
+func TestRemoteTimeout(t *testing.T) {
+	srv := newFlakyServer(t, 0, http.StatusOK, time.Second)
+
+	_, err := (&RemoteService{}).
+		WithRemote(srv.URL).
+		WithTimeout(20*time.Millisecond).
+		WithRetry(2, time.Millisecond, 10*time.Millisecond).
+		Call()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	srv.assertRequests(t, 2)
+}
+
+func TestRemoteContextStopsRetries(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		ctx  func() (context.Context, context.CancelFunc)
+		want error
+	}{
+		{
+			name: "deadline",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+			want: context.DeadlineExceeded,
+		},
+		{
+			name: "cancel",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(50*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			want: context.Canceled,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			srv := newFlakyServer(t, 100, http.StatusServiceUnavailable, 0)
+			ctx, cancel := test.ctx()
+			defer cancel()
+
+			start := time.Now()
+			_, err := (&RemoteService{}).
+				WithRemote(srv.URL).
+				WithRetry(10, 2*time.Second, 5*time.Second).
+				CallContext(ctx)
+			if !errors.Is(err, test.want) {
+				t.Errorf("err = %v, want %v", err, test.want)
+			}
+
+			// The first retry waits at least a second, so it must not have happened
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("gave up after %v", elapsed)
+			}
+			srv.assertRequests(t, 1)
+		})
+	}
+}
+
+func TestRemoteRetryWrites(t *testing.T) {
+	for _, method := range []string{http.MethodPost, http.MethodPut} {
+		// A write is not retried, as a failed attempt may still have been applied
+		srv := newFlakyServer(t, 2, http.StatusServiceUnavailable, 0)
+		_, err := (&RemoteService{}).
+			WithMethod(method).
+			WithRemote(srv.URL).
+			WithRetry(3, time.Millisecond, 10*time.Millisecond).
+			Call()
+		assertStatusError(t, err, http.StatusServiceUnavailable)
+		srv.assertRequests(t, 1)
+
+		// Unless the caller opts in
+		srv = newFlakyServer(t, 2, http.StatusServiceUnavailable, 0)
+		if _, err := (&RemoteService{}).
+			WithMethod(method).
+			WithRemote(srv.URL).
+			WithRetry(3, time.Millisecond, 10*time.Millisecond).
+			WithRetryWrites(true).
+			Call(); err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		srv.assertRequests(t, 3)
+	}
+}
@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+)
+
+// Product is the data returned by a Service
+type Product struct {
+	Name  string `json:"name"`
+	Price string `json:"price"`
+}
+
+// Service is an interface describing a Service that returns Products.
+type Service interface {
+	// Call returns Products
+	Call() ([]Product, error)
+
+	// CallContext returns Products, giving up when the context is done
+	CallContext(ctx context.Context) ([]Product, error)
+}
+
+// LocalService is a service that runs locally, no tnet connection reqiured
+type LocalService struct {
+	products []Product
+}
+
+// WithProducts sets the products to return on future invocations of Call()
+func (ls *LocalService) WithProducts(products ...Product) {
+	ls.products = products
+}
+
+// configure ensures the service has default products if none were provided
+func (ls *LocalService) configure() {
+	if len(ls.products) == 0 {
+		ls.products = []Product{
+			{Name: "Oranges", Price: "2.50/lb"},
+			{Name: "Apples", Price: "5.00/lb"},
+		}
+	}
+}
+
+// Call returns Products
+func (ls LocalService) Call() ([]Product, error) {
+	return ls.CallContext(context.Background())
+}
+
+// CallContext returns Products, unless the context is already done
+func (ls LocalService) CallContext(ctx context.Context) ([]Product, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Ensure we use a configured service
+	(&ls).configure()
+
+	return ls.products, nil
+}
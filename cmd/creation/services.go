@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ErrNoSuchService is the error returned when there is no service with the requested name
+var ErrNoSuchService = fmt.Errorf("No such service")
+
+// serviceOverride is a service that replaces a configured service until it is restored
+type serviceOverride struct {
+	id  int
+	svc Service
+}
+
+// Services is a registry of configured services, looked up by name.
+// The services are loaded from the factory the first time they are needed.
+// Services can be overridden, eg by a test that replaces a RemoteService with a LocalService.
+//
+// It is safe for concurrent use.
+type Services struct {
+	factory   *ServiceFactory
+	localOnly bool
+	loadOnce  *sync.Once
+	loadErr   *error
+
+	mu        sync.Mutex
+	overrides map[string][]serviceOverride
+	nextID    int
+	rebuilt   map[string]Service
+	version   int
+}
+
+// NewServices constructs Services that loads services from a factory
+func NewServices(factory *ServiceFactory) *Services {
+	var loadErr error
+
+	return &Services{
+		factory:   factory,
+		loadOnce:  &sync.Once{},
+		loadErr:   &loadErr,
+		overrides: map[string][]serviceOverride{},
+	}
+}
+
+// The singleton Services, and the Once that creates it
+var (
+	theServices     *Services
+	theServicesOnce sync.Once
+)
+
+// DefaultServices returns the singleton Services, which loads services from the default ServiceFactory
+func DefaultServices() *Services {
+	theServicesOnce.Do(func() {
+		theServices = NewServices(&ServiceFactory{})
+	})
+
+	return theServices
+}
+
+// LocalOnly returns Services with the same services, where every RemoteService is replaced by a LocalService with
+// default products, even inside composite services, so that nothing connects to a server. Overrides are not shared.
+func (s *Services) LocalOnly() *Services {
+	return &Services{
+		factory:   s.factory,
+		localOnly: true,
+		loadOnce:  s.loadOnce,
+		loadErr:   s.loadErr,
+		overrides: map[string][]serviceOverride{},
+	}
+}
+
+// load loads the services from the factory, the first time it is called
+func (s *Services) load() error {
+	s.loadOnce.Do(func() {
+		*s.loadErr = s.factory.Load()
+	})
+
+	return *s.loadErr
+}
+
+// Get returns the service with the given name: the most recent override if there is one, otherwise the configured
+// service. Composite services that refer to an overridden service, directly or indirectly, use the override.
+func (s *Services) Get(name string) (Service, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		svc  Service
+		have bool
+	)
+
+	if (len(s.overrides) == 0) && !s.localOnly {
+		svc, have = s.factory.Service(name)
+	} else {
+		svc, have = s.rebuiltServices()[name]
+	}
+
+	if !have {
+		return nil, fmt.Errorf("%w: %q", ErrNoSuchService, name)
+	}
+
+	return svc, nil
+}
+
+// rebuiltServices returns the services, where composite services are rebuilt to use the overrides and local
+// services. They are rebuilt once, and kept until the overrides or the configuration change, so that a rebuilt
+// CachingService keeps its cache. Must be called with the mutex held.
+func (s *Services) rebuiltServices() map[string]Service {
+	if (s.rebuilt != nil) && (s.version == s.factory.configVersion()) {
+		return s.rebuilt
+	}
+
+	replace := map[string]Service{}
+	for overridden, overrides := range s.overrides {
+		replace[overridden] = overrides[len(overrides)-1].svc
+	}
+
+	var local func(Service) (Service, bool)
+	if s.localOnly {
+		local = localize
+	}
+
+	s.rebuilt, s.version = s.factory.servicesWith(replace, local)
+
+	// A service that is not configured can be overridden too
+	for name, svc := range replace {
+		s.rebuilt[name] = svc
+	}
+
+	return s.rebuilt
+}
+
+// localize returns a LocalService with default products that replaces a RemoteService or CachingService, including
+// one that is monitored, and false for other services.
+func localize(svc Service) (Service, bool) {
+	switch s := svc.(type) {
+	case RemoteService, *CachingService:
+		return LocalService{}, true
+
+	case *MonitoredService:
+		return localize(s.svc)
+
+	default:
+		return nil, false
+	}
+}
+
+// Names returns the names of the configured services, sorted
+func (s *Services) Names() ([]string, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	return s.factory.Names(), nil
+}
+
+// Override replaces a service with another until the returned function is called, which restores the service.
+// Overrides can be nested, and restored in any order: the most recent override that has not been restored is used.
+// Composite services that refer to the service use the override too.
+// A test typically defers the restore function.
+func (s *Services) Override(name string, svc Service) (restore func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextID
+	s.nextID++
+	s.overrides[name] = append(s.overrides[name], serviceOverride{id: id, svc: svc})
+	s.rebuilt = nil
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		overrides := s.overrides[name]
+		for i, o := range overrides {
+			if o.id == id {
+				s.overrides[name] = append(overrides[:i:i], overrides[i+1:]...)
+				break
+			}
+		}
+
+		if len(s.overrides[name]) == 0 {
+			delete(s.overrides, name)
+		}
+		s.rebuilt = nil
+	}
+}
@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+// newTestServices returns Services loaded from a config where composites refer to a remote service, directly and
+// indirectly, and a counter of the requests the remote service receives
+func newTestServices(t *testing.T) (*Services, *int32) {
+	t.Helper()
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		json.NewEncoder(w).Encode([]Product{{Name: "Tangerines", Price: "3.25/lb"}})
+	}))
+	t.Cleanup(srv.Close)
+
+	configs := fmt.Sprintf(`[
+    {"name": "remote", "url": %q},
+    {"name": "bananas", "products": [{"name": "Bananas", "price": "1.23/lb"}]},
+    {"name": "merged", "merge": ["remote", "bananas"]},
+    {"name": "cached", "cache": "remote"},
+    {"name": "fallback", "fallback": ["cached", "bananas"]}
+]`, srv.URL)
+	filename := filepath.Join(t.TempDir(), "services.json")
+	if err := ioutil.WriteFile(filename, []byte(configs), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	factory := (&ServiceFactory{}).WithFilename(filename).WithEnviron(func() []string { return nil })
+	return NewServices(factory), &requests
+}
+
+// assertProducts fails if the named service does not return the expected products
+func assertProducts(t *testing.T, svcs *Services, name string, want ...Product) {
+	t.Helper()
+
+	svc, err := svcs.Get(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	products, err := svc.Call()
+	if err != nil {
+		t.Fatalf("%s: %s", name, err)
+	}
+
+	if !reflect.DeepEqual(products, want) {
+		t.Errorf("%s: products %+v, want %+v", name, products, want)
+	}
+}
+
+func TestServicesOverrideComposites(t *testing.T) {
+	svcs, requests := newTestServices(t)
+
+	var (
+		tangerines = Product{Name: "Tangerines", Price: "3.25/lb"}
+		bananas    = Product{Name: "Bananas", Price: "1.23/lb"}
+		limes      = Product{Name: "Limes", Price: "0.40/ea"}
+	)
+
+	restore := svcs.Override("remote", LocalService{products: []Product{limes}})
+	assertProducts(t, svcs, "remote", limes)
+	assertProducts(t, svcs, "merged", limes, bananas)
+	assertProducts(t, svcs, "cached", limes)
+	assertProducts(t, svcs, "fallback", limes)
+	assertProducts(t, svcs, "bananas", bananas)
+	if n := atomic.LoadInt32(requests); n != 0 {
+		t.Errorf("overridden remote service received %d requests", n)
+	}
+
+	// Overriding a composite wins over overriding what it refers to
+	restoreMerged := svcs.Override("merged", LocalService{products: []Product{bananas}})
+	assertProducts(t, svcs, "merged", bananas)
+	restoreMerged()
+	assertProducts(t, svcs, "merged", limes, bananas)
+
+	restore()
+	assertProducts(t, svcs, "merged", tangerines, bananas)
+	assertProducts(t, svcs, "fallback", tangerines)
+	if n := atomic.LoadInt32(requests); n != 2 {
+		t.Errorf("restored remote service received %d requests, want 2", n)
+	}
+}
+
+func TestServicesLocalOnly(t *testing.T) {
+	svcs, requests := newTestServices(t)
+
+	var (
+		local   = LocalService{}
+		bananas = Product{Name: "Bananas", Price: "1.23/lb"}
+		limes   = Product{Name: "Limes", Price: "0.40/ea"}
+	)
+	defaults, _ := local.Call()
+
+	localOnly := svcs.LocalOnly()
+	assertProducts(t, localOnly, "remote", defaults...)
+	assertProducts(t, localOnly, "merged", append(append([]Product(nil), defaults...), bananas)...)
+	assertProducts(t, localOnly, "fallback", defaults...)
+
+	// An override of a local only service is used as is
+	restore := localOnly.Override("remote", LocalService{products: []Product{limes}})
+	assertProducts(t, localOnly, "merged", limes, bananas)
+	restore()
+
+	if n := atomic.LoadInt32(requests); n != 0 {
+		t.Errorf("local only services sent %d requests", n)
+	}
+}
+
+func TestServicesRebuiltOnce(t *testing.T) {
+	var (
+		svcs, requests = newTestServices(t)
+		tangerines     = Product{Name: "Tangerines", Price: "3.25/lb"}
+	)
+
+	// Overriding the remote service, even with itself, rebuilds the cached service, which still caches
+	remote, _ := svcs.Get("remote")
+	restore := svcs.Override("remote", remote)
+	defer restore()
+
+	cached, _ := svcs.Get("cached")
+	for i := 0; i < 3; i++ {
+		assertProducts(t, svcs, "cached", tangerines)
+	}
+
+	// A fallback to the cached service uses the same cache
+	assertProducts(t, svcs, "fallback", tangerines)
+	if n := atomic.LoadInt32(requests); n != 1 {
+		t.Errorf("cached service sent %d requests, want 1", n)
+	}
+
+	// A change to the overrides rebuilds the services
+	svcs.Override("bananas", LocalService{})()
+	if svc, _ := svcs.Get("cached"); svc == cached {
+		t.Error("services were not rebuilt when the overrides changed")
+	}
+}
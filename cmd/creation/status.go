@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ServiceStatus is the status of one service.
+// Health and probe fields are only present for services with a health probe, circuit fields for services with a
+// circuit breaker, and call statistics for services with either.
+type ServiceStatus struct {
+	Name                string     `json:"name"`
+	Kind                string     `json:"kind"`
+	Health              string     `json:"health,omitempty"`
+	LastProbe           *time.Time `json:"lastProbe,omitempty"`
+	Circuit             string     `json:"circuit,omitempty"`
+	ConsecutiveFailures int        `json:"consecutiveFailures,omitempty"`
+	Calls               int        `json:"calls,omitempty"`
+	Errors              int        `json:"errors,omitempty"`
+	LastError           string     `json:"lastError,omitempty"`
+}
+
+// StatusReport is the status of all services.
+// Healthy is false if any service is down or has an open circuit.
+type StatusReport struct {
+	Time     time.Time       `json:"time"`
+	Healthy  bool            `json:"healthy"`
+	Services []ServiceStatus `json:"services"`
+}
+
+// status returns the status of a monitored service
+func (ms *MonitoredService) status(name, kind string) ServiceStatus {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	status := ServiceStatus{
+		Name:      name,
+		Kind:      kind,
+		Calls:     ms.calls,
+		Errors:    ms.errors,
+		LastError: ms.lastError,
+	}
+
+	if ms.probe != nil {
+		status.Health = ms.health.String()
+		if !ms.lastProbe.IsZero() {
+			lastProbe := ms.lastProbe
+			status.LastProbe = &lastProbe
+		}
+	}
+
+	if ms.breaker != nil {
+		status.Circuit = ms.breaker.State().String()
+		status.ConsecutiveFailures = ms.breaker.ConsecutiveFailures()
+	}
+
+	return status
+}
+
+// Status returns the status of all loaded services, sorted by name.
+// The services are those of one configuration, even if it is reloaded at the same time.
+func (f *ServiceFactory) Status() StatusReport {
+	f.mu.RLock()
+	var (
+		report   = StatusReport{Time: f.clock()(), Healthy: true, Services: make([]ServiceStatus, 0, len(f.services))}
+		services = make(map[string]Service, len(f.services))
+	)
+
+	for name, svc := range f.services {
+		services[name] = svc
+		report.Services = append(report.Services, ServiceStatus{Name: name, Kind: f.configs[name].kind()})
+	}
+	f.mu.RUnlock()
+
+	sort.Slice(report.Services, func(i, j int) bool { return report.Services[i].Name < report.Services[j].Name })
+
+	for i, status := range report.Services {
+		if ms, isa := services[status.Name].(*MonitoredService); isa {
+			status = ms.status(status.Name, status.Kind)
+			report.Services[i] = status
+		}
+
+		if (status.Health == Down.String()) || (status.Circuit == Open.String()) {
+			report.Healthy = false
+		}
+	}
+
+	return report
+}
+
+// StatusHandler returns an http.Handler that serves the status of all services as JSON, for monitoring.
+// The status code is 503 Service Unavailable if any service is unhealthy.
+func (f *ServiceFactory) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := f.Status()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if !report.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		json.NewEncoder(w).Encode(report)
+	})
+}
+
+// CheckHealth runs the health probes that are due, at the same time, and waits for them to finish
+func (f *ServiceFactory) CheckHealth(ctx context.Context) {
+	var monitored []*MonitoredService
+	f.mu.RLock()
+	for _, svc := range f.services {
+		if ms, isa := svc.(*MonitoredService); isa && ms.probeDue() {
+			monitored = append(monitored, ms)
+		}
+	}
+	f.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, ms := range monitored {
+		wg.Add(1)
+		go func(ms *MonitoredService) {
+			defer wg.Done()
+			ms.Probe(ctx)
+		}(ms)
+	}
+	wg.Wait()
+}
+
+// WatchHealth runs the health probes that are due every tick in a new goroutine, until the context is done.
+// The tick should be no longer than the shortest probe interval.
+func (f *ServiceFactory) WatchHealth(ctx context.Context, tick time.Duration) {
+	go func() {
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+
+		for {
+			f.CheckHealth(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
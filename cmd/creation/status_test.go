@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestStatusDuringReload(t *testing.T) {
+	var (
+		filename = filepath.Join(t.TempDir(), "services.json")
+		configs  = []string{
+			`[{"name": "apples"}, {"name": "bananas"}]`,
+			`[{"name": "cherries"}, {"name": "dates"}, {"name": "elderberries"}]`,
+		}
+		names = [][]string{
+			{"apples", "bananas"},
+			{"cherries", "dates", "elderberries"},
+		}
+	)
+
+	if err := ioutil.WriteFile(filename, []byte(configs[0]), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f := (&ServiceFactory{}).WithFilename(filename).WithEnviron(func() []string { return nil })
+	if err := f.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		done = make(chan struct{})
+	)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			ioutil.WriteFile(filename, []byte(configs[i%2]), 0644)
+			f.Load()
+		}
+	}()
+
+	// Every report is of one configuration or the other, never a mixture
+	for i := 0; i < 200; i++ {
+		report := f.Status()
+
+		var got []string
+		for _, status := range report.Services {
+			if status.Kind != localKind {
+				t.Errorf("%s: kind %q, want %q", status.Name, status.Kind, localKind)
+			}
+			got = append(got, status.Name)
+		}
+
+		if !reflect.DeepEqual(got, names[0]) && !reflect.DeepEqual(got, names[1]) {
+			t.Fatalf("status of services %v, want %v or %v", got, names[0], names[1])
+		}
+	}
+
+	close(done)
+	wg.Wait()
+}
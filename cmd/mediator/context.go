@@ -2,15 +2,47 @@
 
 package main
 
+// Protocol is the protocol a client used to send a request
+type Protocol uint
+
+// Protocol constants
+const (
+	FTP Protocol = iota
+	HTTP
+)
+
+var (
+	protocolToString = map[Protocol]string{
+		FTP:  "ftp",
+		HTTP: "http",
+	}
+)
+
+// String is Protocol Stringer
+func (p Protocol) String() string {
+	return protocolToString[p]
+}
+
 // DataContext contains the contextual info for data flowing in either direction:
 // protocol -> mediator -> operations
 // protocol <- mediator <- operations
 //
 // If Data is non-nil, the client is sending data to store.
 // If Data is nil, the client is requesting data to be returned.
+//
+// If Type is BatchType, Batch contains the operations to perform all or nothing, and Data is ignored.
+// The response Batch contains one result per operation, in the same order.
+//
+// Client and Protocol identify who sent the request, for rate limiting and quotas.
+//
+// Err is only set in responses, and is non-nil if the operation failed.
 type DataContext struct {
-	Type   DataType
-	ID     string
-	Format DataFormat
-	Data   []byte
+	Client   string
+	Protocol Protocol
+	Type     DataType
+	ID       string
+	Format   DataFormat
+	Data     []byte
+	Batch    []DataContext
+	Err      error
 }
@@ -11,17 +11,20 @@ type DataType uint
 const (
 	CustomerType DataType = iota
 	InvoiceType
+	BatchType
 )
 
 var (
 	dataTypeToString = map[DataType]string{
 		CustomerType: "customer",
 		InvoiceType:  "invoice",
+		BatchType:    "batch",
 	}
 
 	stringToDataType = map[string]DataType{
 		"customer": CustomerType,
 		"invoice":  InvoiceType,
+		"batch":    BatchType,
 	}
 )
 
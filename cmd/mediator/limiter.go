@@ -0,0 +1,230 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Throttling errors
+var (
+	ErrRateLimited   = fmt.Errorf("Rate limit exceeded, try again later")
+	ErrQuotaExceeded = fmt.Errorf("Daily quota exceeded, try again tomorrow")
+)
+
+// Clock provides the current time, so that tests can control the passage of time
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc is an adapter to allow use of ordinary functions as a Clock
+type ClockFunc func() time.Time
+
+// Now calls c()
+func (c ClockFunc) Now() time.Time {
+	return c()
+}
+
+// Limits are the rate limit and daily quotas for each client of a DataType.
+// A zero Burst means requests are not rate limited, and a zero daily quota is unlimited.
+// A zero Rate with a non-zero Burst never refills the token bucket, so only Burst requests are ever allowed.
+type Limits struct {
+	// Rate is the number of requests per second the token bucket is refilled with
+	Rate float64
+	// Burst is the capacity of the token bucket
+	Burst int
+	// DailyRequests is the number of requests allowed per UTC day
+	DailyRequests int
+	// DailyBytes is the number of bytes sent and received allowed per UTC day
+	DailyBytes int
+}
+
+// clientKey identifies a client for a DataType
+type clientKey struct {
+	client   string
+	protocol Protocol
+	typ      DataType
+}
+
+// usage is the state of a client for a DataType
+type usage struct {
+	tokens     float64
+	refilledAt time.Time
+	day        time.Time
+	requests   int
+	bytes      int
+}
+
+// dataSize is the number of bytes of data in a context, including the data of a batch
+func dataSize(ctx DataContext) int {
+	size := len(ctx.Data)
+	for _, itemCtx := range ctx.Batch {
+		size += dataSize(itemCtx)
+	}
+
+	return size
+}
+
+// Limiter applies rate limits and daily quotas per client, protocol and DataType.
+// DataTypes with no limits are unlimited.
+type Limiter struct {
+	mu     sync.Mutex
+	clock  Clock
+	limits map[DataType]Limits
+	usages map[clientKey]*usage
+}
+
+// NewLimiter constructs a Limiter that uses the system clock
+func NewLimiter() *Limiter {
+	return &Limiter{
+		clock:  ClockFunc(time.Now),
+		limits: map[DataType]Limits{},
+		usages: map[clientKey]*usage{},
+	}
+}
+
+// WithClock overrides the system clock
+func (l *Limiter) WithClock(clock Clock) *Limiter {
+	l.clock = clock
+	return l
+}
+
+// WithLimits sets the limits for a DataType
+func (l *Limiter) WithLimits(typ DataType, limits Limits) *Limiter {
+	l.limits[typ] = limits
+	return l
+}
+
+// usage returns the usage for a key, refilling the token bucket and resetting the quotas for a new day.
+// Must be called with the mutex held.
+func (l *Limiter) usage(key clientKey, limits Limits) *usage {
+	var (
+		now   = l.clock.Now()
+		today = now.UTC().Truncate(24 * time.Hour)
+		u     = l.usages[key]
+	)
+
+	if u == nil {
+		u = &usage{tokens: float64(limits.Burst), refilledAt: now, day: today}
+		l.usages[key] = u
+	}
+
+	if elapsed := now.Sub(u.refilledAt).Seconds(); elapsed > 0 {
+		u.tokens += elapsed * limits.Rate
+		if max := float64(limits.Burst); u.tokens > max {
+			u.tokens = max
+		}
+		u.refilledAt = now
+	}
+
+	if !u.day.Equal(today) {
+		u.day = today
+		u.requests = 0
+		u.bytes = 0
+	}
+
+	return u
+}
+
+// charge is what a context costs the client for one DataType
+type charge struct {
+	key      clientKey
+	limits   Limits
+	usage    *usage
+	requests int
+	bytes    int
+}
+
+// charges returns what a context costs the client for each DataType that has limits, in the order the types first
+// appear. A batch costs one request and all of its data for BatchType, and each operation in it also costs one
+// request and its data for its own type, so that a batch cannot be used to get around the limits of a type.
+// Must be called with the mutex held.
+func (l *Limiter) charges(ctx DataContext) []*charge {
+	var (
+		charges []*charge
+		byType  = map[DataType]*charge{}
+		add     func(DataType, int)
+	)
+
+	add = func(typ DataType, bytes int) {
+		limits, haveLimits := l.limits[typ]
+		if !haveLimits {
+			return
+		}
+
+		c := byType[typ]
+		if c == nil {
+			key := clientKey{client: ctx.Client, protocol: ctx.Protocol, typ: typ}
+			c = &charge{key: key, limits: limits, usage: l.usage(key, limits)}
+			byType[typ] = c
+			charges = append(charges, c)
+		}
+
+		c.requests++
+		c.bytes += bytes
+	}
+
+	add(ctx.Type, dataSize(ctx))
+	if ctx.Type == BatchType {
+		for _, itemCtx := range ctx.Batch {
+			add(itemCtx.Type, dataSize(itemCtx))
+		}
+	}
+
+	return charges
+}
+
+// Allow returns nil if the request in the context is allowed, and counts it against the client limits.
+// A batch is only allowed if every operation in it is also allowed by the limits of its type.
+// Otherwise it returns ErrRateLimited or ErrQuotaExceeded, and nothing is counted.
+func (l *Limiter) Allow(ctx DataContext) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	charges := l.charges(ctx)
+	for _, c := range charges {
+		if ((c.limits.DailyRequests > 0) && (c.usage.requests+c.requests > c.limits.DailyRequests)) ||
+			((c.limits.DailyBytes > 0) && (c.usage.bytes+c.bytes > c.limits.DailyBytes)) {
+			return ErrQuotaExceeded
+		}
+	}
+
+	for _, c := range charges {
+		if (c.limits.Burst > 0) && (c.usage.tokens < float64(c.requests)) {
+			return ErrRateLimited
+		}
+	}
+
+	for _, c := range charges {
+		if c.limits.Burst > 0 {
+			c.usage.tokens -= float64(c.requests)
+		}
+		c.usage.requests += c.requests
+		c.usage.bytes += c.bytes
+	}
+
+	return nil
+}
+
+// Send returns nil if the data of a response fits in the rest of the daily byte quota of the client, and counts it.
+// Otherwise it returns ErrQuotaExceeded, nothing is counted, and the data must not be sent, so that the quota is
+// never exceeded. As for Allow, the data of each operation in a batch also counts for its own type.
+func (l *Limiter) Send(responseCtx DataContext) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	charges := l.charges(responseCtx)
+	for _, c := range charges {
+		if (c.limits.DailyBytes > 0) && (c.usage.bytes+c.bytes > c.limits.DailyBytes) {
+			return ErrQuotaExceeded
+		}
+	}
+
+	for _, c := range charges {
+		c.usage.bytes += c.bytes
+	}
+
+	return nil
+}
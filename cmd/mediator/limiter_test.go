@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when advanced
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestLimiter(limits map[DataType]Limits) (*Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 2, 23, 59, 0, 0, time.UTC)}
+	l := NewLimiter().WithClock(clock)
+	for typ, lim := range limits {
+		l.WithLimits(typ, lim)
+	}
+
+	return l, clock
+}
+
+func customerCtx(client string, data string) DataContext {
+	ctx := DataContext{Client: client, Protocol: HTTP, Type: CustomerType, ID: "1"}
+	if data != "" {
+		ctx.Data = []byte(data)
+	}
+
+	return ctx
+}
+
+func TestLimiterRefill(t *testing.T) {
+	l, clock := newTestLimiter(map[DataType]Limits{CustomerType: {Rate: 2, Burst: 3}})
+	ctx := customerCtx("alice", "")
+
+	for i := 0; i < 3; i++ {
+		if err := l.Allow(ctx); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+
+	if err := l.Allow(ctx); err != ErrRateLimited {
+		t.Fatalf("expected %v, got %v", ErrRateLimited, err)
+	}
+
+	// Other clients and protocols have their own buckets
+	if err := l.Allow(customerCtx("bob", "")); err != nil {
+		t.Fatal(err)
+	}
+	ftpCtx := ctx
+	ftpCtx.Protocol = FTP
+	if err := l.Allow(ftpCtx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 2 tokens a second: 250ms is half a token, 500ms is one
+	clock.now = clock.now.Add(250 * time.Millisecond)
+	if err := l.Allow(ctx); err != ErrRateLimited {
+		t.Fatalf("expected %v, got %v", ErrRateLimited, err)
+	}
+
+	clock.now = clock.now.Add(250 * time.Millisecond)
+	if err := l.Allow(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The bucket never holds more than the burst
+	clock.now = clock.now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if err := l.Allow(ctx); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if err := l.Allow(ctx); err != ErrRateLimited {
+		t.Fatalf("expected %v, got %v", ErrRateLimited, err)
+	}
+}
+
+func TestLimiterDailyReset(t *testing.T) {
+	l, clock := newTestLimiter(map[DataType]Limits{CustomerType: {DailyRequests: 2, DailyBytes: 10}})
+	ctx := customerCtx("alice", "")
+
+	for i := 0; i < 2; i++ {
+		if err := l.Allow(ctx); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if err := l.Allow(ctx); err != ErrQuotaExceeded {
+		t.Fatalf("expected %v, got %v", ErrQuotaExceeded, err)
+	}
+
+	// A new UTC day resets the quotas
+	clock.now = clock.now.Add(time.Minute)
+	if err := l.Allow(customerCtx("alice", "0123456789")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The byte quota is full, so a request with data is refused, and a response with data cannot be sent
+	if err := l.Allow(customerCtx("alice", "x")); err != ErrQuotaExceeded {
+		t.Fatalf("expected %v, got %v", ErrQuotaExceeded, err)
+	}
+	if err := l.Send(customerCtx("alice", "x")); err != ErrQuotaExceeded {
+		t.Fatalf("expected %v, got %v", ErrQuotaExceeded, err)
+	}
+
+	clock.now = clock.now.Add(24 * time.Hour)
+	if err := l.Send(customerCtx("alice", "0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Send(customerCtx("alice", "x")); err != ErrQuotaExceeded {
+		t.Fatalf("expected %v, got %v", ErrQuotaExceeded, err)
+	}
+}
+
+func TestLimiterBatch(t *testing.T) {
+	l, _ := newTestLimiter(map[DataType]Limits{
+		CustomerType: {Rate: 1, Burst: 2, DailyBytes: 8},
+		BatchType:    {Rate: 1, Burst: 10},
+	})
+
+	batch := func(items ...DataContext) DataContext {
+		return DataContext{Client: "alice", Protocol: HTTP, Type: BatchType, Batch: items}
+	}
+
+	// Each customer operation in the batch costs a customer token
+	if err := l.Allow(batch(customerCtx("alice", "ab"), customerCtx("alice", "cd"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Allow(customerCtx("alice", "")); err != ErrRateLimited {
+		t.Fatalf("expected %v, got %v", ErrRateLimited, err)
+	}
+
+	// A batch with any operation over its limit is refused as a whole, and nothing is counted
+	invoiceCtx := DataContext{Client: "alice", Protocol: HTTP, Type: InvoiceType, ID: "1"}
+	if err := l.Allow(batch(invoiceCtx, customerCtx("alice", ""))); err != ErrRateLimited {
+		t.Fatalf("expected %v, got %v", ErrRateLimited, err)
+	}
+	if tokens := l.usages[clientKey{client: "alice", protocol: HTTP, typ: BatchType}].tokens; tokens != 9 {
+		t.Fatalf("expected 9 batch tokens, got %v", tokens)
+	}
+
+	// The data of each operation counts against the byte quota of its type
+	l2, _ := newTestLimiter(map[DataType]Limits{CustomerType: {DailyBytes: 8}})
+	if err := l2.Allow(batch(customerCtx("alice", "abcd"), customerCtx("alice", "efghi"))); err != ErrQuotaExceeded {
+		t.Fatalf("expected %v, got %v", ErrQuotaExceeded, err)
+	}
+	if err := l2.Allow(batch(customerCtx("alice", "abcd"), customerCtx("alice", "efgh"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := l2.Send(batch(customerCtx("alice", "x"))); err != ErrQuotaExceeded {
+		t.Fatalf("expected %v, got %v", ErrQuotaExceeded, err)
+	}
+}
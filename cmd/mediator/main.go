@@ -20,7 +20,7 @@ func main() {
 	}
 
 	buf := Marshal(cust, GOB)
-	ftpTraffic.Request("/customer/1.gob", buf)
+	ftpTraffic.Request("acme", "/customer/1.gob", buf)
 
 	invoice := Invoice{
 		Number:     "A14",
@@ -37,8 +37,61 @@ func main() {
 	}
 
 	buf = Marshal(invoice, JSON)
-	httpTraffic.Request("/invoice/A14", JSON, buf)
+	httpTraffic.Request("acme", "/invoice/A14", JSON, buf)
 
-	ftpTraffic.Request("/customer/1.json", nil)
-	httpTraffic.Request("/invoice/1", GOB, nil)
+	ftpTraffic.Request("acme", "/customer/1.json", nil)
+	httpTraffic.Request("acme", "/invoice/1", GOB, nil)
+
+	// Another client uploads a customer and their invoice together.
+	// Each operation counts against the limits of its type, as well as the batch limits.
+	cust2 := Customer{
+		ID:        2,
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Address: Address{
+			Line:     "456 Sesame St",
+			City:     "New York",
+			Region:   "New York",
+			MailCode: "12345",
+		},
+	}
+
+	invoice2 := Invoice{
+		Number:     "A15",
+		CustomerID: 2,
+		Date:       time.Now(),
+		Lines: []Line{
+			Line{
+				Product:  "Pears",
+				Price:    "2.00",
+				Qty:      2,
+				Extended: "4.00",
+			},
+		},
+	}
+
+	httpTraffic.RequestBatch("globex", JSON, []HTTPBatchItem{
+		{Path: "/customer/2", Data: Marshal(cust2, JSON)},
+		{Path: "/invoice/A15", Data: Marshal(invoice2, JSON)},
+		{Path: "/invoice/2"},
+	})
+
+	// A batch with a malformed invoice is rolled back, so customer 3 does not exist afterwards
+	cust3 := cust2
+	cust3.ID = 3
+	cust3.FirstName = "Jim"
+
+	httpTraffic.RequestBatch("globex", JSON, []HTTPBatchItem{
+		{Path: "/customer/3", Data: Marshal(cust3, JSON)},
+		{Path: "/invoice/A16", Data: []byte(`{"Number": 16}`)},
+		{Path: "/invoice/3"},
+	})
+
+	httpTraffic.Request("globex", "/customer/3", JSON, nil)
+
+	// A noisy client that downloads too quickly is throttled, without affecting other clients
+	for i := 0; i < 7; i++ {
+		httpTraffic.Request("noisy", "/invoice/1", JSON, nil)
+	}
+	httpTraffic.Request("acme", "/invoice/1", JSON, nil)
 }
@@ -65,7 +65,9 @@ func Unmarshal(data []byte, typ DataFormat, target interface{}) {
 	switch typ {
 	case GOB:
 		buf := bytes.NewReader(data)
-		gob.NewDecoder(buf).Decode(target)
+		if err := gob.NewDecoder(buf).Decode(target); err != nil {
+			panic(err)
+		}
 
 	default:
 		if err := json.Unmarshal(data, target); err != nil {
@@ -7,12 +7,20 @@ import (
 	"strconv"
 )
 
+// Batch errors
+var (
+	ErrNestedBatch  = fmt.Errorf("A batch cannot contain another batch")
+	ErrRolledBack   = fmt.Errorf("Rolled back due to the failure of another operation in the batch")
+	ErrNotPerformed = fmt.Errorf("Not performed due to the failure of an earlier operation in the batch")
+)
+
 // Mediator that handles communication between Receiver/Sender and CustomerOperations/InvoiceOperations
 type Mediator struct {
 	ftpTraffic  *FTPTraffic
 	httpTraffic *HTTPTraffic
 	customerOps *CustomerOperations
 	invoiceOps  *InvoiceOperations
+	limiter     *Limiter
 }
 
 // NewMediator constructs a Mediator
@@ -20,8 +28,37 @@ func NewMediator() *Mediator {
 	return &Mediator{}
 }
 
-// Perform the operation
+// Perform the operation, unless the client has exceeded their rate limit or quota
 func (t *Mediator) Perform(ctx DataContext) DataContext {
+	if t.limiter != nil {
+		if err := t.limiter.Allow(ctx); err != nil {
+			responseCtx := ctx
+			responseCtx.Data = nil
+			responseCtx.Batch = nil
+			responseCtx.Err = err
+
+			return responseCtx
+		}
+	}
+
+	if ctx.Type == BatchType {
+		return t.performBatch(ctx)
+	}
+
+	responseCtx := t.perform(ctx)
+	if t.limiter != nil {
+		// Only a retrieval has data to send, so a failure here never hides a change that was stored
+		if err := t.limiter.Send(responseCtx); err != nil {
+			responseCtx.Data = nil
+			responseCtx.Err = err
+		}
+	}
+
+	return responseCtx
+}
+
+// perform a single operation, panicking if it fails
+func (t *Mediator) perform(ctx DataContext) DataContext {
 	responseCtx := ctx
 
 	switch ctx.Type {
@@ -53,9 +90,89 @@ func (t *Mediator) Perform(ctx DataContext) DataContext {
 			responseCtx.Data = Marshal(invoices, ctx.Format)
 		}
 
+	case BatchType:
+		panic(ErrNestedBatch)
+
 	default:
 		panic(fmt.Errorf("Unknown request type"))
 	}
 
 	return responseCtx
 }
+
+// tryPerform performs a single operation, returning a failure as the Err of the response rather than panicking
+func (t *Mediator) tryPerform(ctx DataContext) (responseCtx DataContext) {
+	defer func() {
+		if r := recover(); r != nil {
+			responseCtx = ctx
+			responseCtx.Data = nil
+
+			if err, isa := r.(error); isa {
+				responseCtx.Err = err
+			} else {
+				responseCtx.Err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+
+	return t.perform(ctx)
+}
+
+// performBatch performs every operation of a batch, all or nothing.
+// The response Batch has one result per operation.
+// If any operation fails, the changes made by the operations before it are rolled back, the operations after it are
+// not performed, and the response Err describes the failure.
+// If the data of the responses would exceed the byte quota of the client, every operation is rolled back, and the
+// response Err is ErrQuotaExceeded, so that a client is never told a batch failed when its changes were stored.
+func (t *Mediator) performBatch(ctx DataContext) DataContext {
+	var (
+		responseCtx = ctx
+		customers   = t.customerOps.snapshot()
+		invoices    = t.invoiceOps.snapshot()
+	)
+
+	responseCtx.Data = nil
+	responseCtx.Batch = make([]DataContext, len(ctx.Batch))
+
+	for i, itemCtx := range ctx.Batch {
+		responseCtx.Batch[i] = t.tryPerform(itemCtx)
+
+		if err := responseCtx.Batch[i].Err; err != nil {
+			// Roll back
+			t.customerOps.restore(customers)
+			t.invoiceOps.restore(invoices)
+
+			for j := range ctx.Batch {
+				switch {
+				case j < i:
+					responseCtx.Batch[j].Data = nil
+					responseCtx.Batch[j].Err = ErrRolledBack
+
+				case j > i:
+					responseCtx.Batch[j] = ctx.Batch[j]
+					responseCtx.Batch[j].Data = nil
+					responseCtx.Batch[j].Err = ErrNotPerformed
+				}
+			}
+
+			responseCtx.Err = fmt.Errorf("Batch operation %d (%s %s) failed: %w", i, itemCtx.Type, itemCtx.ID, err)
+			return responseCtx
+		}
+	}
+
+	if t.limiter != nil {
+		if err := t.limiter.Send(responseCtx); err != nil {
+			t.customerOps.restore(customers)
+			t.invoiceOps.restore(invoices)
+
+			for j := range responseCtx.Batch {
+				responseCtx.Batch[j].Data = nil
+				responseCtx.Batch[j].Err = ErrRolledBack
+			}
+
+			responseCtx.Err = err
+		}
+	}
+
+	return responseCtx
+}
@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// newTestMediator returns a Mediator with empty stores and no limiter
+func newTestMediator() *Mediator {
+	m := NewMediator()
+	m.customerOps = NewCustomerOperations()
+	m.invoiceOps = NewInvoiceOperations()
+
+	return m
+}
+
+// storeCtx returns a context that stores data as JSON
+func storeCtx(typ DataType, data interface{}) DataContext {
+	return DataContext{Client: "alice", Protocol: HTTP, Type: typ, Format: JSON, Data: Marshal(data, JSON)}
+}
+
+func TestBatchCommits(t *testing.T) {
+	var (
+		m       = newTestMediator()
+		cust    = Customer{ID: 1, FirstName: "Avery", Address: Address{City: "Springfield"}}
+		invoice = Invoice{Number: "A-1", CustomerID: 1}
+	)
+
+	responseCtx := m.Perform(DataContext{Type: BatchType, Batch: []DataContext{
+		storeCtx(CustomerType, cust),
+		storeCtx(InvoiceType, invoice),
+	}})
+	if responseCtx.Err != nil {
+		t.Fatal(responseCtx.Err)
+	}
+
+	if got := m.customerOps.GetCustomer(1); !reflect.DeepEqual(got, cust) {
+		t.Errorf("customer = %+v, want %+v", got, cust)
+	}
+
+	if got := m.invoiceOps.GetInvoicesForCustomer(1); len(got) != 1 {
+		t.Errorf("invoices = %+v, want one invoice", got)
+	}
+}
+
+func TestBatchRollsBack(t *testing.T) {
+	var (
+		m        = newTestMediator()
+		original = Customer{ID: 1, FirstName: "Avery", Address: Address{Line: "1 Main St", City: "Springfield"}}
+		invoice  = Invoice{Number: "A-1", CustomerID: 1}
+	)
+	m.customerOps.SetCustomer(original)
+	m.invoiceOps.SetInvoice(invoice)
+
+	var (
+		customers = m.customerOps.snapshot()
+		invoices  = m.invoiceOps.snapshot()
+		moved     = original
+	)
+	moved.Address.City = "Shelbyville"
+
+	// The third operation fails, so the first two are rolled back and the fourth is not performed
+	bad := storeCtx(CustomerType, nil)
+	bad.Data = []byte("{")
+
+	responseCtx := m.Perform(DataContext{Type: BatchType, Batch: []DataContext{
+		storeCtx(CustomerType, moved),
+		storeCtx(InvoiceType, Invoice{Number: "A-2", CustomerID: 1}),
+		bad,
+		storeCtx(CustomerType, Customer{ID: 2, FirstName: "Blair"}),
+	}})
+	if responseCtx.Err == nil {
+		t.Fatal("batch should fail")
+	}
+
+	for i, want := range []error{ErrRolledBack, ErrRolledBack, nil, ErrNotPerformed} {
+		err := responseCtx.Batch[i].Err
+		if ((want == nil) && (err == nil)) || ((want != nil) && !errors.Is(err, want)) {
+			t.Errorf("operation %d: err = %v, want %v", i, err, want)
+		}
+	}
+
+	if !reflect.DeepEqual(m.customerOps.customers, customers) {
+		t.Errorf("customers = %+v, want %+v", m.customerOps.customers, customers)
+	}
+
+	if got := m.customerOps.GetCustomer(1).Address; got != original.Address {
+		t.Errorf("address = %+v, want %+v", got, original.Address)
+	}
+
+	if !reflect.DeepEqual(m.invoiceOps.snapshot(), invoices) {
+		t.Errorf("invoices = %+v, want %+v", m.invoiceOps.snapshot(), invoices)
+	}
+}
+
+func TestBatchNested(t *testing.T) {
+	m := newTestMediator()
+
+	responseCtx := m.Perform(DataContext{Type: BatchType, Batch: []DataContext{
+		storeCtx(CustomerType, Customer{ID: 1}),
+		{Type: BatchType},
+	}})
+	if !errors.Is(responseCtx.Err, ErrNestedBatch) {
+		t.Errorf("err = %v, want %v", responseCtx.Err, ErrNestedBatch)
+	}
+
+	if len(m.customerOps.customers) != 0 {
+		t.Errorf("customers = %+v, want none", m.customerOps.customers)
+	}
+}
+
+func TestBatchQuotaRollsBack(t *testing.T) {
+	var (
+		m    = newTestMediator()
+		cust = Customer{ID: 1, FirstName: "Avery"}
+	)
+	m.customerOps.SetCustomer(cust)
+	m.limiter, _ = newTestLimiter(map[DataType]Limits{CustomerType: {DailyBytes: 150}})
+
+	// The store fits in the quota, but the retrieval would send more data than the rest of it, so the store is
+	// rolled back
+	moved := cust
+	moved.Address.City = "Springfield"
+
+	responseCtx := m.Perform(DataContext{Client: "alice", Protocol: HTTP, Type: BatchType, Batch: []DataContext{
+		storeCtx(CustomerType, moved),
+		{Client: "alice", Protocol: HTTP, Type: CustomerType, ID: "1", Format: JSON},
+	}})
+	if responseCtx.Err != ErrQuotaExceeded {
+		t.Fatalf("err = %v, want %v", responseCtx.Err, ErrQuotaExceeded)
+	}
+
+	for i, itemCtx := range responseCtx.Batch {
+		if (itemCtx.Err != ErrRolledBack) || (itemCtx.Data != nil) {
+			t.Errorf("operation %d: err = %v, data = %q, want %v and no data", i, itemCtx.Err, itemCtx.Data, ErrRolledBack)
+		}
+	}
+
+	if got := m.customerOps.GetCustomer(1); !reflect.DeepEqual(got, cust) {
+		t.Errorf("customer = %+v, want %+v", got, cust)
+	}
+}
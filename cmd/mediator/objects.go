@@ -9,6 +9,10 @@ var (
 	mediator           = NewMediator()
 	customerOperations = NewCustomerOperations()
 	invoiceOperations  = NewInvoiceOperations()
+	limiter            = NewLimiter().
+				WithLimits(CustomerType, Limits{Rate: 1, Burst: 5, DailyRequests: 1000, DailyBytes: 1 << 20}).
+				WithLimits(InvoiceType, Limits{Rate: 0.5, Burst: 5, DailyRequests: 500, DailyBytes: 1 << 20}).
+				WithLimits(BatchType, Limits{Rate: 0.1, Burst: 2, DailyRequests: 100, DailyBytes: 4 << 20})
 )
 
 // Wire them up to refer to each other
@@ -20,4 +24,5 @@ func init() {
 
 	mediator.customerOps = customerOperations
 	mediator.invoiceOps = invoiceOperations
+	mediator.limiter = limiter
 }
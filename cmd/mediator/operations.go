@@ -22,6 +22,21 @@ func (c CustomerOperations) GetCustomer(id int) Customer {
 	return c.customers[id]
 }
 
+// snapshot returns a copy of the current customers, for restore to roll back to
+func (c CustomerOperations) snapshot() map[int]Customer {
+	customers := make(map[int]Customer, len(c.customers))
+	for id, cust := range c.customers {
+		customers[id] = cust
+	}
+
+	return customers
+}
+
+// restore replaces the current customers with a snapshot
+func (c *CustomerOperations) restore(customers map[int]Customer) {
+	c.customers = customers
+}
+
 // InvoiceOperations contains operations on Invoices
 type InvoiceOperations struct {
 	invoices         map[string]Invoice
@@ -46,3 +61,33 @@ func (i *InvoiceOperations) SetInvoice(data Invoice) {
 func (i InvoiceOperations) GetInvoicesForCustomer(id int) []Invoice {
 	return i.invoicesByCustID[id]
 }
+
+// invoiceSnapshot is a copy of invoices to roll back to
+type invoiceSnapshot struct {
+	invoices         map[string]Invoice
+	invoicesByCustID map[int][]Invoice
+}
+
+// snapshot returns a copy of the current invoices, for restore to roll back to
+func (i InvoiceOperations) snapshot() invoiceSnapshot {
+	snap := invoiceSnapshot{
+		invoices:         make(map[string]Invoice, len(i.invoices)),
+		invoicesByCustID: make(map[int][]Invoice, len(i.invoicesByCustID)),
+	}
+
+	for number, invoice := range i.invoices {
+		snap.invoices[number] = invoice
+	}
+
+	for id, invoices := range i.invoicesByCustID {
+		snap.invoicesByCustID[id] = append([]Invoice(nil), invoices...)
+	}
+
+	return snap
+}
+
+// restore replaces the current invoices with a snapshot
+func (i *InvoiceOperations) restore(snap invoiceSnapshot) {
+	i.invoices = snap.invoices
+	i.invoicesByCustID = snap.invoicesByCustID
+}
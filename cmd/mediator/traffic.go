@@ -17,8 +17,8 @@ func NewFTPTraffic() *FTPTraffic {
 	return &FTPTraffic{}
 }
 
-// Request is called by a virtual FTP server when it receives a request to store or retrieve data
-func (f FTPTraffic) Request(path string, data []byte) {
+// Request is called by a virtual FTP server when it receives a request from a client to store or retrieve data
+func (f FTPTraffic) Request(client, path string, data []byte) {
 	typeIDAndFormat := strings.Split(path, ".")
 	typeAndID := strings.Split(typeIDAndFormat[0], "/")
 
@@ -28,8 +28,11 @@ func (f FTPTraffic) Request(path string, data []byte) {
 	format := StringToDataFormat(typeIDAndFormat[1])
 
 	fmt.Printf("Requesting FTP for %s %s: %s = %s\n", typ, id, format, data)
-	responseCtx := f.mediator.Perform(DataContext{Type: typ, ID: id, Format: format, Data: data})
-	if responseCtx.Data == nil {
+	responseCtx := f.mediator.Perform(DataContext{Client: client, Protocol: FTP, Type: typ, ID: id, Format: format, Data: data})
+	if responseCtx.Err != nil {
+		// Failure
+		fmt.Printf("FTP Failed for %s %s: %s\n", typ, id, responseCtx.Err)
+	} else if responseCtx.Data == nil {
 		// Successful upload
 		fmt.Println("FTP Successful Upload to", typ, id)
 	} else {
@@ -48,8 +51,8 @@ func NewHTTPTraffic() *HTTPTraffic {
 	return &HTTPTraffic{}
 }
 
-// Request is called by an HTTP server when it receives a request to store or retrieve data
-func (h HTTPTraffic) Request(path string, format DataFormat, data []byte) {
+// Request is called by an HTTP server when it receives a request from a client to store or retrieve data
+func (h HTTPTraffic) Request(client, path string, format DataFormat, data []byte) {
 	typeAndID := strings.Split(path, "/")
 
 	// Leading / so index 0 is empty string
@@ -57,8 +60,11 @@ func (h HTTPTraffic) Request(path string, format DataFormat, data []byte) {
 	id := typeAndID[2]
 
 	fmt.Printf("Requesting HTTP for %s %s: %s = %s\n", typ, id, format, data)
-	responseCtx := h.mediator.Perform(DataContext{Type: typ, ID: id, Format: format, Data: data})
-	if responseCtx.Data == nil {
+	responseCtx := h.mediator.Perform(DataContext{Client: client, Protocol: HTTP, Type: typ, ID: id, Format: format, Data: data})
+	if responseCtx.Err != nil {
+		// Failure
+		fmt.Printf("HTTP Failed for %s %s: %s\n", typ, id, responseCtx.Err)
+	} else if responseCtx.Data == nil {
 		// Successful upload
 		fmt.Println("HTTP Successful Upload to", typ, id)
 	} else {
@@ -66,3 +72,43 @@ func (h HTTPTraffic) Request(path string, format DataFormat, data []byte) {
 		fmt.Printf("HTTP Successful Download of %s %s: %s = %s\n", typ, id, responseCtx.Format, responseCtx.Data)
 	}
 }
+
+// HTTPBatchItem is one request of a batch of HTTP requests
+type HTTPBatchItem struct {
+	Path string
+	Data []byte
+}
+
+// RequestBatch is called by an HTTP server when it receives a batch of requests from a client to store or retrieve data all or nothing
+func (h HTTPTraffic) RequestBatch(client string, format DataFormat, items []HTTPBatchItem) {
+	ctx := DataContext{Client: client, Protocol: HTTP, Type: BatchType, Format: format}
+	for _, item := range items {
+		typeAndID := strings.Split(item.Path, "/")
+
+		// Leading / so index 0 is empty string
+		ctx.Batch = append(ctx.Batch, DataContext{Client: client, Protocol: HTTP, Type: StringToDataType(typeAndID[1]), ID: typeAndID[2], Format: format, Data: item.Data})
+	}
+
+	fmt.Printf("Requesting HTTP batch of %d operations: %s\n", len(ctx.Batch), format)
+	responseCtx := h.mediator.Perform(ctx)
+	if responseCtx.Batch == nil {
+		fmt.Println("HTTP Failed batch:", responseCtx.Err)
+		return
+	}
+
+	for i, itemCtx := range responseCtx.Batch {
+		if itemCtx.Err != nil {
+			fmt.Printf("  %d: %s %s failed: %s\n", i, itemCtx.Type, itemCtx.ID, itemCtx.Err)
+		} else if itemCtx.Data == nil {
+			fmt.Printf("  %d: %s %s uploaded\n", i, itemCtx.Type, itemCtx.ID)
+		} else {
+			fmt.Printf("  %d: %s %s downloaded: %s = %s\n", i, itemCtx.Type, itemCtx.ID, itemCtx.Format, itemCtx.Data)
+		}
+	}
+
+	if responseCtx.Err != nil {
+		fmt.Println("HTTP Failed batch:", responseCtx.Err)
+	} else {
+		fmt.Println("HTTP Successful batch")
+	}
+}
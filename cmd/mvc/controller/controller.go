@@ -3,51 +3,294 @@
 package controller
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/bantling/gopatterns/cmd/mvc/model"
 	"github.com/bantling/gopatterns/cmd/mvc/view"
 )
 
+//go:generate go run gen_mapping.go
+
+// Route path constants
+const (
+	customersPath   = "/customers"
+	customerPath    = customersPath + "/"
+	newCustomerPath = customerPath + "new"
+)
+
+// Page size constants
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// suffixKey is the request context key of the path suffix that selects the view format
+type suffixKey struct{}
+
 // controller mediates between the model and view
 type controller struct {
-	model *model.Model
-	view  *view.View
+	model    *model.Model
+	view     *view.View
+	mux      *http.ServeMux
+	done     chan struct{}
+	shutdown *sync.Once
 }
 
-// NewController constructs a Controller
-func NewController() *controller {
-	return &controller{
-		model: model.NewModel(),
-		view:  view.NewView(),
+// NewController constructs a Controller of a model and view
+func NewController(m *model.Model, v *view.View) *controller {
+	c := &controller{
+		model:    m,
+		view:     v,
+		mux:      http.NewServeMux(),
+		done:     make(chan struct{}),
+		shutdown: &sync.Once{},
 	}
+
+	c.mux.HandleFunc("/", c.handleRoot)
+	c.mux.HandleFunc(customersPath, c.handleCustomers)
+	c.mux.HandleFunc(customerPath, c.handleCustomer)
+
+	return c
 }
 
 // GetCustomer retrieves a customer and renders the result
 func (c controller) GetCustomer(id int) {
-	fmt.Println("==== SetCustomer(Customer)")
-	c.model.SetCustomer(
-		model.Customer{
-			ID:        1,
-			FirstName: "John",
-			LastName:  "Doe",
-			Address: model.Address{
-				Line:     "123 Sesame St",
-				City:     "New York",
-				Region:   "New York",
-				Country:  "USA",
-				MailCode: "12345",
+	fmt.Printf("==== GetCustomer(%d)\n", id)
+	mcust, _ := c.model.GetCustomer(id)
+	if err := c.view.RenderCustomer(os.Stdout, toViewCustomer(mcust)); err != nil {
+		panic(err)
+	}
+}
+
+// Shutdown ends all event streams, which would otherwise never complete.
+// It is intended to be registered with http.Server.RegisterOnShutdown.
+func (c controller) Shutdown() {
+	c.shutdown.Do(func() { close(c.done) })
+}
+
+// ServeHTTP is http.Handler for the controller.
+// If the last path element has a suffix the view has a format for (eg /customers/1.json), the suffix is removed from
+// the path before routing, and selects the format instead of the Accept header.
+func (c controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if i := strings.LastIndexByte(r.URL.Path, '.'); i > strings.LastIndexByte(r.URL.Path, '/') {
+		if suffix := r.URL.Path[i+1:]; c.view.HasSuffix(suffix) {
+			u := *r.URL
+			u.Path, u.RawPath = u.Path[:i], ""
+
+			r = r.WithContext(context.WithValue(r.Context(), suffixKey{}, suffix))
+			r.URL = &u
+		}
+	}
+
+	c.mux.ServeHTTP(w, r)
+}
+
+// handleRoot redirects to the list of customers
+func (c controller) handleRoot(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.Redirect(w, r, customersPath, http.StatusFound)
+}
+
+// handleCustomers renders all customers, or creates a customer
+func (c controller) handleCustomers(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		c.saveCustomer(w, r, 0)
+		return
+	}
+
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	var (
+		params = r.URL.Query()
+		list   = view.CustomerList{
+			Search: view.CustomerSearch{
+				Name:     params.Get("name"),
+				City:     params.Get("city"),
+				Region:   params.Get("region"),
+				MailCode: params.Get("mailCode"),
+				Sort:     params.Get("sort"),
+				Desc:     params.Get("desc") != "",
+				Size:     intParam(params.Get("size"), defaultPageSize),
 			},
-		},
+			Lang: language(r),
+			Page: intParam(params.Get("page"), 1),
+			Path: r.URL.Path,
+		}
 	)
 
-	fmt.Printf("==== GetCustomer(%d)\n", id)
-	mcust := c.model.GetCustomer(id)
-	vcust := view.Customer{
-		ID:        mcust.ID,
-		FirstName: mcust.FirstName,
-		LastName:  mcust.LastName,
-		Address:   view.Address(mcust.Address),
-	}
-	c.view.RenderCustomer(vcust)
+	if list.Search.Size > maxPageSize {
+		list.Search.Size = maxPageSize
+	}
+
+	result, err := c.model.Query(model.Query{
+		Name:     list.Search.Name,
+		City:     list.Search.City,
+		Region:   list.Search.Region,
+		MailCode: list.Search.MailCode,
+		Sort:     list.Search.Sort,
+		Desc:     list.Search.Desc,
+		Offset:   (list.Page - 1) * list.Search.Size,
+		Limit:    list.Search.Size,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	list.Total = result.Total
+	list.Pages = (result.Total + list.Search.Size - 1) / list.Search.Size
+	for _, mcust := range result.Customers {
+		list.Customers = append(list.Customers, toViewCustomer(mcust))
+	}
+
+	c.render(w, r, http.StatusOK, func(rdr view.Renderer, buf *bytes.Buffer) error { return rdr.RenderCustomers(buf, list) })
+}
+
+// intParam returns a query parameter as a positive int, or the default if it is missing or invalid
+func intParam(param string, def int) int {
+	if value, err := strconv.Atoi(param); (err == nil) && (value > 0) {
+		return value
+	}
+
+	return def
+}
+
+// handleCustomer renders a form for the customer whose id is the last path element, or updates the customer.
+// If the last path element is "new", renders an empty form to create a customer.
+// If the last path element is "events", streams changes to the customer whose id is the previous path element.
+func (c controller) handleCustomer(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == newCustomerPath {
+		if allowMethods(w, r) {
+			c.renderForm(w, r, http.StatusOK, view.CustomerForm{Action: customersPath})
+		}
+		return
+	}
+
+	idPath := strings.TrimPrefix(r.URL.Path, customerPath)
+	events := strings.HasSuffix(idPath, eventsPathSuffix)
+	idPath = strings.TrimSuffix(idPath, eventsPathSuffix)
+
+	id, err := strconv.Atoi(idPath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	mcust, exists := c.model.GetCustomer(id)
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+
+	if events {
+		c.streamCustomer(w, r, id)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		c.saveCustomer(w, r, id)
+		return
+	}
+
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	c.renderForm(w, r, http.StatusOK, view.CustomerForm{
+		Customer:  toViewCustomer(mcust),
+		Action:    r.URL.Path,
+		Saved:     r.URL.Query().Get("saved") != "",
+		EventsURL: c.eventsURL(id),
+	})
+}
+
+// saveCustomer validates a submitted customer form, and stores the customer with the given id (0 to create it).
+// If the form is invalid, it is rendered again with the errors, otherwise the client is redirected to the customer.
+func (c controller) saveCustomer(w http.ResponseWriter, r *http.Request, id int) {
+	if !validCSRF(r) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	vcust, errs := customerFromForm(r, language(r))
+	vcust.ID = id
+	if len(errs) > 0 {
+		c.renderForm(w, r, http.StatusUnprocessableEntity, view.CustomerForm{Customer: vcust, Action: r.URL.Path, Errors: errs})
+		return
+	}
+
+	id = c.model.SetCustomer(toModelCustomer(vcust))
+
+	redirect := fmt.Sprintf("%s%d", customerPath, id)
+	if suffix, haveSuffix := r.Context().Value(suffixKey{}).(string); haveSuffix {
+		redirect += "." + suffix
+	}
+	http.Redirect(w, r, redirect+"?saved=1", http.StatusSeeOther)
+}
+
+// renderForm renders a customer form with a CSRF token
+func (c controller) renderForm(w http.ResponseWriter, r *http.Request, status int, form view.CustomerForm) {
+	form.CSRFToken = csrfToken(w, r)
+	form.Lang = language(r)
+	c.render(w, r, status, func(rdr view.Renderer, buf *bytes.Buffer) error { return rdr.RenderCustomer(buf, form) })
+}
+
+// allowMethods returns true if the request is a GET, HEAD, or one of the given methods.
+// Otherwise it responds with 405 Method Not Allowed.
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	allowed := append([]string{http.MethodGet, http.MethodHead}, methods...)
+	for _, method := range allowed {
+		if r.Method == method {
+			return true
+		}
+	}
+
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	return false
+}
+
+// language returns the language negotiated from the Accept-Language header
+func language(r *http.Request) string {
+	return view.NegotiateLanguage(r.Header.Get("Accept-Language"))
+}
+
+// render renders with the Renderer negotiated from the path suffix or Accept header, in the language negotiated from
+// the Accept-Language header.
+// If no Renderer is acceptable, responds with 406 Not Acceptable.
+// Renders into a buffer first, so that a failure can be reported as 500 Internal Server Error.
+func (c controller) render(w http.ResponseWriter, r *http.Request, status int, fn func(view.Renderer, *bytes.Buffer) error) {
+	suffix, _ := r.Context().Value(suffixKey{}).(string)
+	w.Header().Add("Vary", "Accept")
+	w.Header().Add("Vary", "Accept-Language")
+
+	rdr, acceptable := c.view.Negotiate(suffix, r.Header.Get("Accept"))
+	if !acceptable {
+		http.Error(w, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := fn(rdr, &buf); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", rdr.ContentType())
+	w.Header().Set("Content-Language", language(r))
+	w.WriteHeader(status)
+	buf.WriteTo(w)
 }
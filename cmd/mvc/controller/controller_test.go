@@ -0,0 +1,272 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/bantling/gopatterns/cmd/mvc/model"
+	"github.com/bantling/gopatterns/cmd/mvc/view"
+)
+
+// testCSRFToken is a well formed CSRF token
+var testCSRFToken = strings.Repeat("ab", csrfTokenBytes)
+
+// newTestController returns a controller with two customers
+func newTestController() *controller {
+	m := model.NewModel()
+	for _, cust := range []model.Customer{
+		{ID: 1, FirstName: "John", LastName: "Doe", Address: model.Address{Line: "123 Sesame St", City: "New York", Region: "New York", Country: "USA", MailCode: "12345"}},
+		{ID: 2, FirstName: "Jane", LastName: "Roe", Address: model.Address{Line: "1 King St", City: "Toronto", Region: "Ontario", Country: "Canada", MailCode: "M5H 1A1"}},
+	} {
+		m.SetCustomer(cust)
+	}
+
+	return NewController(m, view.NewView())
+}
+
+// serve sends a request to the controller and returns the recorded response
+func serve(c *controller, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, r)
+	return w
+}
+
+// assertStatus fails the test if the response status is not the expected status
+func assertStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("expected status %d, got %d: %s", status, w.Code, w.Body)
+	}
+}
+
+// assertHeader fails the test if a response header does not have the expected value
+func assertHeader(t *testing.T, w *httptest.ResponseRecorder, name, value string) {
+	t.Helper()
+	if got := w.Header().Get(name); got != value {
+		t.Fatalf("expected %s %q, got %q", name, value, got)
+	}
+}
+
+// customerForm returns a valid customer form submission with a CSRF token
+func customerForm(token string) url.Values {
+	form := url.Values{
+		"firstName": {"Ann"},
+		"lastName":  {"Ahmed"},
+		"line":      {"9 Oak Ave"},
+		"city":      {"Seattle"},
+		"region":    {"Washington"},
+		"country":   {"USA"},
+		"mailCode":  {"98101"},
+	}
+	if token != "" {
+		form.Set(csrfFieldName, token)
+	}
+
+	return form
+}
+
+// postForm returns a form POST request with a CSRF cookie, if the cookie is not empty
+func postForm(path string, form url.Values, cookie string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if cookie != "" {
+		r.AddCookie(&http.Cookie{Name: csrfCookieName, Value: cookie})
+	}
+
+	return r
+}
+
+func TestRoot(t *testing.T) {
+	w := serve(newTestController(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assertStatus(t, w, http.StatusFound)
+	assertHeader(t, w, "Location", customersPath)
+
+	w = serve(newTestController(), httptest.NewRequest(http.MethodGet, "/nothing", nil))
+	assertStatus(t, w, http.StatusNotFound)
+}
+
+func TestList(t *testing.T) {
+	c := newTestController()
+
+	w := serve(c, httptest.NewRequest(http.MethodGet, customersPath, nil))
+	assertStatus(t, w, http.StatusOK)
+	assertHeader(t, w, "Content-Type", "text/html; charset=utf-8")
+	for _, name := range []string{"John", "Jane"} {
+		if !strings.Contains(w.Body.String(), name) {
+			t.Errorf("expected %q in the list:\n%s", name, w.Body)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, customersPath+"?sort=firstName", nil)
+	r.Header.Set("Accept", "application/json")
+	w = serve(c, r)
+	assertStatus(t, w, http.StatusOK)
+	assertHeader(t, w, "Content-Type", "application/json")
+
+	var page struct {
+		Customers []view.Customer
+		Total     int
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if (page.Total != 2) || (len(page.Customers) != 2) || (page.Customers[0].FirstName != "Jane") {
+		t.Fatalf("unexpected list %+v", page)
+	}
+}
+
+func TestShow(t *testing.T) {
+	c := newTestController()
+
+	w := serve(c, httptest.NewRequest(http.MethodGet, customerPath+"1", nil))
+	assertStatus(t, w, http.StatusOK)
+	assertHeader(t, w, "Content-Type", "text/html; charset=utf-8")
+	if body := w.Body.String(); !strings.Contains(body, "John") || !strings.Contains(body, csrfFieldName) {
+		t.Fatalf("expected a form for John with a CSRF token:\n%s", body)
+	}
+	if !strings.Contains(w.Header().Get("Set-Cookie"), csrfCookieName+"=") {
+		t.Fatalf("expected a CSRF cookie, got %q", w.Header().Get("Set-Cookie"))
+	}
+
+	// The path suffix selects the format
+	w = serve(c, httptest.NewRequest(http.MethodGet, customerPath+"2.json", nil))
+	assertStatus(t, w, http.StatusOK)
+	assertHeader(t, w, "Content-Type", "application/json")
+
+	var cust view.Customer
+	if err := json.Unmarshal(w.Body.Bytes(), &cust); err != nil {
+		t.Fatal(err)
+	}
+	if (cust.ID != 2) || (cust.FirstName != "Jane") || (cust.Address.City != "Toronto") {
+		t.Fatalf("unexpected customer %+v", cust)
+	}
+}
+
+func TestUnknownID(t *testing.T) {
+	c := newTestController()
+
+	for _, path := range []string{customerPath + "99", customerPath + "abc", customerPath + "99" + eventsPathSuffix} {
+		w := serve(c, httptest.NewRequest(http.MethodGet, path, nil))
+		assertStatus(t, w, http.StatusNotFound)
+	}
+}
+
+func TestWrongMethod(t *testing.T) {
+	c := newTestController()
+
+	w := serve(c, httptest.NewRequest(http.MethodDelete, customerPath+"1", nil))
+	assertStatus(t, w, http.StatusMethodNotAllowed)
+	assertHeader(t, w, "Allow", "GET, HEAD, POST")
+
+	w = serve(c, httptest.NewRequest(http.MethodPut, customersPath, nil))
+	assertStatus(t, w, http.StatusMethodNotAllowed)
+	assertHeader(t, w, "Allow", "GET, HEAD, POST")
+
+	w = serve(c, httptest.NewRequest(http.MethodPost, newCustomerPath, nil))
+	assertStatus(t, w, http.StatusMethodNotAllowed)
+	assertHeader(t, w, "Allow", "GET, HEAD")
+}
+
+func TestNotAcceptable(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, customerPath+"1", nil)
+	r.Header.Set("Accept", "image/png")
+
+	w := serve(newTestController(), r)
+	assertStatus(t, w, http.StatusNotAcceptable)
+}
+
+func TestAccept(t *testing.T) {
+	c := newTestController()
+
+	for accept, want := range map[string]string{
+		"":                 "text/html; charset=utf-8",
+		"application/json": "application/json",
+		"text/html;q=0.5, application/json;q=0.8": "application/json",
+		"text/*":                              "text/html; charset=utf-8",
+		"text/html;q=0, text/*":               "text/csv; charset=utf-8",
+		"text/html;q=0, application/json;q=0": "",
+	} {
+		r := httptest.NewRequest(http.MethodGet, customersPath, nil)
+		r.Header.Set("Accept", accept)
+
+		w := serve(c, r)
+		if want == "" {
+			assertStatus(t, w, http.StatusNotAcceptable)
+			continue
+		}
+
+		assertStatus(t, w, http.StatusOK)
+		assertHeader(t, w, "Content-Type", want)
+	}
+}
+
+func TestPostBadCSRF(t *testing.T) {
+	c := newTestController()
+
+	for name, r := range map[string]*http.Request{
+		"no cookie":   postForm(customersPath, customerForm(testCSRFToken), ""),
+		"no field":    postForm(customersPath, customerForm(""), testCSRFToken),
+		"wrong field": postForm(customersPath, customerForm(strings.Repeat("cd", csrfTokenBytes)), testCSRFToken),
+	} {
+		w := serve(c, r)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s: expected status %d, got %d", name, http.StatusForbidden, w.Code)
+		}
+	}
+
+	if _, exists := c.model.GetCustomer(3); exists {
+		t.Fatal("a customer was created without a valid CSRF token")
+	}
+}
+
+func TestPostInvalid(t *testing.T) {
+	c := newTestController()
+
+	form := customerForm(testCSRFToken)
+	form.Del("firstName")
+	form.Set("mailCode", "!")
+
+	r := postForm(customersPath+".json", form, testCSRFToken)
+	w := serve(c, r)
+	assertStatus(t, w, http.StatusUnprocessableEntity)
+	assertHeader(t, w, "Content-Type", "application/json")
+
+	var errs struct{ Errors map[string]string }
+	if err := json.Unmarshal(w.Body.Bytes(), &errs); err != nil {
+		t.Fatal(err)
+	}
+	if (errs.Errors["firstName"] == "") || (errs.Errors["mailCode"] == "") || (len(errs.Errors) != 2) {
+		t.Fatalf("unexpected errors %+v", errs.Errors)
+	}
+}
+
+func TestPostValid(t *testing.T) {
+	c := newTestController()
+
+	// Create
+	w := serve(c, postForm(customersPath, customerForm(testCSRFToken), testCSRFToken))
+	assertStatus(t, w, http.StatusSeeOther)
+	assertHeader(t, w, "Location", customerPath+"3?saved=1")
+
+	cust, exists := c.model.GetCustomer(3)
+	if !exists || (cust.FirstName != "Ann") || (cust.Address.City != "Seattle") {
+		t.Fatalf("unexpected customer %+v", cust)
+	}
+
+	// Update, keeping the format suffix
+	form := customerForm(testCSRFToken)
+	form.Set("firstName", "Johnny")
+	w = serve(c, postForm(customerPath+"1.json", form, testCSRFToken))
+	assertStatus(t, w, http.StatusSeeOther)
+	assertHeader(t, w, "Location", customerPath+"1.json?saved=1")
+
+	if cust, _ := c.model.GetCustomer(1); cust.FirstName != "Johnny" {
+		t.Fatalf("expected Johnny, got %+v", cust)
+	}
+}
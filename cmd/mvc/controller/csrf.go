@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+)
+
+// CSRF constants.
+// The token is stored in a cookie and submitted in a hidden form field (double submit cookie).
+// A cross site form cannot read the cookie, so it cannot submit a matching field.
+const (
+	csrfCookieName = "csrfToken"
+	csrfFieldName  = "csrfToken"
+	csrfTokenBytes = 32
+)
+
+// csrfToken returns the CSRF token of the request, generating a new one and setting the cookie if the request has none
+func csrfToken(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(csrfCookieName); (err == nil) && (len(cookie.Value) == 2*csrfTokenBytes) {
+		return cookie.Value
+	}
+
+	buf := make([]byte, csrfTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+
+	token := hex.EncodeToString(buf)
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	return token
+}
+
+// validCSRF returns true if the submitted form field matches the cookie
+func validCSRF(r *http.Request) bool {
+	cookie, err := r.Cookie(csrfCookieName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(r.PostFormValue(csrfFieldName))) == 1
+}
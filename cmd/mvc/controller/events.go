@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bantling/gopatterns/cmd/mvc/model"
+)
+
+// Server-Sent Events constants
+const (
+	eventsPathSuffix  = "/events"
+	afterParam        = "after"
+	lastEventIDHeader = "Last-Event-ID"
+	customerEvent     = "customer"
+	retryMillis       = 3000
+	keepAliveInterval = 30 * time.Second
+)
+
+// eventsURL returns the URL of the stream of changes to a customer after the most recent change
+func (c controller) eventsURL(id int) string {
+	return fmt.Sprintf("%s%d%s?%s=%d", customerPath, id, eventsPathSuffix, afterParam, c.model.LastChangeID())
+}
+
+// streamCustomer streams changes to a customer as Server-Sent Events, until the client disconnects or the controller
+// is shut down.
+//
+// The first connection provides the id of the last change the client has seen in the after query parameter.
+// When the client reconnects, it provides the id of the last event it received in the Last-Event-ID header, so that
+// any changes that occurred while it was disconnected are sent first.
+func (c controller) streamCustomer(w http.ResponseWriter, r *http.Request, id int) {
+	if !allowMethods(w, r) {
+		return
+	}
+
+	flusher, isa := w.(http.Flusher)
+	if !isa {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	lastID := r.Header.Get(lastEventIDHeader)
+	if lastID == "" {
+		lastID = r.URL.Query().Get(afterParam)
+	}
+
+	afterID, err := strconv.ParseUint(lastID, 10, 64)
+	if (err != nil) && (lastID != "") {
+		http.Error(w, "Invalid last event id", http.StatusBadRequest)
+		return
+	}
+
+	missed, sub := c.model.Subscribe(afterID)
+	defer sub.Cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", retryMillis)
+	flusher.Flush()
+
+	for _, change := range missed {
+		if err := c.sendChange(w, id, change); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case change, ok := <-sub.C:
+			if !ok {
+				// Not keeping up, the client reconnects and catches up
+				return
+			}
+
+			if err := c.sendChange(w, id, change); err != nil {
+				return
+			}
+
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep alive\n\n"); err != nil {
+				return
+			}
+
+		case <-r.Context().Done():
+			return
+
+		case <-c.done:
+			return
+		}
+
+		flusher.Flush()
+	}
+}
+
+// sendChange writes a change as an event, if it is a change to the customer with the given id
+func (c controller) sendChange(w http.ResponseWriter, id int, change model.Change) error {
+	if change.Customer.ID != id {
+		return nil
+	}
+
+	data, err := json.Marshal(toViewCustomer(change.Customer))
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", change.ID, customerEvent, data)
+	return err
+}
@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bantling/gopatterns/cmd/mvc/model"
+	"github.com/bantling/gopatterns/cmd/mvc/view"
+)
+
+// sseEvent is an event read from a stream
+type sseEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+// sseReader reads events from a stream, skipping comments and the retry field
+type sseReader struct {
+	scanner *bufio.Scanner
+}
+
+// next returns the next event, failing the test if the stream ends first
+func (r sseReader) next(t *testing.T) sseEvent {
+	t.Helper()
+
+	var ev sseEvent
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+		switch {
+		case line == "":
+			if ev.id != "" {
+				return ev
+			}
+
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+
+		case strings.HasPrefix(line, "event: "):
+			ev.event = strings.TrimPrefix(line, "event: ")
+
+		case strings.HasPrefix(line, "data: "):
+			ev.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+
+	t.Fatalf("stream ended: %v", r.scanner.Err())
+	return ev
+}
+
+// connect opens a stream of events, with a Last-Event-ID header if lastEventID is not empty.
+// It returns once the stream has started, so changes made afterwards are streamed.
+func connect(t *testing.T, url, lastEventID string) (*http.Response, sseReader) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set(lastEventIDHeader, lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected Content-Type text/event-stream, got %q", ct)
+	}
+
+	// The retry field is sent once the subscription exists
+	rdr := sseReader{scanner: bufio.NewScanner(resp.Body)}
+	if !rdr.scanner.Scan() || (rdr.scanner.Text() != fmt.Sprintf("retry: %d", retryMillis)) {
+		t.Fatalf("expected the retry field, got %q", rdr.scanner.Text())
+	}
+
+	return resp, rdr
+}
+
+// assertEvent fails the test unless an event is a change with the given id, for a customer with the given name
+func assertEvent(t *testing.T, ev sseEvent, id uint64, firstName string) {
+	t.Helper()
+
+	var cust view.Customer
+	if err := json.Unmarshal([]byte(ev.data), &cust); err != nil {
+		t.Fatalf("event %+v: %v", ev, err)
+	}
+
+	if (ev.id != fmt.Sprint(id)) || (ev.event != customerEvent) || (cust.FirstName != firstName) {
+		t.Fatalf("expected change %d to %s, got %+v", id, firstName, ev)
+	}
+}
+
+func TestStreamResume(t *testing.T) {
+	var (
+		c    = newTestController()
+		srv  = httptest.NewServer(c)
+		john = model.Customer{ID: 1, FirstName: "John", LastName: "Doe", Address: model.Address{Country: "USA"}}
+		jane = model.Customer{ID: 2, FirstName: "Jane", LastName: "Roe", Address: model.Address{Country: "Canada"}}
+		url  = srv.URL + c.eventsURL(1)
+	)
+	defer srv.Close()
+	defer c.Shutdown()
+
+	start := c.model.LastChangeID()
+	resp, rdr := connect(t, url, "")
+
+	// Changes to other customers are not streamed
+	john.FirstName = "Johnny"
+	c.model.SetCustomer(john)
+	jane.FirstName = "Janet"
+	c.model.SetCustomer(jane)
+	john.FirstName = "Jon"
+	c.model.SetCustomer(john)
+
+	assertEvent(t, rdr.next(t), start+1, "Johnny")
+	assertEvent(t, rdr.next(t), start+3, "Jon")
+
+	// Disconnect, and miss a change
+	resp.Body.Close()
+	john.FirstName = "Jonathan"
+	c.model.SetCustomer(john)
+
+	// Reconnect after the first event: only the later events are replayed, then new changes are streamed
+	resp, rdr = connect(t, url, fmt.Sprint(start+1))
+	defer resp.Body.Close()
+
+	assertEvent(t, rdr.next(t), start+3, "Jon")
+	assertEvent(t, rdr.next(t), start+4, "Jonathan")
+
+	john.FirstName = "John"
+	c.model.SetCustomer(john)
+	assertEvent(t, rdr.next(t), start+5, "John")
+}
+
+func TestStreamInvalidLastEventID(t *testing.T) {
+	c := newTestController()
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+c.eventsURL(1), nil)
+	req.Header.Set(lastEventIDHeader, "soon")
+
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bantling/gopatterns/cmd/mvc/view"
+)
+
+// Field length limits
+const (
+	maxNameLength    = 50
+	maxAddressLength = 100
+)
+
+var (
+	mailCodeRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 -]{1,9}$`)
+)
+
+// customerFromForm reads a customer from a submitted form.
+// The returned map contains a message in the given language for each invalid field, keyed by form field name, and is
+// empty if the customer is valid.
+func customerFromForm(r *http.Request, lang string) (view.Customer, map[string]string) {
+	var (
+		vcust = view.Customer{
+			FirstName: strings.TrimSpace(r.PostFormValue("firstName")),
+			LastName:  strings.TrimSpace(r.PostFormValue("lastName")),
+			Address: view.Address{
+				Line:     strings.TrimSpace(r.PostFormValue("line")),
+				City:     strings.TrimSpace(r.PostFormValue("city")),
+				Region:   strings.TrimSpace(r.PostFormValue("region")),
+				Country:  strings.TrimSpace(r.PostFormValue("country")),
+				MailCode: strings.TrimSpace(r.PostFormValue("mailCode")),
+			},
+		}
+		errs = map[string]string{}
+	)
+
+	validateLength(errs, lang, "firstName", vcust.FirstName, true, maxNameLength)
+	validateLength(errs, lang, "lastName", vcust.LastName, true, maxNameLength)
+	validateLength(errs, lang, "line", vcust.Address.Line, true, maxAddressLength)
+	validateLength(errs, lang, "city", vcust.Address.City, true, maxAddressLength)
+	validateLength(errs, lang, "region", vcust.Address.Region, false, maxAddressLength)
+	validateLength(errs, lang, "country", vcust.Address.Country, true, maxAddressLength)
+
+	if (vcust.Address.MailCode != "") && !mailCodeRegex.MatchString(vcust.Address.MailCode) {
+		errs["mailCode"] = view.T(lang, "error.mailCode")
+	}
+
+	return vcust, errs
+}
+
+// validateLength adds an error in the given language for the field if it is required and empty, or longer than max
+// characters
+func validateLength(errs map[string]string, lang, field, value string, required bool, max int) {
+	switch {
+	case required && (value == ""):
+		errs[field] = view.T(lang, "error.required")
+
+	case utf8.RuneCountInString(value) > max:
+		errs[field] = view.T(lang, "error.tooLong", max)
+	}
+}
@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build ignore
+// +build ignore
+
+// gen_mapping generates mapping_gen.go, which maps between model and view types.
+// Run it with go generate after changing the fields of model or view types.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+
+	"github.com/bantling/gopatterns/cmd/mvc/mapping"
+	"github.com/bantling/gopatterns/cmd/mvc/model"
+	"github.com/bantling/gopatterns/cmd/mvc/view"
+)
+
+// outputFile is the generated file
+const outputFile = "mapping_gen.go"
+
+func main() {
+	pairs := []mapping.Pair{
+		{Src: reflect.TypeOf(model.Customer{}), Dst: reflect.TypeOf(view.Customer{}), Func: "toViewCustomer"},
+		{Src: reflect.TypeOf(view.Customer{}), Dst: reflect.TypeOf(model.Customer{}), Func: "toModelCustomer"},
+	}
+
+	for _, pair := range pairs {
+		report, err := mapping.ReportOf(pair.Src, pair.Dst)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		if !report.Complete() {
+			fmt.Fprintf(os.Stderr, "%s to %s: %s\n", pair.Src, pair.Dst, report)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := mapping.Generate(&buf, "github.com/bantling/gopatterns/cmd/mvc/controller", pairs...); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if err := ioutil.WriteFile(outputFile, buf.Bytes(), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by mapping.Generate; DO NOT EDIT.
+
+package controller
+
+import (
+	"github.com/bantling/gopatterns/cmd/mvc/model"
+	"github.com/bantling/gopatterns/cmd/mvc/view"
+)
+
+// toViewCustomer maps model.Customer to view.Customer
+func toViewCustomer(src model.Customer) (dst view.Customer) {
+	dst.ID = src.ID
+	dst.FirstName = src.FirstName
+	dst.LastName = src.LastName
+	dst.Address = mapModelAddressToViewAddress(src.Address)
+
+	return
+}
+
+// mapModelAddressToViewAddress maps model.Address to view.Address
+func mapModelAddressToViewAddress(src model.Address) (dst view.Address) {
+	dst.Line = src.Line
+	dst.City = src.City
+	dst.Region = src.Region
+	dst.Country = src.Country
+	dst.MailCode = src.MailCode
+
+	return
+}
+
+// toModelCustomer maps view.Customer to model.Customer
+func toModelCustomer(src view.Customer) (dst model.Customer) {
+	dst.ID = src.ID
+	dst.FirstName = src.FirstName
+	dst.LastName = src.LastName
+	dst.Address = mapViewAddressToModelAddress(src.Address)
+
+	return
+}
+
+// mapViewAddressToModelAddress maps view.Address to model.Address
+func mapViewAddressToModelAddress(src view.Address) (dst model.Address) {
+	dst.Line = src.Line
+	dst.City = src.City
+	dst.Region = src.Region
+	dst.Country = src.Country
+	dst.MailCode = src.MailCode
+
+	return
+}
@@ -3,10 +3,125 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/bantling/gopatterns/cmd/mvc/controller"
+	"github.com/bantling/gopatterns/cmd/mvc/model"
+	"github.com/bantling/gopatterns/cmd/mvc/view"
+)
+
+// Sample data to generate customers from
+var (
+	sampleFirstNames = []string{"Ann", "Bob", "Carla", "Dev", "Emma", "Farid", "Grace", "Hiro", "Ines", "Jamal", "Kim", "Luis"}
+	sampleLastNames  = []string{"Ahmed", "Brown", "Chen", "Dubois", "Evans", "Fischer", "Garcia", "Haddad", "Ito", "Jones"}
+	sampleStreets    = []string{"Main St", "Oak Ave", "Elm St", "King St", "Queen St", "Park Rd"}
+	samplePlaces     = []struct{ City, Region, Country string }{
+		{"New York", "New York", "USA"},
+		{"Seattle", "Washington", "USA"},
+		{"Toronto", "Ontario", "Canada"},
+		{"Vancouver", "British Columbia", "Canada"},
+		{"London", "", "United Kingdom"},
+		{"Berlin", "", "Germany"},
+	}
 )
 
+// generateCustomers adds n customers with random sample data.
+// The same customers are generated every time.
+func generateCustomers(m *model.Model, n int) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < n; i++ {
+		place := samplePlaces[rnd.Intn(len(samplePlaces))]
+		m.SetCustomer(model.Customer{
+			FirstName: sampleFirstNames[rnd.Intn(len(sampleFirstNames))],
+			LastName:  sampleLastNames[rnd.Intn(len(sampleLastNames))],
+			Address: model.Address{
+				Line:     fmt.Sprintf("%d %s", 1+rnd.Intn(999), sampleStreets[rnd.Intn(len(sampleStreets))]),
+				City:     place.City,
+				Region:   place.Region,
+				Country:  place.Country,
+				MailCode: fmt.Sprintf("%05d", rnd.Intn(100000)),
+			},
+		})
+	}
+}
+
 func main() {
-	cntl := controller.NewController()
+	var (
+		addr        = flag.String("addr", ":8080", "address to listen on")
+		gracePeriod = flag.Duration("grace", 10*time.Second, "time to wait for requests to complete on shutdown")
+		templateDir = flag.String("templates", "", "directory to load templates from (default is the compiled in templates)")
+		dev         = flag.Bool("dev", false, "development mode: reload templates when they change")
+		generate    = flag.Int("generate", 0, "number of sample customers to generate")
+	)
+	flag.Parse()
+
+	templateFS := view.AssetTemplates()
+	if *templateDir != "" {
+		templateFS = os.DirFS(*templateDir)
+	}
+
+	templates := view.NewTemplates(templateFS).WithReload(*dev)
+	if err := templates.LoadAll(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Println("==== SetCustomer(Customer)")
+	mdl := model.NewModel()
+	mdl.SetCustomer(
+		model.Customer{
+			ID:        1,
+			FirstName: "John",
+			LastName:  "Doe",
+			Address: model.Address{
+				Line:     "123 Sesame St",
+				City:     "New York",
+				Region:   "New York",
+				Country:  "USA",
+				MailCode: "12345",
+			},
+		},
+	)
+
+	generateCustomers(mdl, *generate)
+
+	cntl := controller.NewController(mdl, view.NewView().WithTemplates(templates))
 	cntl.GetCustomer(1)
+
+	srv := &http.Server{Addr: *addr, Handler: cntl}
+	srv.RegisterOnShutdown(cntl.Shutdown)
+
+	// Shut down gracefully on interrupt, allowing in flight requests to complete
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+
+		ctx, cancel := context.WithTimeout(context.Background(), *gracePeriod)
+		defer cancel()
+
+		if err := srv.Shutdown(ctx); err != nil {
+			fmt.Fprintln(os.Stderr, "Shutdown:", err)
+		}
+	}()
+
+	fmt.Println("==== Listening on", *addr)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	<-done
+	fmt.Println("==== Shut down")
 }
@@ -0,0 +1,253 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mapping
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Pair is a source and target type to generate a mapping function for.
+// If Func is empty, the function is named map{Source}To{Target}, eg mapModelCustomerToViewCustomer.
+type Pair struct {
+	Src  reflect.Type
+	Dst  reflect.Type
+	Func string
+}
+
+// generator accumulates generated functions and the imports they need
+type generator struct {
+	pkgPath string
+	imports map[string]bool
+	names   map[*conversion]string
+	order   []*conversion
+}
+
+// Generate writes a Go source file for the package with the given import path, containing a function for each pair that
+// maps the source type to the target type without reflection.
+// Functions for nested structs, slices, arrays and pointers are generated as needed.
+// Unmapped fields are listed in the comment of each generated function.
+func Generate(w io.Writer, pkgPath string, pairs ...Pair) error {
+	g := &generator{
+		pkgPath: pkgPath,
+		imports: map[string]bool{},
+		names:   map[*conversion]string{},
+	}
+
+	for _, pair := range pairs {
+		conv, err := plan(pair.Src, pair.Dst)
+		if err != nil {
+			return err
+		}
+
+		if conv.kind <= convert {
+			return fmt.Errorf("Cannot generate a function to map %s to %s, it is a simple assignment or conversion", pair.Src, pair.Dst)
+		}
+
+		if pair.Func != "" {
+			g.names[conv] = pair.Func
+		}
+		g.collect(conv)
+	}
+
+	var body bytes.Buffer
+	for _, conv := range g.order {
+		g.function(&body, conv)
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintln(&src, "// SPDX-License-Identifier: Apache-2.0")
+	fmt.Fprintln(&src)
+	fmt.Fprintln(&src, "// Code generated by mapping.Generate; DO NOT EDIT.")
+	fmt.Fprintln(&src)
+	fmt.Fprintf(&src, "package %s\n\n", path.Base(pkgPath))
+
+	if len(g.imports) > 0 {
+		var imports []string
+		for imp := range g.imports {
+			imports = append(imports, imp)
+		}
+		sort.Strings(imports)
+
+		fmt.Fprintln(&src, "import (")
+		for _, imp := range imports {
+			fmt.Fprintf(&src, "\t%q\n", imp)
+		}
+		fmt.Fprintln(&src, ")")
+	}
+
+	body.WriteTo(&src)
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return fmt.Errorf("Generated code is invalid: %w", err)
+	}
+
+	_, err = w.Write(formatted)
+	return err
+}
+
+// collect adds a conversion and all nested conversions that need a function, in the order they are first used
+func (g *generator) collect(conv *conversion) {
+	if conv.kind <= convert {
+		return
+	}
+
+	for _, c := range g.order {
+		if c == conv {
+			return
+		}
+	}
+
+	if g.names[conv] == "" {
+		g.names[conv] = "map" + typeName(conv.src) + "To" + typeName(conv.dst)
+	}
+	g.order = append(g.order, conv)
+
+	for _, f := range conv.fields {
+		g.collect(f.conv)
+	}
+
+	if conv.elem != nil {
+		g.collect(conv.elem)
+	}
+}
+
+// typeName returns a name for a type that can be part of a function name, eg ModelCustomer or SliceOfModelLine
+func typeName(t reflect.Type) string {
+	switch {
+	case t.Name() != "":
+		var pkg string
+		if t.PkgPath() != "" {
+			pkg = path.Base(t.PkgPath())
+		}
+
+		return upperFirst(pkg) + upperFirst(t.Name())
+
+	case t.Kind() == reflect.Slice:
+		return "SliceOf" + typeName(t.Elem())
+
+	case t.Kind() == reflect.Array:
+		return fmt.Sprintf("Array%dOf", t.Len()) + typeName(t.Elem())
+
+	case t.Kind() == reflect.Ptr:
+		return "PtrTo" + typeName(t.Elem())
+
+	default:
+		return upperFirst(t.Kind().String())
+	}
+}
+
+// upperFirst returns a string with the first letter in upper case
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// typeExpr returns the Go expression for a type, qualified by package name if it is not in the generated package,
+// and records the imports needed
+func (g *generator) typeExpr(t reflect.Type) string {
+	switch {
+	case t.Name() != "":
+		if (t.PkgPath() == "") || (t.PkgPath() == g.pkgPath) {
+			return t.Name()
+		}
+
+		g.imports[t.PkgPath()] = true
+		return path.Base(t.PkgPath()) + "." + t.Name()
+
+	case t.Kind() == reflect.Slice:
+		return "[]" + g.typeExpr(t.Elem())
+
+	case t.Kind() == reflect.Array:
+		return fmt.Sprintf("[%d]", t.Len()) + g.typeExpr(t.Elem())
+
+	case t.Kind() == reflect.Ptr:
+		return "*" + g.typeExpr(t.Elem())
+
+	case t.Kind() == reflect.Map:
+		return "map[" + g.typeExpr(t.Key()) + "]" + g.typeExpr(t.Elem())
+
+	default:
+		return t.String()
+	}
+}
+
+// expr returns the Go expression that maps a source expression
+func (g *generator) expr(conv *conversion, src string) string {
+	switch conv.kind {
+	case assign:
+		return src
+
+	case convert:
+		return fmt.Sprintf("%s(%s)", g.typeExpr(conv.dst), src)
+
+	default:
+		return fmt.Sprintf("%s(%s)", g.names[conv], src)
+	}
+}
+
+// function writes the function for a conversion
+func (g *generator) function(w io.Writer, conv *conversion) {
+	var (
+		name = g.names[conv]
+		src  = g.typeExpr(conv.src)
+		dst  = g.typeExpr(conv.dst)
+	)
+
+	fmt.Fprintf(w, "\n// %s maps %s to %s\n", name, src, dst)
+	if conv.kind == structMap {
+		if len(conv.report.UnmappedSource) > 0 {
+			fmt.Fprintf(w, "// Unmapped source fields: %s\n", strings.Join(conv.report.UnmappedSource, ", "))
+		}
+
+		if len(conv.report.UnmappedTarget) > 0 {
+			fmt.Fprintf(w, "// Unmapped target fields: %s\n", strings.Join(conv.report.UnmappedTarget, ", "))
+		}
+	}
+	fmt.Fprintf(w, "func %s(src %s) (dst %s) {\n", name, src, dst)
+
+	switch conv.kind {
+	case structMap:
+		for _, f := range conv.fields {
+			srcField := "src." + conv.src.FieldByIndex(f.srcIndex).Name
+			fmt.Fprintf(w, "dst.%s = %s\n", f.name, g.expr(f.conv, srcField))
+		}
+
+	case sliceMap:
+		fmt.Fprintln(w, "if src == nil {")
+		fmt.Fprintln(w, "return")
+		fmt.Fprintln(w, "}")
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "dst = make(%s, len(src))\n", dst)
+		fmt.Fprintln(w, "for i, elem := range src {")
+		fmt.Fprintf(w, "dst[i] = %s\n", g.expr(conv.elem, "elem"))
+		fmt.Fprintln(w, "}")
+
+	case arrayMap:
+		fmt.Fprintln(w, "for i, elem := range src {")
+		fmt.Fprintf(w, "dst[i] = %s\n", g.expr(conv.elem, "elem"))
+		fmt.Fprintln(w, "}")
+
+	case ptrMap:
+		fmt.Fprintln(w, "if src == nil {")
+		fmt.Fprintln(w, "return")
+		fmt.Fprintln(w, "}")
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "elem := %s\n", g.expr(conv.elem, "*src"))
+		fmt.Fprintln(w, "dst = &elem")
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "return")
+	fmt.Fprintln(w, "}")
+}
@@ -0,0 +1,401 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package mapping copies values between struct types that have the same shape but are not the same type, such as a
+// model type and a view type.
+//
+// Fields are matched by name, which can be changed with a `map:"name"` tag, or excluded with a `map:"-"` tag.
+// Unexported fields are not mapped.
+// Matching fields are mapped if:
+// - the source type is assignable or convertible to the target type, other than a conversion between a number and a string
+// - both types are structs, which are mapped recursively
+// - both types are slices, arrays, or pointers, whose elements are mapped recursively
+//
+// Numeric conversions that can lose information, such as float64 to int or int64 to int32, are not allowed.
+//
+// Map copies values using reflection, while Generate emits plain Go functions that copy values, for speed.
+package mapping
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// tagName is the struct tag key that renames or excludes a field
+const tagName = "map"
+
+// convKind is the way a value of one type is mapped to another
+type convKind uint
+
+// convKind constants
+const (
+	assign convKind = iota
+	convert
+	structMap
+	sliceMap
+	arrayMap
+	ptrMap
+)
+
+// fieldMapping maps one field of a struct
+type fieldMapping struct {
+	name     string
+	srcIndex []int
+	dstIndex []int
+	conv     *conversion
+}
+
+// conversion is a plan for mapping values of a source type to a target type
+type conversion struct {
+	kind   convKind
+	src    reflect.Type
+	dst    reflect.Type
+	fields []fieldMapping
+	elem   *conversion
+	report Report
+}
+
+// Report lists the fields that were not mapped, as dotted paths (eg Address.Line).
+// Fields of slice and array elements have [] after the slice or array field name (eg Lines[].Product).
+type Report struct {
+	UnmappedSource []string
+	UnmappedTarget []string
+}
+
+// Complete returns true if every field was mapped
+func (r Report) Complete() bool {
+	return (len(r.UnmappedSource) == 0) && (len(r.UnmappedTarget) == 0)
+}
+
+// String is Stringer for Report
+func (r Report) String() string {
+	if r.Complete() {
+		return "all fields mapped"
+	}
+
+	return fmt.Sprintf("unmapped source fields %v, unmapped target fields %v", r.UnmappedSource, r.UnmappedTarget)
+}
+
+// typePair is the key of the cache of conversions
+type typePair struct {
+	src reflect.Type
+	dst reflect.Type
+}
+
+// Cache of conversions, and the mutex that guards it
+var (
+	conversionsMu sync.Mutex
+	conversions   = map[typePair]*conversion{}
+)
+
+// plan returns the conversion from a source type to a target type, building and caching it if necessary
+func plan(src, dst reflect.Type) (*conversion, error) {
+	conversionsMu.Lock()
+	defer conversionsMu.Unlock()
+
+	building := map[typePair]*conversion{}
+	conv, err := build(src, dst, building)
+	if err != nil {
+		return nil, err
+	}
+
+	for pair, c := range building {
+		conversions[pair] = c
+	}
+
+	return conv, nil
+}
+
+// build builds the conversion from a source type to a target type.
+// Conversions being built are tracked so that recursive types are handled.
+// Must be called with the mutex held.
+func build(src, dst reflect.Type, building map[typePair]*conversion) (*conversion, error) {
+	pair := typePair{src: src, dst: dst}
+	if conv := conversions[pair]; conv != nil {
+		return conv, nil
+	}
+
+	if conv := building[pair]; conv != nil {
+		return conv, nil
+	}
+
+	conv := &conversion{src: src, dst: dst}
+
+	switch {
+	case src.AssignableTo(dst):
+		conv.kind = assign
+
+	case (src.Kind() == reflect.Struct) && (dst.Kind() == reflect.Struct):
+		conv.kind = structMap
+		building[pair] = conv
+		if err := buildFields(conv, building); err != nil {
+			return nil, err
+		}
+
+	case (src.Kind() == reflect.Slice) && (dst.Kind() == reflect.Slice),
+		(src.Kind() == reflect.Ptr) && (dst.Kind() == reflect.Ptr),
+		(src.Kind() == reflect.Array) && (dst.Kind() == reflect.Array) && (src.Len() == dst.Len()):
+		conv.kind = map[reflect.Kind]convKind{reflect.Slice: sliceMap, reflect.Ptr: ptrMap, reflect.Array: arrayMap}[src.Kind()]
+		building[pair] = conv
+
+		elem, err := build(src.Elem(), dst.Elem(), building)
+		if err != nil {
+			return nil, err
+		}
+		conv.elem = elem
+
+	case convertible(src, dst):
+		conv.kind = convert
+
+	default:
+		return nil, fmt.Errorf("Cannot map %s to %s", src, dst)
+	}
+
+	return conv, nil
+}
+
+// convertible returns true if a source type can be converted to a target type without changing the meaning of the
+// value, which excludes conversions between numbers and strings, and numeric conversions that can lose information
+func convertible(src, dst reflect.Type) bool {
+	if !src.ConvertibleTo(dst) {
+		return false
+	}
+
+	if (src.Kind() == reflect.String) != (dst.Kind() == reflect.String) {
+		return false
+	}
+
+	srcNum, srcBits := numeric(src)
+	dstNum, dstBits := numeric(dst)
+	if (srcNum == notNumeric) || (dstNum == notNumeric) {
+		return true
+	}
+
+	switch {
+	case srcNum == dstNum:
+		return dstBits >= srcBits
+
+	case (srcNum == unsignedNumeric) && (dstNum == signedNumeric):
+		return dstBits > srcBits
+
+	case (srcNum != floatNumeric) && (dstNum == floatNumeric):
+		// Every integer fits in the mantissa of the float
+		return srcBits <= map[int]int{32: 24, 64: 53}[dstBits]
+	}
+
+	// Signed to unsigned, and float to integer
+	return false
+}
+
+// numericKind is the kind of number a type is
+type numericKind uint
+
+// numericKind constants
+const (
+	notNumeric numericKind = iota
+	signedNumeric
+	unsignedNumeric
+	floatNumeric
+	complexNumeric
+)
+
+// numeric returns the kind of number a type is, and its size in bits
+func numeric(typ reflect.Type) (numericKind, int) {
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return signedNumeric, typ.Bits()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return unsignedNumeric, typ.Bits()
+	case reflect.Float32, reflect.Float64:
+		return floatNumeric, typ.Bits()
+	case reflect.Complex64, reflect.Complex128:
+		return complexNumeric, typ.Bits()
+	}
+
+	return notNumeric, 0
+}
+
+// mappedName returns the name a field is matched by, and false if it is excluded or unexported
+func mappedName(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+
+	switch tag := f.Tag.Get(tagName); tag {
+	case "-":
+		return "", false
+	case "":
+		return f.Name, true
+	default:
+		return tag, true
+	}
+}
+
+// buildFields builds the field mappings of a struct conversion, and the report of unmapped fields
+func buildFields(conv *conversion, building map[typePair]*conversion) error {
+	var (
+		srcFields = map[string]reflect.StructField{}
+		matched   = map[string]bool{}
+	)
+
+	for i := 0; i < conv.src.NumField(); i++ {
+		if name, ok := mappedName(conv.src.Field(i)); ok {
+			srcFields[name] = conv.src.Field(i)
+		}
+	}
+
+	for i := 0; i < conv.dst.NumField(); i++ {
+		dstField := conv.dst.Field(i)
+		name, ok := mappedName(dstField)
+		if !ok {
+			continue
+		}
+
+		srcField, haveSrc := srcFields[name]
+		if !haveSrc {
+			conv.report.UnmappedTarget = append(conv.report.UnmappedTarget, dstField.Name)
+			continue
+		}
+
+		fieldConv, err := build(srcField.Type, dstField.Type, building)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", conv.src, srcField.Name, err)
+		}
+
+		matched[name] = true
+		conv.fields = append(conv.fields, fieldMapping{
+			name:     dstField.Name,
+			srcIndex: srcField.Index,
+			dstIndex: dstField.Index,
+			conv:     fieldConv,
+		})
+	}
+
+	for name, srcField := range srcFields {
+		if !matched[name] {
+			conv.report.UnmappedSource = append(conv.report.UnmappedSource, srcField.Name)
+		}
+	}
+	sort.Strings(conv.report.UnmappedSource)
+
+	return nil
+}
+
+// fullReport adds the unmapped fields of a conversion and all nested conversions to a Report, prefixed by a path
+func (c *conversion) fullReport(prefix string, visited map[*conversion]bool, r *Report) {
+	if visited[c] {
+		return
+	}
+	visited[c] = true
+	defer delete(visited, c)
+
+	switch c.kind {
+	case structMap:
+		for _, name := range c.report.UnmappedSource {
+			r.UnmappedSource = append(r.UnmappedSource, prefix+name)
+		}
+
+		for _, name := range c.report.UnmappedTarget {
+			r.UnmappedTarget = append(r.UnmappedTarget, prefix+name)
+		}
+
+		for _, f := range c.fields {
+			f.conv.fullReport(prefix+f.name+".", visited, r)
+		}
+
+	case sliceMap, arrayMap:
+		c.elem.fullReport(strings.TrimSuffix(prefix, ".")+"[].", visited, r)
+
+	case ptrMap:
+		c.elem.fullReport(prefix, visited, r)
+	}
+}
+
+// ReportOf returns the fields that are not mapped from a source type to a target type
+func ReportOf(src, dst reflect.Type) (Report, error) {
+	conv, err := plan(src, dst)
+	if err != nil {
+		return Report{}, err
+	}
+
+	var r Report
+	conv.fullReport("", map[*conversion]bool{}, &r)
+
+	return r, nil
+}
+
+// Map copies a source value into the target, which must be a non-nil pointer, and reports the unmapped fields.
+// The source must not be nil or a nil pointer. A pointer source is dereferenced if the target is not a pointer.
+func Map(dst, src interface{}) (Report, error) {
+	dstVal := reflect.ValueOf(dst)
+	if (dstVal.Kind() != reflect.Ptr) || dstVal.IsNil() {
+		return Report{}, fmt.Errorf("Map target must be a non-nil pointer, not %T", dst)
+	}
+
+	srcVal := reflect.ValueOf(src)
+	for {
+		if (!srcVal.IsValid()) || ((srcVal.Kind() == reflect.Ptr) && srcVal.IsNil()) {
+			return Report{}, fmt.Errorf("Map source must not be a nil value or pointer, not %T", src)
+		}
+
+		if (srcVal.Kind() != reflect.Ptr) || (dstVal.Elem().Kind() == reflect.Ptr) {
+			break
+		}
+		srcVal = srcVal.Elem()
+	}
+
+	conv, err := plan(srcVal.Type(), dstVal.Elem().Type())
+	if err != nil {
+		return Report{}, err
+	}
+
+	conv.apply(dstVal.Elem(), srcVal)
+
+	var r Report
+	conv.fullReport("", map[*conversion]bool{}, &r)
+
+	return r, nil
+}
+
+// apply copies a source value into a settable target value
+func (c *conversion) apply(dst, src reflect.Value) {
+	switch c.kind {
+	case assign:
+		dst.Set(src)
+
+	case convert:
+		dst.Set(src.Convert(c.dst))
+
+	case structMap:
+		for _, f := range c.fields {
+			f.conv.apply(dst.FieldByIndex(f.dstIndex), src.FieldByIndex(f.srcIndex))
+		}
+
+	case sliceMap:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(c.dst))
+			return
+		}
+
+		dst.Set(reflect.MakeSlice(c.dst, src.Len(), src.Len()))
+		for i := 0; i < src.Len(); i++ {
+			c.elem.apply(dst.Index(i), src.Index(i))
+		}
+
+	case arrayMap:
+		for i := 0; i < src.Len(); i++ {
+			c.elem.apply(dst.Index(i), src.Index(i))
+		}
+
+	case ptrMap:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(c.dst))
+			return
+		}
+
+		dst.Set(reflect.New(c.dst.Elem()))
+		c.elem.apply(dst.Elem(), src.Elem())
+	}
+}
@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Code generated by mapping.Generate; DO NOT EDIT.
+
+package mapping
+
+// toTestOrderView maps testOrder to testOrderView
+// Unmapped source fields: Secret
+func toTestOrderView(src testOrder) (dst testOrderView) {
+	dst.ID = src.ID
+	dst.Reference = src.Ref
+	dst.Shipping = mapPtrToMappingTestAddressToPtrToMappingTestAddressView(src.Shipping)
+	dst.Billing = mapMappingTestAddressToMappingTestAddressView(src.Billing)
+	dst.Lines = mapSliceOfMappingTestLineToSliceOfMappingTestLineView(src.Lines)
+	dst.Totals = mapArray2OfMappingTestLineToArray2OfMappingTestLineView(src.Totals)
+	dst.Tags = src.Tags
+
+	return
+}
+
+// mapPtrToMappingTestAddressToPtrToMappingTestAddressView maps *testAddress to *testAddressView
+func mapPtrToMappingTestAddressToPtrToMappingTestAddressView(src *testAddress) (dst *testAddressView) {
+	if src == nil {
+		return
+	}
+
+	elem := mapMappingTestAddressToMappingTestAddressView(*src)
+	dst = &elem
+
+	return
+}
+
+// mapMappingTestAddressToMappingTestAddressView maps testAddress to testAddressView
+// Unmapped target fields: Postal
+func mapMappingTestAddressToMappingTestAddressView(src testAddress) (dst testAddressView) {
+	dst.Line = src.Line
+	dst.City = src.City
+	dst.Country = src.Country
+
+	return
+}
+
+// mapSliceOfMappingTestLineToSliceOfMappingTestLineView maps []testLine to []testLineView
+func mapSliceOfMappingTestLineToSliceOfMappingTestLineView(src []testLine) (dst []testLineView) {
+	if src == nil {
+		return
+	}
+
+	dst = make([]testLineView, len(src))
+	for i, elem := range src {
+		dst[i] = mapMappingTestLineToMappingTestLineView(elem)
+	}
+
+	return
+}
+
+// mapMappingTestLineToMappingTestLineView maps testLine to testLineView
+// Unmapped source fields: Note
+func mapMappingTestLineToMappingTestLineView(src testLine) (dst testLineView) {
+	dst.Product = src.Product
+	dst.Qty = int64(src.Qty)
+	dst.Cost = src.Cost
+
+	return
+}
+
+// mapArray2OfMappingTestLineToArray2OfMappingTestLineView maps [2]testLine to [2]testLineView
+func mapArray2OfMappingTestLineToArray2OfMappingTestLineView(src [2]testLine) (dst [2]testLineView) {
+	for i, elem := range src {
+		dst[i] = mapMappingTestLineToMappingTestLineView(elem)
+	}
+
+	return
+}
@@ -0,0 +1,280 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mapping
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+//go:generate go test -run TestGenerate -update
+
+// update rewrites the generated test file instead of comparing with it
+var update = flag.Bool("update", false, "rewrite "+generatedFile)
+
+// generatedFile contains the functions Generate emits for the test types
+const generatedFile = "mapping_gen_test.go"
+
+// testPkgPath is the import path the test functions are generated for
+const testPkgPath = "github.com/bantling/gopatterns/cmd/mvc/mapping"
+
+type testAddress struct {
+	Line    string
+	City    string
+	Country string
+}
+
+type testAddressView struct {
+	Line    string
+	City    string
+	Country string
+	Postal  string
+}
+
+type testLine struct {
+	Product string
+	Qty     int
+	Cost    float64
+	Note    string
+}
+
+type testLineView struct {
+	Product string
+	Qty     int64
+	Cost    float64
+}
+
+type testOrder struct {
+	ID       int
+	Ref      string
+	Secret   string
+	Shipping *testAddress
+	Billing  testAddress
+	Lines    []testLine
+	Totals   [2]testLine
+	Tags     [2]string
+	internal int
+}
+
+type testOrderView struct {
+	ID        int
+	Reference string `map:"Ref"`
+	Secret    string `map:"-"`
+	Shipping  *testAddressView
+	Billing   testAddressView
+	Lines     []testLineView
+	Totals    [2]testLineView
+	Tags      [2]string
+}
+
+// testPairs are the pairs generated into generatedFile
+var testPairs = []Pair{
+	{Src: reflect.TypeOf(testOrder{}), Dst: reflect.TypeOf(testOrderView{}), Func: "toTestOrderView"},
+}
+
+// testOrders are mapped by both Map and the generated functions
+func testOrders() []testOrder {
+	return []testOrder{
+		{},
+		{
+			ID:       1,
+			Ref:      "A-1",
+			Secret:   "hidden",
+			Shipping: &testAddress{Line: "1 Main St", City: "Springfield", Country: "USA"},
+			Billing:  testAddress{Line: "2 Side St", City: "Shelbyville", Country: "USA"},
+			Lines: []testLine{
+				{Product: "Widget", Qty: 2, Cost: 1.5, Note: "fragile"},
+				{Product: "Gadget", Qty: 1, Cost: 10},
+			},
+			Totals:   [2]testLine{{Product: "Subtotal", Cost: 13}, {Product: "Tax", Cost: 1.3}},
+			Tags:     [2]string{"rush", "gift"},
+			internal: 5,
+		},
+		{
+			ID:    2,
+			Lines: []testLine{},
+		},
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Generate(&buf, testPkgPath, testPairs...); err != nil {
+		t.Fatal(err)
+	}
+
+	if *update {
+		if err := ioutil.WriteFile(generatedFile, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(generatedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("Generate output differs from %s, run go generate to update it:\n%s", generatedFile, buf.String())
+	}
+}
+
+func TestMapMatchesGenerated(t *testing.T) {
+	for i, order := range testOrders() {
+		var got testOrderView
+		if _, err := Map(&got, order); err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+
+		if want := toTestOrderView(order); !reflect.DeepEqual(got, want) {
+			t.Errorf("%d: Map gave %+v, generated function gave %+v", i, got, want)
+		}
+	}
+}
+
+func TestMapPointers(t *testing.T) {
+	order := testOrders()[1]
+
+	var got *testOrderView
+	if _, err := Map(&got, &order); err != nil {
+		t.Fatal(err)
+	}
+
+	if got == nil {
+		t.Fatal("Map gave a nil pointer")
+	}
+
+	if (got.Shipping == nil) || (got.Shipping.City != order.Shipping.City) {
+		t.Errorf("Shipping = %+v, want a copy of %+v", got.Shipping, order.Shipping)
+	}
+
+	if want := toTestOrderView(order); !reflect.DeepEqual(*got, want) {
+		t.Errorf("Map gave %+v, generated function gave %+v", *got, want)
+	}
+}
+
+func TestMapReport(t *testing.T) {
+	var view testOrderView
+	report, err := Map(&view, testOrder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Report{
+		UnmappedSource: []string{"Secret", "Lines[].Note", "Totals[].Note"},
+		UnmappedTarget: []string{"Shipping.Postal", "Billing.Postal"},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("report = %+v, want %+v", report, want)
+	}
+
+	if report.Complete() {
+		t.Error("report should not be complete")
+	}
+
+	typeReport, err := ReportOf(reflect.TypeOf(testOrder{}), reflect.TypeOf(testOrderView{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(typeReport, report) {
+		t.Errorf("ReportOf = %+v, Map report = %+v", typeReport, report)
+	}
+}
+
+func TestMapNil(t *testing.T) {
+	var (
+		view  testOrderView
+		order *testOrder
+	)
+
+	for _, src := range []interface{}{nil, order} {
+		if _, err := Map(&view, src); err == nil {
+			t.Errorf("Map of %#v should fail", src)
+		}
+	}
+
+	if _, err := Map(nil, testOrder{}); err == nil {
+		t.Error("Map to nil should fail")
+	}
+
+	if _, err := Map(view, testOrder{}); err == nil {
+		t.Error("Map to a non-pointer should fail")
+	}
+}
+
+func TestMapPointerSource(t *testing.T) {
+	order := testOrders()[1]
+	orderPtr := &order
+
+	// A pointer source is dereferenced, as many times as necessary
+	for _, src := range []interface{}{&order, &orderPtr} {
+		var got testOrderView
+		if _, err := Map(&got, src); err != nil {
+			t.Fatalf("%T: %s", src, err)
+		}
+
+		if want := toTestOrderView(order); !reflect.DeepEqual(got, want) {
+			t.Errorf("%T: Map gave %+v, generated function gave %+v", src, got, want)
+		}
+	}
+
+	var (
+		view   testOrderView
+		nilPtr *testOrder
+	)
+	if _, err := Map(&view, &nilPtr); err == nil {
+		t.Error("Map of a pointer to a nil pointer should fail")
+	}
+}
+
+// testID is convertible to and from int
+type testID int
+
+func TestConvertible(t *testing.T) {
+	for _, test := range []struct {
+		src, dst interface{}
+		want     bool
+	}{
+		{int32(0), int64(0), true},
+		{int64(0), int32(0), false},
+		{int(0), int64(0), true},
+		{uint8(0), uint16(0), true},
+		{uint16(0), uint8(0), false},
+		{uint32(0), int64(0), true},
+		{uint32(0), int32(0), false},
+		{int8(0), uint64(0), false},
+		{float32(0), float64(0), true},
+		{float64(0), float32(0), false},
+		{float64(0), int(0), false},
+		{int16(0), float32(0), true},
+		{int32(0), float32(0), false},
+		{int32(0), float64(0), true},
+		{int64(0), float64(0), false},
+		{complex64(0), complex128(0), true},
+		{complex128(0), complex64(0), false},
+		{int(0), "", false},
+		{"", []byte(nil), false},
+		{int(0), testID(0), true},
+	} {
+		src, dst := reflect.TypeOf(test.src), reflect.TypeOf(test.dst)
+		if got := convertible(src, dst); got != test.want {
+			t.Errorf("convertible(%s, %s) = %t, want %t", src, dst, got, test.want)
+		}
+	}
+}
+
+func TestMapLossy(t *testing.T) {
+	var (
+		src = struct{ Price float64 }{1.5}
+		dst struct{ Price int }
+	)
+
+	if _, err := Map(&dst, src); err == nil {
+		t.Errorf("Map of float64 to int should fail, gave %+v", dst)
+	}
+}
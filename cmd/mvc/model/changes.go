@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package model
+
+import (
+	"sync"
+)
+
+// Change feed constants
+const (
+	changeHistorySize       = 1000
+	subscriptionChannelSize = 16
+)
+
+// Change is a change to a customer.
+// Changes are numbered from 1 in the order they occurred.
+type Change struct {
+	ID       uint64
+	Customer Customer
+}
+
+// Subscription receives changes on C.
+// C is closed when the subscription is cancelled, or if the subscriber does not keep up with changes.
+// A subscriber that did not keep up can subscribe again after the last change it received to catch up.
+type Subscription struct {
+	C    <-chan Change
+	c    chan Change
+	feed *changeFeed
+}
+
+// Cancel stops receiving changes
+func (s *Subscription) Cancel() {
+	s.feed.mu.Lock()
+	defer s.feed.mu.Unlock()
+
+	s.feed.remove(s)
+}
+
+// changeFeed publishes changes to subscribers, and remembers recent changes so that subscribers can catch up
+type changeFeed struct {
+	mu          sync.Mutex
+	lastID      uint64
+	history     []Change
+	subscribers map[*Subscription]bool
+}
+
+// newChangeFeed constructs a changeFeed
+func newChangeFeed() *changeFeed {
+	return &changeFeed{subscribers: map[*Subscription]bool{}}
+}
+
+// remove a subscriber and close its channel, if it has not already been removed.
+// Must be called with the mutex held.
+func (f *changeFeed) remove(s *Subscription) {
+	if f.subscribers[s] {
+		delete(f.subscribers, s)
+		close(s.c)
+	}
+}
+
+// publish a change of a customer to all subscribers, removing any that have not kept up
+func (f *changeFeed) publish(cust Customer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lastID++
+	change := Change{ID: f.lastID, Customer: cust}
+
+	if len(f.history) == changeHistorySize {
+		copy(f.history, f.history[1:])
+		f.history = f.history[:changeHistorySize-1]
+	}
+	f.history = append(f.history, change)
+
+	for s := range f.subscribers {
+		select {
+		case s.c <- change:
+		default:
+			f.remove(s)
+		}
+	}
+}
+
+// LastChangeID returns the id of the most recent change, or 0 if there have been no changes
+func (f *changeFeed) LastChangeID() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.lastID
+}
+
+// Subscribe returns the remembered changes after a change id, and a Subscription to receive changes that occur later.
+// If afterID is older than the oldest remembered change, some changes have been forgotten and are not returned.
+func (f *changeFeed) Subscribe(afterID uint64) ([]Change, *Subscription) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var missed []Change
+	for _, change := range f.history {
+		if change.ID > afterID {
+			missed = append(missed, change)
+		}
+	}
+
+	c := make(chan Change, subscriptionChannelSize)
+	s := &Subscription{C: c, c: c, feed: f}
+	f.subscribers[s] = true
+
+	return missed, s
+}
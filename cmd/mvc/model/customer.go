@@ -2,22 +2,58 @@
 
 package model
 
-// customerModel contains operations on Customer
+import (
+	"sync"
+)
+
+// customerModel contains operations on Customer, and publishes a Change whenever a customer is set.
+// It is safe for concurrent use.
 type customerModel struct {
+	*changeFeed
+	mu        sync.RWMutex
 	customers map[int]Customer
+	indexes   *customerIndexes
+	lastID    int
 }
 
 // newCustomerModel constructs customerModel
 func newCustomerModel() *customerModel {
-	return &customerModel{customers: map[int]Customer{}}
+	return &customerModel{
+		changeFeed: newChangeFeed(),
+		customers:  map[int]Customer{},
+		indexes:    newCustomerIndexes(),
+	}
 }
 
-// SetCustomer adds or replaces a customer by id
-func (c *customerModel) SetCustomer(data Customer) {
+// SetCustomer adds or replaces a customer by id, and returns the id.
+// If the id is zero, the customer is added with the next available id.
+func (c *customerModel) SetCustomer(data Customer) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if data.ID == 0 {
+		c.lastID++
+		data.ID = c.lastID
+	} else if data.ID > c.lastID {
+		c.lastID = data.ID
+	}
+
+	if prev, exists := c.customers[data.ID]; exists {
+		c.indexes.remove(prev)
+	}
+
 	c.customers[data.ID] = data
+	c.indexes.add(data)
+	c.publish(data)
+
+	return data.ID
 }
 
 // GetCustomer returns one customer by id and a flag indicating whether or not the customer exists
-func (c customerModel) GetCustomer(id int) Customer {
-	return c.customers[id]
+func (c *customerModel) GetCustomer(id int) (Customer, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cust, exists := c.customers[id]
+	return cust, exists
 }
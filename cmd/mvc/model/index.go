@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package model
+
+import (
+	"sort"
+	"strings"
+)
+
+// prefixIndex maps case insensitive keys to the ids of customers that have them, and finds the ids of every key that
+// starts with a prefix by binary searching the sorted keys.
+// The keys are kept sorted as they are added and removed, so that matching does not modify the index.
+type prefixIndex struct {
+	ids  map[string]map[int]bool
+	keys []string
+}
+
+// newPrefixIndex constructs a prefixIndex
+func newPrefixIndex() *prefixIndex {
+	return &prefixIndex{ids: map[string]map[int]bool{}}
+}
+
+// add adds the id under each non-empty key
+func (p *prefixIndex) add(id int, keys ...string) {
+	for _, key := range keys {
+		if key = strings.ToLower(key); key == "" {
+			continue
+		}
+
+		ids := p.ids[key]
+		if ids == nil {
+			ids = map[int]bool{}
+			p.ids[key] = ids
+
+			i := sort.SearchStrings(p.keys, key)
+			p.keys = append(p.keys, "")
+			copy(p.keys[i+1:], p.keys[i:])
+			p.keys[i] = key
+		}
+
+		ids[id] = true
+	}
+}
+
+// remove removes the id from each key, and removes keys that have no ids
+func (p *prefixIndex) remove(id int, keys ...string) {
+	for _, key := range keys {
+		key = strings.ToLower(key)
+		if ids := p.ids[key]; ids != nil {
+			delete(ids, id)
+			if len(ids) == 0 {
+				delete(p.ids, key)
+
+				i := sort.SearchStrings(p.keys, key)
+				p.keys = append(p.keys[:i], p.keys[i+1:]...)
+			}
+		}
+	}
+}
+
+// match returns the ids of all keys that start with a prefix, case insensitive
+func (p *prefixIndex) match(prefix string) map[int]bool {
+	var (
+		prefixLower = strings.ToLower(prefix)
+		result      = map[int]bool{}
+	)
+
+	for i := sort.SearchStrings(p.keys, prefixLower); (i < len(p.keys)) && strings.HasPrefix(p.keys[i], prefixLower); i++ {
+		for id := range p.ids[p.keys[i]] {
+			result[id] = true
+		}
+	}
+
+	return result
+}
+
+// orderEntry is a customer id and the key it is sorted by
+type orderEntry struct {
+	key string
+	id  int
+}
+
+// less returns true if the entry sorts before another, by key then by id
+func (e orderEntry) less(o orderEntry) bool {
+	if e.key != o.key {
+		return e.key < o.key
+	}
+
+	return e.id < o.id
+}
+
+// sortOrder is all customers ordered by one field, then by id
+type sortOrder []orderEntry
+
+// search returns the index of an entry, or where it would be inserted
+func (o sortOrder) search(e orderEntry) int {
+	return sort.Search(len(o), func(i int) bool { return !o[i].less(e) })
+}
+
+// insert returns the order with an entry inserted in its place
+func (o sortOrder) insert(e orderEntry) sortOrder {
+	i := o.search(e)
+	o = append(o, orderEntry{})
+	copy(o[i+1:], o[i:])
+	o[i] = e
+
+	return o
+}
+
+// delete returns the order without an entry
+func (o sortOrder) delete(e orderEntry) sortOrder {
+	if i := o.search(e); (i < len(o)) && (o[i] == e) {
+		o = append(o[:i], o[i+1:]...)
+	}
+
+	return o
+}
+
+// ids returns the ids in order
+func (o sortOrder) ids() []int {
+	ids := make([]int, len(o))
+	for i, e := range o {
+		ids[i] = e.id
+	}
+
+	return ids
+}
+
+// sortKey returns the value of a customer field to sort by, case insensitive
+type sortKey func(Customer) string
+
+// Sort field names
+const (
+	SortByID        = "id"
+	SortByFirstName = "firstName"
+	SortByLastName  = "lastName"
+	SortByLine      = "line"
+	SortByCity      = "city"
+	SortByRegion    = "region"
+	SortByCountry   = "country"
+	SortByMailCode  = "mailCode"
+)
+
+// sortKeys are the fields customers can be sorted by, other than id
+var sortKeys = map[string]sortKey{
+	SortByFirstName: func(c Customer) string { return c.FirstName },
+	SortByLastName:  func(c Customer) string { return c.LastName },
+	SortByLine:      func(c Customer) string { return c.Address.Line },
+	SortByCity:      func(c Customer) string { return c.Address.City },
+	SortByRegion:    func(c Customer) string { return c.Address.Region },
+	SortByCountry:   func(c Customer) string { return c.Address.Country },
+	SortByMailCode:  func(c Customer) string { return c.Address.MailCode },
+}
+
+// orderEntryOf returns the entry of a customer in the order of a field, case insensitive
+func orderEntryOf(c Customer, field string) orderEntry {
+	e := orderEntry{id: c.ID}
+	if keyFn := sortKeys[field]; keyFn != nil {
+		e.key = strings.ToLower(keyFn(c))
+	}
+
+	return e
+}
+
+// buildSortOrder returns customers ordered by a field, then by id
+func buildSortOrder(customers map[int]Customer, field string) sortOrder {
+	order := make(sortOrder, 0, len(customers))
+	for _, cust := range customers {
+		order = append(order, orderEntryOf(cust, field))
+	}
+
+	sort.Slice(order, func(i, j int) bool { return order[i].less(order[j]) })
+	return order
+}
@@ -0,0 +1,184 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package model
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Query is a search for customers.
+// Name, City, Region and MailCode match customers whose field starts with the value, case insensitive, where Name
+// matches the first name, last name, or full name. Empty values match all customers.
+// Results are ordered by the Sort field (default id) then by id, and the page of results starts at Offset.
+// A Limit of 0 returns all results.
+type Query struct {
+	Name     string
+	City     string
+	Region   string
+	MailCode string
+	Sort     string
+	Desc     bool
+	Offset   int
+	Limit    int
+}
+
+// QueryResult is a page of customers matching a Query, and the total number of matching customers
+type QueryResult struct {
+	Customers []Customer
+	Total     int
+}
+
+// customerIndexes are the prefix indexes of searchable fields, and the sort orders built so far.
+// The indexes and orders are updated in place as customers are added and removed, which requires the model's
+// exclusive lock. Queries share the model's read lock, so ordersMu guards building an order the first time it is
+// needed.
+type customerIndexes struct {
+	name     *prefixIndex
+	city     *prefixIndex
+	region   *prefixIndex
+	mailCode *prefixIndex
+	ordersMu sync.Mutex
+	orders   map[string]sortOrder
+}
+
+// newCustomerIndexes constructs customerIndexes
+func newCustomerIndexes() *customerIndexes {
+	return &customerIndexes{
+		name:     newPrefixIndex(),
+		city:     newPrefixIndex(),
+		region:   newPrefixIndex(),
+		mailCode: newPrefixIndex(),
+		orders:   map[string]sortOrder{},
+	}
+}
+
+// nameKeys returns the keys a customer name is indexed by
+func nameKeys(c Customer) []string {
+	return []string{c.FirstName, c.LastName, strings.TrimSpace(c.FirstName + " " + c.LastName)}
+}
+
+// add indexes a customer
+func (x *customerIndexes) add(c Customer) {
+	x.name.add(c.ID, nameKeys(c)...)
+	x.city.add(c.ID, c.Address.City)
+	x.region.add(c.ID, c.Address.Region)
+	x.mailCode.add(c.ID, c.Address.MailCode)
+
+	for field, order := range x.orders {
+		x.orders[field] = order.insert(orderEntryOf(c, field))
+	}
+}
+
+// remove removes a customer from the indexes
+func (x *customerIndexes) remove(c Customer) {
+	x.name.remove(c.ID, nameKeys(c)...)
+	x.city.remove(c.ID, c.Address.City)
+	x.region.remove(c.ID, c.Address.Region)
+	x.mailCode.remove(c.ID, c.Address.MailCode)
+
+	for field, order := range x.orders {
+		x.orders[field] = order.delete(orderEntryOf(c, field))
+	}
+}
+
+// order returns the order of all customers by a field, building it the first time it is needed
+func (x *customerIndexes) order(customers map[int]Customer, field string) sortOrder {
+	x.ordersMu.Lock()
+	defer x.ordersMu.Unlock()
+
+	order := x.orders[field]
+	if order == nil {
+		order = buildSortOrder(customers, field)
+		x.orders[field] = order
+	}
+
+	return order
+}
+
+// Query returns the page of customers matching a query.
+// Returns an error if the sort field is not a field of Customer or Address.
+func (c *customerModel) Query(q Query) (QueryResult, error) {
+	sortField := q.Sort
+	if sortField == "" {
+		sortField = SortByID
+	}
+
+	if _, valid := sortKeys[sortField]; !valid && (sortField != SortByID) {
+		return QueryResult{}, fmt.Errorf("Cannot sort by %q", q.Sort)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// Intersect the ids matching each search field, where nil means all ids match
+	var candidates map[int]bool
+	for _, search := range []struct {
+		index  *prefixIndex
+		prefix string
+	}{
+		{c.indexes.name, q.Name},
+		{c.indexes.city, q.City},
+		{c.indexes.region, q.Region},
+		{c.indexes.mailCode, q.MailCode},
+	} {
+		if search.prefix == "" {
+			continue
+		}
+
+		matches := search.index.match(search.prefix)
+		if candidates == nil {
+			candidates = matches
+			continue
+		}
+
+		for id := range candidates {
+			if !matches[id] {
+				delete(candidates, id)
+			}
+		}
+	}
+
+	// Order the matching ids: a small number of matches is sorted directly, otherwise the full sort order is filtered
+	var ids []int
+	if (candidates != nil) && (len(candidates) < len(c.customers)/8) {
+		all := make(map[int]Customer, len(candidates))
+		for id := range candidates {
+			all[id] = c.customers[id]
+		}
+
+		ids = buildSortOrder(all, sortField).ids()
+	} else {
+		order := c.indexes.order(c.customers, sortField)
+		if candidates == nil {
+			ids = order.ids()
+		} else {
+			ids = make([]int, 0, len(candidates))
+			for _, e := range order {
+				if candidates[e.id] {
+					ids = append(ids, e.id)
+				}
+			}
+		}
+	}
+
+	// Select the page, reading the ids backwards for a descending order
+	result := QueryResult{Total: len(ids)}
+	for i := q.Offset; (i >= 0) && (i < len(ids)) && ((q.Limit <= 0) || (len(result.Customers) < q.Limit)); i++ {
+		id := ids[i]
+		if q.Desc {
+			id = ids[len(ids)-1-i]
+		}
+
+		result.Customers = append(result.Customers, c.customers[id])
+	}
+
+	return result, nil
+}
+
+// Customers returns all customers, ordered by id
+func (c *customerModel) Customers() []Customer {
+	result, _ := c.Query(Query{})
+	return result.Customers
+}
@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// cities are the cities of test customers
+var cities = []string{"Toronto", "berlin", "Austin", "Boston", "austin", "Toledo"}
+
+// newTestModel returns a model with n customers
+func newTestModel(n int) *Model {
+	m := NewModel()
+	for i := 0; i < n; i++ {
+		m.SetCustomer(Customer{
+			FirstName: fmt.Sprintf("First%02d", n-i),
+			LastName:  fmt.Sprintf("Last%d", i%3),
+			Address:   Address{City: cities[i%len(cities)]},
+		})
+	}
+
+	return m
+}
+
+// queryIDs returns the ids of the customers matching a query
+func queryIDs(t *testing.T, m *Model, q Query) []int {
+	t.Helper()
+
+	result, err := m.Query(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []int{}
+	for _, cust := range result.Customers {
+		ids = append(ids, cust.ID)
+	}
+
+	return ids
+}
+
+// assertOrders fails the test unless the cached sort orders are the same as orders built from scratch
+func assertOrders(t *testing.T, m *Model) {
+	t.Helper()
+
+	for field, order := range m.indexes.orders {
+		if expected := buildSortOrder(m.customers, field); !reflect.DeepEqual(order, expected) {
+			t.Fatalf("order by %s is %v, expected %v", field, order, expected)
+		}
+	}
+}
+
+func TestQueryOrdersUpdatedInPlace(t *testing.T) {
+	m := newTestModel(50)
+
+	for _, field := range []string{SortByID, SortByCity, SortByFirstName} {
+		queryIDs(t, m, Query{Sort: field})
+	}
+	if len(m.indexes.orders) != 3 {
+		t.Fatalf("expected 3 cached orders, got %d", len(m.indexes.orders))
+	}
+
+	// Update and add customers: the cached orders are kept, and stay sorted
+	m.SetCustomer(Customer{ID: 7, FirstName: "Aaron", Address: Address{City: "Zurich"}})
+	m.SetCustomer(Customer{ID: 8, FirstName: "zed", Address: Address{City: "austin"}})
+	m.SetCustomer(Customer{FirstName: "New", Address: Address{City: "Boston"}})
+	if len(m.indexes.orders) != 3 {
+		t.Fatalf("expected 3 cached orders, got %d", len(m.indexes.orders))
+	}
+	assertOrders(t, m)
+
+	ids := queryIDs(t, m, Query{Sort: SortByCity, Desc: true, Limit: 1})
+	if !reflect.DeepEqual(ids, []int{7}) {
+		t.Fatalf("expected Zurich first, got %v", ids)
+	}
+
+	ids = queryIDs(t, m, Query{Sort: SortByFirstName, Limit: 1})
+	if !reflect.DeepEqual(ids, []int{7}) {
+		t.Fatalf("expected Aaron first, got %v", ids)
+	}
+}
+
+func TestQueryPrefixIndexUpdated(t *testing.T) {
+	m := newTestModel(12)
+
+	if ids := queryIDs(t, m, Query{City: "tor"}); !reflect.DeepEqual(ids, []int{1, 7}) {
+		t.Fatalf("expected [1 7], got %v", ids)
+	}
+
+	// Moving customer 1 out of Toronto removes it from the index, and the last Toronto customer removes the key
+	m.SetCustomer(Customer{ID: 1, FirstName: "Moved", Address: Address{City: "Ottawa"}})
+	if ids := queryIDs(t, m, Query{City: "tor"}); !reflect.DeepEqual(ids, []int{7}) {
+		t.Fatalf("expected [7], got %v", ids)
+	}
+
+	m.SetCustomer(Customer{ID: 7, FirstName: "Moved", Address: Address{City: "Ottawa"}})
+	if ids := queryIDs(t, m, Query{City: "tor"}); len(ids) != 0 {
+		t.Fatalf("expected no customers, got %v", ids)
+	}
+	for _, key := range m.indexes.city.keys {
+		if key == "toronto" {
+			t.Fatal("toronto is still a key")
+		}
+	}
+
+	if ids := queryIDs(t, m, Query{City: "OTT", Name: "mov"}); !reflect.DeepEqual(ids, []int{1, 7}) {
+		t.Fatalf("expected [1 7], got %v", ids)
+	}
+}
+
+func TestQueryConcurrent(t *testing.T) {
+	var (
+		m  = newTestModel(100)
+		wg sync.WaitGroup
+	)
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 50; j++ {
+				if i == 0 {
+					m.SetCustomer(Customer{ID: j + 1, FirstName: fmt.Sprint("Renamed", j), Address: Address{City: cities[j%len(cities)]}})
+					continue
+				}
+
+				if _, err := m.Query(Query{Sort: []string{SortByCity, SortByFirstName, SortByLastName}[j%3], Offset: j}); err != nil {
+					t.Error(err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assertOrders(t, m)
+}
@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package view
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Address field names, as used in forms and address formats
+const (
+	lineField     = "line"
+	cityField     = "city"
+	regionField   = "region"
+	countryField  = "country"
+	mailCodeField = "mailCode"
+)
+
+// addressFieldNames are all address fields, in the default order
+var addressFieldNames = []string{lineField, cityField, regionField, mailCodeField, countryField}
+
+// countryCodes maps the ways a country may be written, in lower case, to the ISO 3166 code
+var countryCodes = map[string]string{
+	"us":                       "US",
+	"usa":                      "US",
+	"united states":            "US",
+	"united states of america": "US",
+	"ca":                       "CA",
+	"canada":                   "CA",
+	"gb":                       "GB",
+	"uk":                       "GB",
+	"united kingdom":           "GB",
+	"great britain":            "GB",
+	"de":                       "DE",
+	"germany":                  "DE",
+	"deutschland":              "DE",
+	"fr":                       "FR",
+	"france":                   "FR",
+	"jp":                       "JP",
+	"japan":                    "JP",
+}
+
+// CountryCode returns the ISO 3166 code of a country, or an empty string if the country is not known
+func CountryCode(country string) string {
+	return countryCodes[strings.ToLower(strings.TrimSpace(country))]
+}
+
+// addressFormats contains the lines of an address in each country, keyed by ISO 3166 code.
+// Each {field} is replaced by the value of the field, and lines whose fields are all empty are omitted.
+var addressFormats = map[string][]string{
+	"US": {"{line}", "{city}, {region} {mailCode}", "{country}"},
+	"CA": {"{line}", "{city} {region} {mailCode}", "{country}"},
+	"GB": {"{line}", "{city}", "{region}", "{mailCode}", "{country}"},
+	"DE": {"{line}", "{mailCode} {city}", "{country}"},
+	"FR": {"{line}", "{mailCode} {city}", "{country}"},
+	"JP": {"〒{mailCode}", "{region}{city}", "{line}", "{country}"},
+}
+
+// defaultAddressFormat is the format of addresses in countries with no specific format
+var defaultAddressFormat = []string{"{line}", "{city}", "{region} {mailCode}", "{country}"}
+
+// addressFieldRegex matches a {field} of an address format
+var addressFieldRegex = regexp.MustCompile(`\{(\w+)\}`)
+
+// addressFormat returns the format of addresses in a country
+func addressFormat(country string) []string {
+	if format, have := addressFormats[CountryCode(country)]; have {
+		return format
+	}
+
+	return defaultAddressFormat
+}
+
+// addressValue returns the value of an address field
+func addressValue(a Address, field string) string {
+	return map[string]string{
+		lineField:     a.Line,
+		cityField:     a.City,
+		regionField:   a.Region,
+		countryField:  a.Country,
+		mailCodeField: a.MailCode,
+	}[field]
+}
+
+// FormatAddress returns the lines of an address, formatted for the country of the address
+func FormatAddress(a Address) []string {
+	var lines []string
+	for _, format := range addressFormat(a.Country) {
+		var hasValue bool
+		line := addressFieldRegex.ReplaceAllStringFunc(format, func(field string) string {
+			value := strings.TrimSpace(addressValue(a, field[1:len(field)-1]))
+			hasValue = hasValue || (value != "")
+			return value
+		})
+
+		if hasValue {
+			// Remove the separators of empty fields
+			line = strings.Join(strings.Fields(line), " ")
+			line = strings.Trim(strings.ReplaceAll(line, " ,", ","), ", ")
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+// addressFields returns the fields of a form to enter an address, in the order of the address format of the country,
+// with labels for the country in the given language
+func addressFields(lang string, a Address, errs map[string]string) []field {
+	var (
+		names   []string
+		seen    = map[string]bool{countryField: true}
+		country = -1
+	)
+
+	for _, format := range addressFormat(a.Country) {
+		for _, match := range addressFieldRegex.FindAllStringSubmatch(format, -1) {
+			switch name := match[1]; {
+			case (name == countryField) && (country < 0):
+				country = len(names)
+			case !seen[name]:
+				names = append(names, name)
+				seen[name] = true
+			}
+		}
+	}
+
+	// Fields not in the format go before the country, which is last if it is not in the format
+	if country < 0 {
+		country = len(names)
+	}
+
+	ordered := append([]string{}, names[:country]...)
+	for _, name := range addressFieldNames {
+		if !seen[name] {
+			ordered = append(ordered, name)
+		}
+	}
+	ordered = append(ordered, countryField)
+	ordered = append(ordered, names[country:]...)
+
+	fields := make([]field, len(ordered))
+	for i, name := range ordered {
+		fields[i] = field{
+			Label: tCountry(lang, "field."+name, a.Country),
+			Name:  name,
+			Value: addressValue(a, name),
+			Error: errs[name],
+		}
+	}
+
+	return fields
+}
@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package view
+
+import (
+	"reflect"
+	"testing"
+)
+
+// fieldNames returns the names of the address fields for a country
+func fieldNames(country string) []string {
+	var names []string
+	for _, f := range addressFields("en", Address{Country: country}, nil) {
+		names = append(names, f.Name)
+	}
+
+	return names
+}
+
+func TestAddressFieldsOrder(t *testing.T) {
+	for country, want := range map[string][]string{
+		"USA":   {lineField, cityField, regionField, mailCodeField, countryField},
+		"UK":    {lineField, cityField, regionField, mailCodeField, countryField},
+		"Japan": {mailCodeField, regionField, cityField, lineField, countryField},
+		// Germany has no region, so it goes before the country
+		"Germany": {lineField, mailCodeField, cityField, regionField, countryField},
+		"Narnia":  {lineField, cityField, regionField, mailCodeField, countryField},
+	} {
+		if got := fieldNames(country); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: fields %v, want %v", country, got, want)
+		}
+	}
+}
+
+func TestAddressFieldLabels(t *testing.T) {
+	for _, test := range []struct {
+		lang, country, field, want string
+	}{
+		{"en", "USA", regionField, "State"},
+		{"en", "canada", mailCodeField, "Postal Code"},
+		{"en", "Narnia", regionField, "Region"},
+		{"de", "Narnia", lineField, T("de", "field.line")},
+	} {
+		for _, f := range addressFields(test.lang, Address{Country: test.country}, nil) {
+			if (f.Name == test.field) && (f.Label != test.want) {
+				t.Errorf("%s %s %s: label %q, want %q", test.lang, test.country, test.field, f.Label, test.want)
+			}
+		}
+	}
+}
+
+func TestFormatAddress(t *testing.T) {
+	for _, test := range []struct {
+		address Address
+		want    []string
+	}{
+		{
+			Address{Line: "123 Sesame St", City: "New York", Region: "NY", Country: "USA", MailCode: "12345"},
+			[]string{"123 Sesame St", "New York, NY 12345", "USA"},
+		},
+		{
+			// The separators of empty fields are removed
+			Address{Line: "123 Sesame St", City: "New York", Country: "United States"},
+			[]string{"123 Sesame St", "New York", "United States"},
+		},
+		{
+			Address{Line: "Unter den Linden 1", City: "Berlin", Country: "Deutschland", MailCode: "10117"},
+			[]string{"Unter den Linden 1", "10117 Berlin", "Deutschland"},
+		},
+		{
+			Address{Line: "1-1 Chiyoda", City: "Chiyoda-ku", Region: "Tokyo", Country: "Japan", MailCode: "100-0001"},
+			[]string{"〒100-0001", "TokyoChiyoda-ku", "1-1 Chiyoda", "Japan"},
+		},
+		{
+			// Lines whose fields are all empty are omitted
+			Address{Line: "10 Downing St", City: "London", Country: "UK", MailCode: "SW1A 2AA"},
+			[]string{"10 Downing St", "London", "SW1A 2AA", "UK"},
+		},
+		{
+			Address{Line: "1 Lamppost Way", City: "Cair Paravel", Region: "East", Country: "Narnia", MailCode: "N1"},
+			[]string{"1 Lamppost Way", "Cair Paravel", "East N1", "Narnia"},
+		},
+		{Address{}, nil},
+	} {
+		if got := FormatAddress(test.address); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("FormatAddress(%+v) = %q, want %q", test.address, got, test.want)
+		}
+	}
+}
+
+func TestNegotiateLanguage(t *testing.T) {
+	for accept, want := range map[string]string{
+		"":                       DefaultLanguage,
+		"fr":                     "fr",
+		"de-DE":                  "de",
+		"FR-ca, de":              "fr",
+		"es, de;q=0.5":           "de",
+		"es, it":                 DefaultLanguage,
+		"fr;q=0.5, de;q=0.8":     "de",
+		"fr;q=0.5, de;q=0.5":     "fr",
+		"fr;q=0, de;q=0.1":       "de",
+		"fr;q=abc":               DefaultLanguage,
+		"de; q=0.5 , fr ; q=0.9": "fr",
+		"*":                      DefaultLanguage,
+		"de;q=0.5, *":            DefaultLanguage,
+		"de, *;q=0.5":            "de",
+		"en;q=0.1, fr;q=0.5, *":  "fr",
+		"de;q=0, *;q=0.1":        DefaultLanguage,
+		"*;q=0, fr;q=0.2":        "fr",
+	} {
+		if got := NegotiateLanguage(accept); got != want {
+			t.Errorf("NegotiateLanguage(%q) = %q, want %q", accept, got, want)
+		}
+	}
+}
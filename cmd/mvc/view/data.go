@@ -2,6 +2,11 @@
 
 package view
 
+import (
+	"net/url"
+	"strconv"
+)
+
 type Customer struct {
 	ID        int
 	FirstName string
@@ -16,3 +21,102 @@ type Address struct {
 	Country  string
 	MailCode string
 }
+
+// CustomerForm is a Customer being edited in a form.
+// Action is the URL the form is submitted to, and is empty if the form is read only.
+// Errors contains a message for each invalid field, keyed by field name.
+// EventsURL is the URL of a stream of changes to the customer, and is empty if the form is not updated live.
+// Lang is the language the form is displayed in.
+type CustomerForm struct {
+	Customer
+	Lang      string
+	Action    string
+	CSRFToken string
+	Errors    map[string]string
+	Saved     bool
+	EventsURL string
+}
+
+// CustomerSearch is a search for customers, as entered in the search form of a list of customers
+type CustomerSearch struct {
+	Name     string
+	City     string
+	Region   string
+	MailCode string
+	Sort     string
+	Desc     bool
+	Size     int
+}
+
+// values returns the search as URL query parameters, omitting empty values
+func (s CustomerSearch) values() url.Values {
+	values := url.Values{}
+	for name, value := range map[string]string{
+		"name":     s.Name,
+		"city":     s.City,
+		"region":   s.Region,
+		"mailCode": s.MailCode,
+		"sort":     s.Sort,
+	} {
+		if value != "" {
+			values.Set(name, value)
+		}
+	}
+
+	if s.Desc {
+		values.Set("desc", "1")
+	}
+
+	if s.Size > 0 {
+		values.Set("size", strconv.Itoa(s.Size))
+	}
+
+	return values
+}
+
+// CustomerList is one page of the customers matching a search.
+// Path is the URL path of the list, which links to other pages and sort orders are relative to.
+// Lang is the language the list is displayed in.
+type CustomerList struct {
+	Customers []Customer
+	Lang      string
+	Search    CustomerSearch
+	Total     int
+	Page      int
+	Pages     int
+	Path      string
+}
+
+// PageURL returns the URL of a page of the list
+func (l CustomerList) PageURL(page int) string {
+	values := l.Search.values()
+	if page > 1 {
+		values.Set("page", strconv.Itoa(page))
+	}
+
+	return (&url.URL{Path: l.Path, RawQuery: values.Encode()}).String()
+}
+
+// SortURL returns the URL of the first page of the list sorted by a field.
+// If the list is already sorted ascending by the field, the URL sorts descending.
+func (l CustomerList) SortURL(field string) string {
+	search := l.Search
+	search.Desc = (search.Sort == field) && !search.Desc
+	search.Sort = field
+
+	return (&url.URL{Path: l.Path, RawQuery: search.values().Encode()}).String()
+}
+
+// PageNumbers returns the numbers of the pages near the current page, for links to them
+func (l CustomerList) PageNumbers() []int {
+	const near = 4
+
+	var numbers []int
+	for page := l.Page - near; page <= l.Page+near; page++ {
+		if (page >= 1) && (page <= l.Pages) {
+			numbers = append(numbers, page)
+		}
+	}
+
+	return numbers
+}
@@ -0,0 +1,236 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package view
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage is the language used when no supported language is acceptable, and for messages that have not been
+// translated
+const DefaultLanguage = "en"
+
+// catalogs contains the messages of each supported language, keyed by message id.
+// Messages may contain fmt verbs for arguments.
+// A message id of the form id.CC is the message for addresses in the country with ISO code CC.
+var catalogs = map[string]map[string]string{
+	"en": {
+		"title.customer":        "Customer",
+		"title.customers":       "Customers",
+		"link.allCustomers":     "All customers",
+		"link.newCustomer":      "New customer",
+		"msg.saved":             "Customer saved.",
+		"msg.changed":           "This customer has been changed by someone else.",
+		"msg.page":              "%d customers, page %d of %d",
+		"msg.none":              "There are no matching customers.",
+		"legend.customer":       "Customer",
+		"legend.address":        "Address",
+		"legend.mailingAddress": "Mailing Address",
+		"legend.search":         "Search",
+		"field.id":              "ID",
+		"field.name":            "Name",
+		"field.firstName":       "First Name",
+		"field.lastName":        "Last Name",
+		"field.line":            "Line",
+		"field.city":            "City",
+		"field.region":          "Region",
+		"field.region.US":       "State",
+		"field.region.CA":       "Province",
+		"field.region.GB":       "County",
+		"field.region.JP":       "Prefecture",
+		"field.country":         "Country",
+		"field.mailCode":        "Mail Code",
+		"field.mailCode.US":     "ZIP Code",
+		"field.mailCode.CA":     "Postal Code",
+		"field.mailCode.GB":     "Postcode",
+		"field.mailCode.DE":     "Postal Code",
+		"field.mailCode.FR":     "Postal Code",
+		"field.mailCode.JP":     "Postal Code",
+		"button.save":           "Save",
+		"button.search":         "Search",
+		"nav.first":             "First",
+		"nav.previous":          "Previous",
+		"nav.next":              "Next",
+		"nav.last":              "Last",
+		"error.required":        "Required",
+		"error.tooLong":         "Cannot be longer than %d characters",
+		"error.mailCode":        "Must be 2 to 10 letters, digits, spaces or dashes",
+		"text.customer":         "Customer %d",
+		"text.name":             "Name",
+		"text.address":          "Address",
+		"text.page":             "%d customers, page %d of %d",
+	},
+	"fr": {
+		"title.customer":        "Client",
+		"title.customers":       "Clients",
+		"link.allCustomers":     "Tous les clients",
+		"link.newCustomer":      "Nouveau client",
+		"msg.saved":             "Client enregistré.",
+		"msg.changed":           "Ce client a été modifié par quelqu'un d'autre.",
+		"msg.page":              "%d clients, page %d sur %d",
+		"msg.none":              "Aucun client ne correspond.",
+		"legend.customer":       "Client",
+		"legend.address":        "Adresse",
+		"legend.mailingAddress": "Adresse postale",
+		"legend.search":         "Recherche",
+		"field.id":              "ID",
+		"field.name":            "Nom",
+		"field.firstName":       "Prénom",
+		"field.lastName":        "Nom",
+		"field.line":            "Adresse",
+		"field.city":            "Ville",
+		"field.region":          "Région",
+		"field.region.US":       "État",
+		"field.region.CA":       "Province",
+		"field.region.GB":       "Comté",
+		"field.region.JP":       "Préfecture",
+		"field.country":         "Pays",
+		"field.mailCode":        "Code postal",
+		"field.mailCode.US":     "Code ZIP",
+		"button.save":           "Enregistrer",
+		"button.search":         "Rechercher",
+		"nav.first":             "Première",
+		"nav.previous":          "Précédente",
+		"nav.next":              "Suivante",
+		"nav.last":              "Dernière",
+		"error.required":        "Obligatoire",
+		"error.tooLong":         "Ne peut pas dépasser %d caractères",
+		"error.mailCode":        "Doit contenir de 2 à 10 lettres, chiffres, espaces ou tirets",
+		"text.customer":         "Client %d",
+		"text.name":             "Nom",
+		"text.address":          "Adresse",
+		"text.page":             "%d clients, page %d sur %d",
+	},
+	"de": {
+		"title.customer":        "Kunde",
+		"title.customers":       "Kunden",
+		"link.allCustomers":     "Alle Kunden",
+		"link.newCustomer":      "Neuer Kunde",
+		"msg.saved":             "Kunde gespeichert.",
+		"msg.changed":           "Dieser Kunde wurde von jemand anderem geändert.",
+		"msg.page":              "%d Kunden, Seite %d von %d",
+		"msg.none":              "Keine passenden Kunden.",
+		"legend.customer":       "Kunde",
+		"legend.address":        "Adresse",
+		"legend.mailingAddress": "Postanschrift",
+		"legend.search":         "Suche",
+		"field.id":              "ID",
+		"field.name":            "Name",
+		"field.firstName":       "Vorname",
+		"field.lastName":        "Nachname",
+		"field.line":            "Straße",
+		"field.city":            "Ort",
+		"field.region":          "Region",
+		"field.region.US":       "Bundesstaat",
+		"field.region.CA":       "Provinz",
+		"field.region.GB":       "Grafschaft",
+		"field.region.JP":       "Präfektur",
+		"field.region.DE":       "Bundesland",
+		"field.country":         "Land",
+		"field.mailCode":        "Postleitzahl",
+		"field.mailCode.US":     "ZIP-Code",
+		"button.save":           "Speichern",
+		"button.search":         "Suchen",
+		"nav.first":             "Erste",
+		"nav.previous":          "Vorherige",
+		"nav.next":              "Nächste",
+		"nav.last":              "Letzte",
+		"error.required":        "Erforderlich",
+		"error.tooLong":         "Darf nicht länger als %d Zeichen sein",
+		"error.mailCode":        "Muss 2 bis 10 Buchstaben, Ziffern, Leerzeichen oder Bindestriche enthalten",
+		"text.customer":         "Kunde %d",
+		"text.name":             "Name",
+		"text.address":          "Adresse",
+		"text.page":             "%d Kunden, Seite %d von %d",
+	},
+}
+
+// T returns the message with the given id in a language, formatted with any arguments.
+// Messages that are not translated into the language are in the default language.
+// Unknown message ids are returned as is.
+func T(lang, id string, args ...interface{}) string {
+	msg, have := catalogs[lang][id]
+	if !have {
+		if msg, have = catalogs[DefaultLanguage][id]; !have {
+			return id
+		}
+	}
+
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+
+	return msg
+}
+
+// tCountry returns the message with the given id for addresses in a country, if there is one, otherwise the message
+// with the given id
+func tCountry(lang, id, country string) string {
+	if code := CountryCode(country); code != "" {
+		countryID := id + "." + code
+		if msg := T(lang, countryID); msg != countryID {
+			return msg
+		}
+	}
+
+	return T(lang, id)
+}
+
+// NegotiateLanguage returns the supported language most preferred by an Accept-Language header value, or the default
+// language if no supported language is acceptable.
+// A language range such as fr-CA matches the supported language fr, and the range * matches the default language,
+// unless the default language has its own range.
+func NegotiateLanguage(acceptLanguage string) string {
+	type choice struct {
+		lang string
+		q    float64
+	}
+
+	var (
+		ranges   []choice
+		explicit = map[string]bool{}
+	)
+
+	for _, lr := range strings.Split(acceptLanguage, ",") {
+		parts := strings.Split(strings.TrimSpace(lr), ";")
+		q := 1.0
+		for _, param := range parts[1:] {
+			if param = strings.TrimSpace(param); strings.HasPrefix(param, "q=") {
+				var err error
+				if q, err = strconv.ParseFloat(param[2:], 64); err != nil {
+					q = 0
+				}
+			}
+		}
+
+		lang := strings.ToLower(strings.TrimSpace(parts[0]))
+		if i := strings.IndexByte(lang, '-'); i >= 0 {
+			lang = lang[:i]
+		}
+
+		ranges = append(ranges, choice{lang: lang, q: q})
+		explicit[lang] = true
+	}
+
+	var choices []choice
+	for _, c := range ranges {
+		if (c.lang == "*") && !explicit[DefaultLanguage] {
+			c.lang = DefaultLanguage
+		}
+
+		if _, supported := catalogs[c.lang]; supported && (c.q > 0) {
+			choices = append(choices, c)
+		}
+	}
+
+	// Stable sort, so the first language wins ties
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+	if len(choices) == 0 {
+		return DefaultLanguage
+	}
+
+	return choices[0].lang
+}
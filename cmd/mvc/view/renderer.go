@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package view
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Renderer renders customers in one format.
+// New formats can be added to a View with View.WithRenderer.
+type Renderer interface {
+	// ContentType is the Content-Type header value of the rendered output
+	ContentType() string
+
+	// RenderCustomer renders one customer, with any validation errors
+	RenderCustomer(w io.Writer, f CustomerForm) error
+
+	// RenderCustomers renders a page of a list of customers
+	RenderCustomers(w io.Writer, l CustomerList) error
+}
+
+// HTML page names
+const (
+	customerPage  = "customer.html"
+	customersPage = "customers.html"
+)
+
+// htmlRenderer renders HTML pages
+type htmlRenderer struct {
+	templates *Templates
+}
+
+// ContentType is Renderer for htmlRenderer
+func (htmlRenderer) ContentType() string {
+	return "text/html; charset=utf-8"
+}
+
+// RenderCustomer is Renderer for htmlRenderer
+func (r htmlRenderer) RenderCustomer(w io.Writer, f CustomerForm) error {
+	return r.templates.Execute(w, customerPage, f)
+}
+
+// RenderCustomers is Renderer for htmlRenderer
+func (r htmlRenderer) RenderCustomers(w io.Writer, l CustomerList) error {
+	return r.templates.Execute(w, customersPage, l)
+}
+
+// jsonRenderer renders JSON.
+// A customer with validation errors is rendered as an object of errors keyed by field name.
+type jsonRenderer struct{}
+
+// ContentType is Renderer for jsonRenderer
+func (jsonRenderer) ContentType() string {
+	return "application/json"
+}
+
+// RenderCustomer is Renderer for jsonRenderer
+func (jsonRenderer) RenderCustomer(w io.Writer, f CustomerForm) error {
+	if len(f.Errors) > 0 {
+		return json.NewEncoder(w).Encode(struct{ Errors map[string]string }{f.Errors})
+	}
+
+	return json.NewEncoder(w).Encode(f.Customer)
+}
+
+// RenderCustomers is Renderer for jsonRenderer
+func (jsonRenderer) RenderCustomers(w io.Writer, l CustomerList) error {
+	page := struct {
+		Customers []Customer
+		Total     int
+		Page      int
+		Pages     int
+	}{l.Customers, l.Total, l.Page, l.Pages}
+
+	if page.Customers == nil {
+		page.Customers = []Customer{}
+	}
+
+	return json.NewEncoder(w).Encode(page)
+}
+
+// csvHeader is the header row of customers rendered as CSV
+var csvHeader = []string{"ID", "FirstName", "LastName", "Line", "City", "Region", "Country", "MailCode"}
+
+// csvRenderer renders CSV with a header row.
+// A customer with validation errors is rendered as rows of field name and error.
+type csvRenderer struct{}
+
+// ContentType is Renderer for csvRenderer
+func (csvRenderer) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+// RenderCustomer is Renderer for csvRenderer
+func (r csvRenderer) RenderCustomer(w io.Writer, f CustomerForm) error {
+	if len(f.Errors) > 0 {
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"Field", "Error"})
+		for _, field := range sortedKeys(f.Errors) {
+			cw.Write([]string{field, f.Errors[field]})
+		}
+		cw.Flush()
+
+		return cw.Error()
+	}
+
+	return r.RenderCustomers(w, CustomerList{Customers: []Customer{f.Customer}})
+}
+
+// RenderCustomers is Renderer for csvRenderer
+func (csvRenderer) RenderCustomers(w io.Writer, l CustomerList) error {
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	for _, c := range l.Customers {
+		cw.Write([]string{
+			strconv.Itoa(c.ID),
+			c.FirstName,
+			c.LastName,
+			c.Address.Line,
+			c.Address.City,
+			c.Address.Region,
+			c.Address.Country,
+			c.Address.MailCode,
+		})
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// textRenderer renders plain text for humans
+type textRenderer struct{}
+
+// ContentType is Renderer for textRenderer
+func (textRenderer) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+// RenderCustomer is Renderer for textRenderer
+func (textRenderer) RenderCustomer(w io.Writer, f CustomerForm) error {
+	var sb strings.Builder
+	if len(f.Errors) > 0 {
+		for _, field := range sortedKeys(f.Errors) {
+			fmt.Fprintf(&sb, "%s: %s\n", field, f.Errors[field])
+		}
+	} else {
+		var (
+			nameLabel    = T(f.Lang, "text.name")
+			addressLabel = T(f.Lang, "text.address")
+			width        = len([]rune(nameLabel))
+		)
+		if n := len([]rune(addressLabel)); n > width {
+			width = n
+		}
+
+		fmt.Fprintln(&sb, T(f.Lang, "text.customer", f.ID))
+		fmt.Fprintf(&sb, "  %-*s %s %s\n", width+1, nameLabel+":", f.FirstName, f.LastName)
+		for i, line := range FormatAddress(f.Address) {
+			if i == 0 {
+				fmt.Fprintf(&sb, "  %-*s %s\n", width+1, addressLabel+":", line)
+			} else {
+				fmt.Fprintf(&sb, "  %-*s %s\n", width+1, "", line)
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// RenderCustomers is Renderer for textRenderer
+func (textRenderer) RenderCustomers(w io.Writer, l CustomerList) error {
+	var sb strings.Builder
+	for _, c := range l.Customers {
+		fmt.Fprintf(&sb, "%d\t%s %s\t%s\n", c.ID, c.FirstName, c.LastName, textAddress(c.Address))
+	}
+	fmt.Fprintln(&sb, T(l.Lang, "text.page", l.Total, l.Page, l.Pages))
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// textAddress renders an address as a single line, by joining the lines of the address formatted for its country
+func textAddress(a Address) string {
+	return strings.Join(FormatAddress(a), ", ")
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
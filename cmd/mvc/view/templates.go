@@ -0,0 +1,210 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package view
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"sync"
+	"time"
+)
+
+// Template file constants.
+// Every page is parsed together with the layout and all partials, and is executed by executing the layout.
+const (
+	layoutFile     = "layout.html"
+	layoutTemplate = "layout"
+	partialsGlob   = "partials/*.html"
+	pagesGlob      = "*.html"
+)
+
+// assets are the compiled in templates
+//
+//go:embed templates
+var assets embed.FS
+
+// AssetTemplates returns the compiled in templates
+func AssetTemplates() fs.FS {
+	fsys, err := fs.Sub(assets, "templates")
+	if err != nil {
+		panic(err)
+	}
+
+	return fsys
+}
+
+// field is the data of the field partial
+type field struct {
+	Label string
+	Name  string
+	Value string
+	Error string
+}
+
+// templateFuncs are the functions available to all templates
+var templateFuncs = template.FuncMap{
+	"add": func(a, b int) int {
+		return a + b
+	},
+	"field": func(label, name, value, err string) field {
+		return field{Label: label, Name: name, Value: value, Error: err}
+	},
+	"t":             T,
+	"addressLines":  FormatAddress,
+	"addressFields": addressFields,
+}
+
+// page is a parsed page, and the modification times of the files it was parsed from
+type page struct {
+	tmpl     *template.Template
+	modTimes map[string]time.Time
+}
+
+// Templates loads page templates from a file system, such as a directory (os.DirFS) or the compiled in templates
+// (AssetTemplates). Each page is parsed with the layout and partials the first time it is used.
+//
+// If reload is enabled, a page is parsed again whenever any of its files have changed, which is useful when
+// developing templates.
+//
+// It is safe for concurrent use.
+type Templates struct {
+	fsys   fs.FS
+	reload bool
+	mu     sync.Mutex
+	pages  map[string]*page
+}
+
+// NewTemplates constructs Templates that loads from a file system
+func NewTemplates(fsys fs.FS) *Templates {
+	return &Templates{
+		fsys:  fsys,
+		pages: map[string]*page{},
+	}
+}
+
+// WithReload enables or disables reloading of changed templates
+func (t *Templates) WithReload(reload bool) *Templates {
+	t.reload = reload
+	return t
+}
+
+// files returns the names of the files a page is parsed from
+func (t *Templates) files(name string) ([]string, error) {
+	partials, err := fs.Glob(t.fsys, partialsGlob)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(append([]string{layoutFile}, partials...), name), nil
+}
+
+// modTimes returns the modification times of files
+func (t *Templates) modTimes(files []string) (map[string]time.Time, error) {
+	modTimes := map[string]time.Time{}
+	for _, file := range files {
+		info, err := fs.Stat(t.fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		modTimes[file] = info.ModTime()
+	}
+
+	return modTimes, nil
+}
+
+// changed returns true if the files of a page are not the same files with the same modification times
+func changed(p *page, modTimes map[string]time.Time) bool {
+	if len(p.modTimes) != len(modTimes) {
+		return true
+	}
+
+	for file, modTime := range modTimes {
+		if prevModTime, have := p.modTimes[file]; !have || !prevModTime.Equal(modTime) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Load returns a parsed page, parsing it if it has not been parsed yet, or if reload is enabled and it has changed.
+// If reload is enabled and the page cannot be parsed again, the error is returned, and the previous version is kept.
+// Pages are parsed without holding the lock, so that other pages can be used meanwhile.
+func (t *Templates) Load(name string) (*template.Template, error) {
+	t.mu.Lock()
+	p, loaded := t.pages[name]
+	t.mu.Unlock()
+
+	if loaded && !t.reload {
+		return p.tmpl, nil
+	}
+
+	files, err := t.files(name)
+	if err != nil {
+		return nil, fmt.Errorf("Template %s: %w", name, err)
+	}
+
+	modTimes, err := t.modTimes(files)
+	if err != nil {
+		return nil, fmt.Errorf("Template %s: %w", name, err)
+	}
+
+	if loaded && !changed(p, modTimes) {
+		return p.tmpl, nil
+	}
+
+	tmpl, err := template.New(path.Base(name)).Funcs(templateFuncs).ParseFS(t.fsys, files...)
+	if err != nil {
+		return nil, fmt.Errorf("Template %s: %w", name, err)
+	}
+
+	if tmpl.Lookup(layoutTemplate) == nil {
+		return nil, fmt.Errorf("Template %s: %s does not define %q", name, layoutFile, layoutTemplate)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Another caller may have parsed the same files meanwhile, in which case its page is used
+	if p, loaded := t.pages[name]; loaded && !changed(p, modTimes) {
+		return p.tmpl, nil
+	}
+
+	t.pages[name] = &page{tmpl: tmpl, modTimes: modTimes}
+	return tmpl, nil
+}
+
+// LoadAll loads every page, so that errors can be reported on startup rather than when a page is first used
+func (t *Templates) LoadAll() error {
+	names, err := fs.Glob(t.fsys, pagesGlob)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if name == layoutFile {
+			continue
+		}
+
+		if _, err := t.Load(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Execute executes a page, by executing the layout it was parsed with
+func (t *Templates) Execute(w io.Writer, name string, data interface{}) error {
+	tmpl, err := t.Load(name)
+	if err != nil {
+		return err
+	}
+
+	return tmpl.ExecuteTemplate(w, layoutTemplate, data)
+}
@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package view
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// testLayout is a layout that executes the title and body of a page
+const testLayout = `{{define "layout"}}{{template "title" .}}: {{template "body" .}}{{end}}`
+
+// newTestFS returns a file system with a layout, a partial and one page, all modified at the given time
+func newTestFS(page string, modTime time.Time) fstest.MapFS {
+	return fstest.MapFS{
+		layoutFile:               {Data: []byte(testLayout), ModTime: modTime},
+		"partials/greeting.html": {Data: []byte(`{{define "greeting"}}Hello {{.}}{{end}}`), ModTime: modTime},
+		"page.html":              {Data: []byte(page), ModTime: modTime},
+	}
+}
+
+// execute executes the test page, and returns the output
+func execute(t *testing.T, tmpls *Templates) (string, error) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	err := tmpls.Execute(&buf, "page.html", "Avery")
+
+	return buf.String(), err
+}
+
+// assertOutput fails if the test page cannot be executed, or its output is not as expected
+func assertOutput(t *testing.T, tmpls *Templates, want string) {
+	t.Helper()
+
+	got, err := execute(t, tmpls)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != want {
+		t.Errorf("output %q, want %q", got, want)
+	}
+}
+
+// assertParseError fails if the test page can be loaded, or the error does not name the page
+func assertParseError(t *testing.T, tmpls *Templates) {
+	t.Helper()
+
+	_, err := execute(t, tmpls)
+	if (err == nil) || !strings.HasPrefix(err.Error(), "Template page.html: ") {
+		t.Fatalf("err = %v, want a parse error for page.html", err)
+	}
+}
+
+const (
+	goodPage   = `{{define "title"}}Page{{end}}{{define "body"}}{{template "greeting" .}}{{end}}`
+	brokenPage = `{{define "title"}}Page{{end}}{{define "body"}}{{template "greeting" .}}`
+)
+
+func TestTemplatesParseError(t *testing.T) {
+	var (
+		now   = time.Now()
+		fsys  = newTestFS(brokenPage, now)
+		tmpls = NewTemplates(fsys)
+	)
+
+	assertParseError(t, tmpls)
+	if err := tmpls.LoadAll(); err == nil {
+		t.Error("LoadAll of a broken page should fail")
+	}
+
+	// A page that failed to parse is parsed again when it is next used, even without reload
+	fsys["page.html"] = &fstest.MapFile{Data: []byte(goodPage), ModTime: now}
+	assertOutput(t, tmpls, "Page: Hello Avery")
+}
+
+func TestTemplatesMissingLayout(t *testing.T) {
+	fsys := newTestFS(goodPage, time.Now())
+	fsys[layoutFile] = &fstest.MapFile{Data: []byte(`{{define "other"}}{{end}}`)}
+
+	assertParseError(t, NewTemplates(fsys))
+}
+
+func TestTemplatesReload(t *testing.T) {
+	var (
+		now   = time.Now()
+		fsys  = newTestFS(goodPage, now)
+		tmpls = NewTemplates(fsys).WithReload(true)
+	)
+	assertOutput(t, tmpls, "Page: Hello Avery")
+
+	// A broken edit is returned as an error, rather than panicking
+	now = now.Add(time.Second)
+	fsys["page.html"] = &fstest.MapFile{Data: []byte(brokenPage), ModTime: now}
+	assertParseError(t, tmpls)
+
+	if tmpl, _ := tmpls.Load("page.html"); tmpl != nil {
+		t.Error("Load of a broken page should not return a template")
+	}
+
+	// Fixing the page reloads it
+	now = now.Add(time.Second)
+	fsys["page.html"] = &fstest.MapFile{
+		Data:    []byte(`{{define "title"}}Fixed{{end}}{{define "body"}}{{template "greeting" .}}{{end}}`),
+		ModTime: now,
+	}
+	assertOutput(t, tmpls, "Fixed: Hello Avery")
+
+	// A changed partial also reloads the page
+	now = now.Add(time.Second)
+	fsys["partials/greeting.html"] = &fstest.MapFile{Data: []byte(`{{define "greeting"}}Hi {{.}}{{end}}`), ModTime: now}
+	assertOutput(t, tmpls, "Fixed: Hi Avery")
+}
+
+func TestTemplatesNoReload(t *testing.T) {
+	var (
+		now   = time.Now()
+		fsys  = newTestFS(goodPage, now)
+		tmpls = NewTemplates(fsys)
+	)
+	assertOutput(t, tmpls, "Page: Hello Avery")
+
+	// Without reload, a page is parsed once
+	fsys["page.html"] = &fstest.MapFile{Data: []byte(brokenPage), ModTime: now.Add(time.Second)}
+	assertOutput(t, tmpls, "Page: Hello Avery")
+}
+
+func TestTemplatesConcurrent(t *testing.T) {
+	var (
+		tmpls = NewTemplates(newTestFS(goodPage, time.Now())).WithReload(true)
+		wg    sync.WaitGroup
+	)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := execute(t, tmpls); (err != nil) || (got != "Page: Hello Avery") {
+				t.Errorf("output %q, err %v", got, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestAssetTemplates(t *testing.T) {
+	if err := NewTemplates(AssetTemplates()).LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+}
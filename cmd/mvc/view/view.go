@@ -3,31 +3,145 @@
 package view
 
 import (
-	"html/template"
-	"os"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
 )
 
-// Template filename constants
-const (
-	customerTemplateFile = "cmd/mvc/view/customer.html"
-)
-
-// Template variables
-var (
-	customerTemplate = template.Must(template.ParseFiles(customerTemplateFile))
-)
+// format is a Renderer registered under a path suffix and media type
+type format struct {
+	suffix    string
+	mediaType string
+	renderer  Renderer
+}
 
-// View provides the view
-type View struct{}
+// View provides the view, in any of the formats it has a Renderer for.
+// The first format registered is the default.
+type View struct {
+	formats []format
+}
 
-// NewView constructs a view
+// NewView constructs a view that renders HTML (the default) using the compiled in templates, JSON, CSV and plain text
 func NewView() *View {
-	return &View{}
+	return (&View{}).
+		WithTemplates(NewTemplates(AssetTemplates())).
+		WithRenderer("json", "application/json", jsonRenderer{}).
+		WithRenderer("csv", "text/csv", csvRenderer{}).
+		WithRenderer("txt", "text/plain", textRenderer{})
+}
+
+// WithRenderer registers a Renderer for a path suffix (eg json) and media type (eg application/json).
+// Registering an existing suffix replaces its Renderer.
+func (v *View) WithRenderer(suffix, mediaType string, r Renderer) *View {
+	f := format{suffix: suffix, mediaType: mediaType, renderer: r}
+	for i := range v.formats {
+		if v.formats[i].suffix == suffix {
+			v.formats[i] = f
+			return v
+		}
+	}
+
+	v.formats = append(v.formats, f)
+	return v
+}
+
+// WithTemplates renders HTML using the given templates
+func (v *View) WithTemplates(t *Templates) *View {
+	return v.WithRenderer("html", "text/html", htmlRenderer{templates: t})
+}
+
+// HasSuffix returns true if there is a Renderer for a path suffix
+func (v View) HasSuffix(suffix string) bool {
+	for _, f := range v.formats {
+		if f.suffix == suffix {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Negotiate selects a Renderer.
+// If the suffix is non-empty, the Renderer for the suffix is selected.
+// Otherwise the Renderer is selected by the Accept header value, where an empty value selects the default Renderer.
+// Returns false if no Renderer is acceptable.
+func (v View) Negotiate(suffix, accept string) (Renderer, bool) {
+	if suffix != "" {
+		for _, f := range v.formats {
+			if f.suffix == suffix {
+				return f.renderer, true
+			}
+		}
+
+		return nil, false
+	}
+
+	if strings.TrimSpace(accept) == "" {
+		if len(v.formats) == 0 {
+			return nil, false
+		}
+
+		return v.formats[0].renderer, true
+	}
+
+	// Choose the format with the highest quality, where the first format wins ties
+	var (
+		best    Renderer
+		bestQ   float64
+		bestSet bool
+	)
+
+	for _, f := range v.formats {
+		if q := acceptQuality(accept, f.mediaType); (q > 0) && (!bestSet || (q > bestQ)) {
+			best, bestQ, bestSet = f.renderer, q, true
+		}
+	}
+
+	return best, bestSet
 }
 
-// RenderCustomer
-func (v View) RenderCustomer(c Customer) {
-	if err := customerTemplate.Execute(os.Stdout, c); err != nil {
-		panic(err)
+// acceptQuality returns the quality value an Accept header value gives a media type, or 0 if it is not acceptable.
+// The most specific matching media range applies: type/subtype, then type/*, then */*.
+func acceptQuality(accept, mediaType string) float64 {
+	var (
+		typ          = mediaType[:strings.IndexByte(mediaType, '/')+1]
+		q            float64
+		bestSpecific = -1
+	)
+
+	for _, mediaRange := range strings.Split(accept, ",") {
+		rng, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+
+		specific := -1
+		switch {
+		case rng == mediaType:
+			specific = 2
+		case rng == typ+"*":
+			specific = 1
+		case rng == "*/*":
+			specific = 0
+		}
+
+		if specific > bestSpecific {
+			bestSpecific = specific
+			q = 1
+			if qstr, haveQ := params["q"]; haveQ {
+				if q, err = strconv.ParseFloat(qstr, 64); err != nil {
+					q = 0
+				}
+			}
+		}
 	}
+
+	return q
+}
+
+// RenderCustomer renders one customer in the default format
+func (v View) RenderCustomer(w io.Writer, c Customer) error {
+	r, _ := v.Negotiate("", "")
+	return r.RenderCustomer(w, CustomerForm{Customer: c})
 }
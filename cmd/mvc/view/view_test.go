@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package view
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// testCustomer is rendered by the renderer tests
+var testCustomer = Customer{
+	ID:        1,
+	FirstName: "John",
+	LastName:  "Doe",
+	Address:   Address{Line: "123 Sesame St", City: "New York", Region: "NY", Country: "USA", MailCode: "12345"},
+}
+
+func TestAcceptQuality(t *testing.T) {
+	for _, test := range []struct {
+		accept    string
+		mediaType string
+		want      float64
+	}{
+		{"application/json", "application/json", 1},
+		{"text/html", "application/json", 0},
+		{"application/json;q=0.5", "application/json", 0.5},
+		{"*/*;q=0.1", "text/csv", 0.1},
+		{"text/*;q=0.3, */*;q=0.1", "text/csv", 0.3},
+		{"text/*;q=0.3, text/csv;q=0.8, */*;q=0.1", "text/csv", 0.8},
+		// The most specific range applies, even if a less specific one has a higher quality
+		{"text/csv;q=0, */*", "text/csv", 0},
+		{"text/*;q=0.2, */*", "text/csv", 0.2},
+		{"application/json;q=abc", "application/json", 0},
+		{"not a media type, application/json", "application/json", 1},
+	} {
+		if got := acceptQuality(test.accept, test.mediaType); got != test.want {
+			t.Errorf("acceptQuality(%q, %q) = %v, want %v", test.accept, test.mediaType, got, test.want)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	v := NewView()
+
+	for _, test := range []struct {
+		suffix string
+		accept string
+		want   string
+	}{
+		{"", "", "text/html; charset=utf-8"},
+		{"", " ", "text/html; charset=utf-8"},
+		{"", "*/*", "text/html; charset=utf-8"},
+		{"", "application/json", "application/json"},
+		{"", "text/*", "text/html; charset=utf-8"},
+		{"", "text/html;q=0.5, application/json;q=0.9", "application/json"},
+		{"", "text/html;q=0.5, text/plain", "text/plain; charset=utf-8"},
+		{"", "text/*;q=0.5, text/csv", "text/csv; charset=utf-8"},
+		{"", "text/html;q=0, */*;q=0.5", "application/json"},
+		{"", "image/png", ""},
+		{"", "text/html;q=0", ""},
+		{"csv", "application/json", "text/csv; charset=utf-8"},
+		{"txt", "", "text/plain; charset=utf-8"},
+		{"pdf", "", ""},
+	} {
+		r, acceptable := v.Negotiate(test.suffix, test.accept)
+		if test.want == "" {
+			if acceptable {
+				t.Errorf("suffix %q, Accept %q: got %s, want none acceptable", test.suffix, test.accept, r.ContentType())
+			}
+			continue
+		}
+
+		if !acceptable {
+			t.Errorf("suffix %q, Accept %q: none acceptable, want %s", test.suffix, test.accept, test.want)
+		} else if got := r.ContentType(); got != test.want {
+			t.Errorf("suffix %q, Accept %q: got %s, want %s", test.suffix, test.accept, got, test.want)
+		}
+	}
+}
+
+func TestNegotiateNoFormats(t *testing.T) {
+	if _, acceptable := (View{}).Negotiate("", ""); acceptable {
+		t.Error("a view with no formats should have nothing acceptable")
+	}
+}
+
+func TestWithRenderer(t *testing.T) {
+	v := NewView().WithRenderer("json", "application/vnd.customer+json", jsonRenderer{})
+
+	if _, acceptable := v.Negotiate("", "application/json"); acceptable {
+		t.Error("a replaced media type should not be acceptable")
+	}
+
+	if _, acceptable := v.Negotiate("", "application/vnd.customer+json"); !acceptable {
+		t.Error("the replacing media type should be acceptable")
+	}
+
+	if !v.HasSuffix("json") || v.HasSuffix("xml") {
+		t.Error("HasSuffix should only be true for registered suffixes")
+	}
+}
+
+// render renders testCustomer with the Renderer for a suffix
+func render(t *testing.T, suffix string, f CustomerForm) string {
+	t.Helper()
+
+	r, acceptable := NewView().Negotiate(suffix, "")
+	if !acceptable {
+		t.Fatalf("no renderer for %s", suffix)
+	}
+
+	var buf bytes.Buffer
+	if err := r.RenderCustomer(&buf, f); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.String()
+}
+
+func TestRenderJSON(t *testing.T) {
+	var got Customer
+	if err := json.Unmarshal([]byte(render(t, "json", CustomerForm{Customer: testCustomer})), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got != testCustomer {
+		t.Errorf("rendered %+v, want %+v", got, testCustomer)
+	}
+
+	// Validation errors are rendered instead of the customer
+	var errs struct{ Errors map[string]string }
+	body := render(t, "json", CustomerForm{Customer: testCustomer, Errors: map[string]string{"firstName": "required"}})
+	if err := json.Unmarshal([]byte(body), &errs); err != nil {
+		t.Fatal(err)
+	}
+
+	if errs.Errors["firstName"] != "required" {
+		t.Errorf("rendered %s, want the errors", body)
+	}
+}
+
+func TestRenderCSV(t *testing.T) {
+	rows, err := csv.NewReader(strings.NewReader(render(t, "csv", CustomerForm{Customer: testCustomer}))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"1", "John", "Doe", "123 Sesame St", "New York", "NY", "USA", "12345"}
+	if (len(rows) != 2) || (strings.Join(rows[0], ",") != strings.Join(csvHeader, ",")) ||
+		(strings.Join(rows[1], ",") != strings.Join(want, ",")) {
+		t.Errorf("rendered %q, want the header and %q", rows, want)
+	}
+}
+
+func TestRenderHTMLAndText(t *testing.T) {
+	for _, suffix := range []string{"html", "txt"} {
+		body := render(t, suffix, CustomerForm{Customer: testCustomer, Lang: "en"})
+
+		for _, want := range []string{"John", "New York, NY 12345"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s: rendered %s, want it to contain %q", suffix, body, want)
+			}
+		}
+	}
+}
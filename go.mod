@@ -2,4 +2,4 @@
 
 module github.com/bantling/gopatterns
 
-go 1.14
+go 1.16